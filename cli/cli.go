@@ -34,6 +34,14 @@ type cliConfig struct {
 	Engine     engine.Config    `config:",squash"`
 	Log        logConfig        `config:"log"`
 	Monitoring monitoringConfig `config:"monitoring"`
+	Drain      drainConfig      `config:"drain"`
+	RunSummary runSummaryConfig `config:"run-summary"`
+}
+
+// drainConfig configures graceful shutdown on SIGINT or SIGTERM.
+type drainConfig struct {
+	// Timeout limits awaiting of shoots in flight. After that, they are canceled.
+	Timeout time.Duration `config:"timeout" validate:"min-time=0s"`
 }
 
 type logConfig struct {
@@ -74,16 +82,29 @@ func defaultConfig() *cliConfig {
 				Enabled: false,
 				File:    "memprofile.log",
 			},
+			Control: &controlConfig{
+				Enabled: false,
+			},
+			Prometheus: defaultPrometheusConfig(),
+		},
+		Drain: drainConfig{
+			Timeout: 30 * time.Second,
 		},
 	}
 }
 
-// TODO(skipor): make nice spf13/cobra CLI and integrate it with viper
-// TODO(skipor): on special command (help or smth else) print list of available plugins
-
 func Run() {
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage of Pandora: pandora [<config_filename>]\n"+"<config_filename> is './%s.(yaml|json|...)' by default\n", defaultConfigFile)
+		fmt.Fprintf(os.Stderr, "Usage of Pandora: pandora [flags] [run] [<config_filename>]\n"+"<config_filename> is './%s.(yaml|json|...)' by default\n", defaultConfigFile)
+		fmt.Fprintf(os.Stderr, "       pandora validate [<config_filename>] - decode and validate config without shooting\n")
+		fmt.Fprintf(os.Stderr, "       pandora dry-run [flags] [<config_filename>] - read ammo and build requests by schedule without sending\n")
+		fmt.Fprintf(os.Stderr, "       pandora preview [-format table|csv|chart] [<config_filename>] - print planned RPS and instances per second\n")
+		fmt.Fprintf(os.Stderr, "       pandora coordinator [-agents <addr,...>|-local N] [<config_filename>] - split load between agents\n")
+		fmt.Fprintf(os.Stderr, "       pandora agent [-listen <addr>] - shoot load part received from coordinator\n")
+		fmt.Fprintf(os.Stderr, "       pandora plugins [<plugin_type>] - list registered plugins with default configs\n")
+		fmt.Fprintf(os.Stderr, "       pandora example [-type <plugin_type>] [<plugin_name>] - print example config or plugin config snippet\n")
+		fmt.Fprintf(os.Stderr, "       pandora report [flags] <results_file>... - build HTML report from phout or jsonlines results\n")
+		fmt.Fprintf(os.Stderr, "       pandora compare [flags] <baseline_file> <current_file> - compare results with baseline\n")
 		flag.PrintDefaults()
 	}
 	var (
@@ -101,8 +122,8 @@ func Run() {
 	}
 
 	if example {
-		panic("Not implemented yet")
-		// TODO: print example config file content
+		fmt.Print(exampleConfig)
+		return
 	}
 
 	if version {
@@ -110,11 +131,50 @@ func Run() {
 		return
 	}
 
-	ReadConfigAndRunEngine()
+	args := flag.Args()
+	if len(args) > 0 {
+		switch args[0] {
+		case "run":
+			args = args[1:]
+		case "validate":
+			runValidate(args[1:])
+			return
+		case "dry-run":
+			runDryRun(args[1:])
+			return
+		case "preview":
+			runPreview(args[1:])
+			return
+		case "coordinator":
+			runCoordinator(args[1:])
+			return
+		case "agent":
+			runAgent(args[1:])
+			return
+		case "plugins":
+			runPlugins(args[1:])
+			return
+		case "example":
+			runExample(args[1:])
+			return
+		case "report":
+			runReport(args[1:])
+			return
+		case "compare":
+			runCompare(args[1:])
+			return
+		}
+	}
+	runConfig(args)
 }
 
+// ReadConfigAndRunEngine reads config from file passed in command line arguments and runs engine.
 func ReadConfigAndRunEngine() {
-	conf := readConfig(flag.Args())
+	runConfig(flag.Args())
+}
+
+func runConfig(args []string) {
+	conf := readConfig(args)
 	log := newLogger(conf.Log)
 	zap.ReplaceGlobals(log)
 	zap.RedirectStdLog(log)
@@ -125,6 +185,12 @@ func ReadConfigAndRunEngine() {
 	startReport(m)
 
 	pandora := engine.New(log, m, conf.Engine)
+	if conf.Monitoring.Control.Enabled {
+		registerControlHandlers(http.DefaultServeMux, pandora)
+	}
+	if conf.Monitoring.Prometheus.Enabled {
+		registerPrometheusHandler(http.DefaultServeMux, conf.Monitoring.Prometheus, m, pandora)
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -133,39 +199,50 @@ func ReadConfigAndRunEngine() {
 	go runEngine(ctx, pandora, errs)
 
 	// waiting for signal or error message from engine
-	awaitPandoraTermination(pandora, cancel, errs, log)
+	res := awaitPandoraTermination(pandora, conf.Drain, cancel, errs, log)
+	code := exitCode(res)
+	if conf.RunSummary.File != "" {
+		summary := newRunSummary(res, code, m, pandora.PoolsStatus())
+		err := writeRunSummary(conf.RunSummary.File, summary)
+		if err != nil {
+			log.Error("Run summary write failed", zap.Error(err))
+		}
+	}
+	if code != 0 {
+		closeMonitoring()
+		log.Info("Pandora exits", zap.Int("exit_code", code))
+		os.Exit(code)
+	}
 	log.Info("Engine run successfully finished")
 }
 
-// helper function that awaits pandora run
-func awaitPandoraTermination(pandora *engine.Engine, gracefulShutdown func(), errs chan error, log *zap.Logger) {
+// runResult is result of pandora run, awaited by awaitPandoraTermination.
+type runResult struct {
+	start  time.Time
+	finish time.Time
+	// err is engine run error, or drain fail error.
+	err error
+	// interrupted is true, if run has been interrupted by signal.
+	interrupted bool
+}
+
+// helper function that awaits pandora run.
+// On SIGINT or SIGTERM pandora is drained.
+func awaitPandoraTermination(pandora *engine.Engine, conf drainConfig, gracefulShutdown func(), errs chan error, log *zap.Logger) runResult {
 	sigs := make(chan os.Signal, 2)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 
+	res := runResult{start: time.Now()}
 	select {
 	case sig := <-sigs:
-		var interruptTimeout = 3 * time.Second
 		switch sig {
-		case syscall.SIGINT:
-			// await gun timeout but no longer than 30 sec.
-			interruptTimeout = 30 * time.Second
-			log.Info("SIGINT received. Graceful shutdown.", zap.Duration("timeout", interruptTimeout))
-			gracefulShutdown()
-		case syscall.SIGTERM:
-			log.Info("SIGTERM received. Trying to stop gracefully.", zap.Duration("timeout", interruptTimeout))
-			gracefulShutdown()
+		case syscall.SIGINT, syscall.SIGTERM:
+			log.Info("Signal received. Graceful shutdown.", zap.Stringer("signal", sig), zap.Duration("drain_timeout", conf.Timeout))
 		default:
 			log.Fatal("Unexpected signal received. Quiting.", zap.Stringer("signal", sig))
 		}
-
-		select {
-		case <-time.After(interruptTimeout):
-			log.Fatal("Interrupt timeout exceeded")
-		case sig := <-sigs:
-			log.Fatal("Another signal received. Quiting.", zap.Stringer("signal", sig))
-		case err := <-errs:
-			log.Fatal("Engine interrupted", zap.Error(err))
-		}
+		res.interrupted = true
+		res.err = awaitDrain(pandora, conf, gracefulShutdown, errs, sigs, log)
 
 	case err := <-errs:
 		switch err {
@@ -175,12 +252,73 @@ func awaitPandoraTermination(pandora *engine.Engine, gracefulShutdown func(), er
 			const awaitTimeout = 3 * time.Second
 			log.Error("Engine run failed. Awaiting started tasks.", zap.Error(err), zap.Duration("timeout", awaitTimeout))
 			gracefulShutdown()
-			time.AfterFunc(awaitTimeout, func() {
-				log.Fatal("Engine tasks timeout exceeded.")
-			})
-			pandora.Wait()
-			log.Fatal("Engine run failed. Pandora graceful shutdown successfully finished")
+			if awaitEngineTasks(pandora, awaitTimeout, log) {
+				log.Info("Engine run failed. Pandora graceful shutdown successfully finished")
+			}
+			res.err = err
+		}
+	}
+	res.finish = time.Now()
+	return res
+}
+
+// drainError is returned, when drain is not finished, because drain timeout is exceeded, or
+// another signal is received. Run is still interrupted by signal in that case.
+type drainError struct {
+	reason string
+}
+
+func (e *drainError) Error() string { return e.reason }
+
+// awaitDrain drains pandora and awaits engine run finish. If drain timeout is exceeded,
+// or another signal is received, shoots in flight are canceled, and *drainError is returned.
+func awaitDrain(pandora *engine.Engine, conf drainConfig, cancel func(), errs chan error, sigs chan os.Signal, log *zap.Logger) error {
+	drainStart := time.Now()
+	pandora.Drain()
+	timeout := time.NewTimer(conf.Timeout)
+	defer timeout.Stop()
+	var drainErr error
+	select {
+	case err := <-errs:
+		if err != nil {
+			log.Error("Engine failed while draining", zap.Error(err))
+			return err
 		}
+		log.Info("Drain finished. Shoots in flight are completed and results are flushed.",
+			zap.Duration("duration", time.Since(drainStart)))
+		return nil
+	case <-timeout.C:
+		log.Error("Drain timeout exceeded. Canceling shoots in flight.", zap.Duration("timeout", conf.Timeout))
+		drainErr = &drainError{fmt.Sprintf("drain timeout %v exceeded", conf.Timeout)}
+	case sig := <-sigs:
+		log.Error("Another signal received. Canceling shoots in flight.", zap.Stringer("signal", sig))
+		drainErr = &drainError{fmt.Sprintf("drain interrupted by %v", sig)}
+	}
+	cancel()
+	const awaitTimeout = 3 * time.Second
+	select {
+	case <-errs:
+		awaitEngineTasks(pandora, awaitTimeout, log)
+	case <-time.After(awaitTimeout):
+		log.Error("Engine run cancel timeout exceeded")
+	}
+	log.Error("Drain failed. Results of canceled shoots are incomplete.")
+	return drainErr
+}
+
+// awaitEngineTasks waits engine tasks no longer than timeout. Returns false on timeout.
+func awaitEngineTasks(pandora *engine.Engine, timeout time.Duration, log *zap.Logger) bool {
+	done := make(chan struct{})
+	go func() {
+		pandora.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		log.Error("Engine tasks timeout exceeded.")
+		return false
 	}
 }
 
@@ -191,11 +329,22 @@ func runEngine(ctx context.Context, engine *engine.Engine, errs chan error) {
 }
 
 func readConfig(args []string) *cliConfig {
+	settings := readConfigSettings(args)
+	conf := defaultConfig()
+	err := config.DecodeAndValidate(settings, conf)
+	if err != nil {
+		zap.L().Fatal("Config decode failed", zap.Error(err))
+	}
+	return conf
+}
+
+// readConfigSettings reads config file or standard input passed in args into map, without decoding.
+func readConfigSettings(args []string) map[string]interface{} {
 	log, err := zap.NewDevelopment(zap.AddCaller())
 	if err != nil {
 		panic(err)
 	}
-	log = log.WithOptions(zap.WrapCore(zaputil.NewStackExtractCore))
+	log = log.WithOptions(zap.WrapCore(zaputil.NewStackExtractCore), zap.WithFatalHook(exitOnFatal(exitCodeConfig)))
 	zap.ReplaceGlobals(log)
 	zap.RedirectStdLog(log)
 
@@ -232,12 +381,7 @@ func readConfig(args []string) *cliConfig {
 		}
 	}
 
-	conf := defaultConfig()
-	err = config.DecodeAndValidate(v.AllSettings(), conf)
-	if err != nil {
-		log.Fatal("Config decode failed", zap.Error(err))
-	}
-	return conf
+	return v.AllSettings()
 }
 
 func newViper() *viper.Viper {
@@ -253,6 +397,9 @@ type monitoringConfig struct {
 	Expvar     *expvarConfig
 	CPUProfile *cpuprofileConfig
 	MemProfile *memprofileConfig
+	// Control API and Prometheus metrics are served by expvar HTTP server.
+	Control    *controlConfig
+	Prometheus *prometheusConfig
 }
 
 type expvarConfig struct {
@@ -273,7 +420,7 @@ type memprofileConfig struct {
 func startMonitoring(conf monitoringConfig) (stop func()) {
 	zap.L().Debug("Start monitoring", zap.Reflect("conf", conf))
 	if conf.Expvar != nil {
-		if conf.Expvar.Enabled {
+		if conf.Expvar.Enabled || conf.Control.Enabled || conf.Prometheus.Enabled {
 			go func() {
 				err := http.ListenAndServe(":"+strconv.Itoa(conf.Expvar.Port), nil)
 				zap.L().Fatal("Monitoring server failed", zap.Error(err))
@@ -0,0 +1,194 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/yandex/pandora/core/config"
+	"github.com/yandex/pandora/core/plugin"
+	"gopkg.in/yaml.v2"
+)
+
+// runValidate runs 'pandora validate' subcommand, that decodes and validates config, creating
+// all configured plugins, but doesn't shoot.
+func runValidate(args []string) {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of Pandora validate: pandora validate [<config_filename>]\n"+
+			"Config is decoded and validated as on run, but engine is not started.\n"+
+			"Files written by plugins via plugin file system are kept in memory.\n")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	useValidationFs()
+	readConfig(flags.Args())
+	fmt.Fprintf(os.Stderr, "Config is valid\n")
+}
+
+// runPlugins runs 'pandora plugins' subcommand, that prints all registered plugins with their default configs.
+func runPlugins(args []string) {
+	flags := flag.NewFlagSet("plugins", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of Pandora plugins: pandora plugins [<plugin_type>]\n"+
+			"Prints registered plugins with default configs. Plugin type is like 'gun' or 'core.Gun'.\n")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() > 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	plugins := map[string]map[string]interface{}{}
+	for _, p := range plugin.Plugins() {
+		if flags.NArg() == 1 && !matchPluginType(p.Type, flags.Arg(0)) {
+			continue
+		}
+		conf, err := plugin.DefaultConfig(p.Type, p.Name)
+		if err != nil {
+			exitOnError(err)
+		}
+		typeName := p.Type.String()
+		if plugins[typeName] == nil {
+			plugins[typeName] = map[string]interface{}{}
+		}
+		plugins[typeName][p.Name] = config.Encode(conf)
+	}
+	if len(plugins) == 0 {
+		exitOnError(errors.Errorf("no plugins of type %q", flags.Arg(0)))
+	}
+	printYAML(plugins)
+}
+
+// runExample runs 'pandora example' subcommand, that prints config snippet of plugin with default
+// config, or whole example config, if no plugin passed.
+func runExample(args []string) {
+	flags := flag.NewFlagSet("example", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of Pandora example: pandora example [-type <plugin_type>] [<plugin_name>]\n"+
+			"Prints config snippet of plugin with default config. Whole example config is printed, if no plugin passed.\n")
+		flags.PrintDefaults()
+	}
+	var pluginType string
+	flags.StringVar(&pluginType, "type", "", "plugin type like 'gun' or 'core.Gun'; plugins of all types with passed name are printed by default")
+	_ = flags.Parse(args)
+	switch flags.NArg() {
+	case 0:
+		fmt.Print(exampleConfig)
+		return
+	case 1:
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+	name := flags.Arg(0)
+	var found bool
+	for _, p := range plugin.Plugins() {
+		if p.Name != name || pluginType != "" && !matchPluginType(p.Type, pluginType) {
+			continue
+		}
+		conf, err := plugin.DefaultConfig(p.Type, p.Name)
+		if err != nil {
+			exitOnError(err)
+		}
+		if found {
+			fmt.Println()
+		}
+		found = true
+		fmt.Printf("# %s %q\n", p.Type, p.Name)
+		printYAML(pluginSnippet(p, conf))
+	}
+	if !found {
+		exitOnError(errors.Errorf("plugin %q is not registered; run 'pandora plugins' to list registered plugins", name))
+	}
+}
+
+// pluginConfigKeys are config keys, under which plugins of some types are usually configured.
+// Plugins, which key is list, are configured as list elements.
+var pluginConfigKeys = map[string]struct {
+	key  string
+	list bool
+}{
+	"core.Gun":                    {key: "gun"},
+	"core.Provider":               {key: "ammo"},
+	"core.Aggregator":             {key: "result"},
+	"core.Schedule":               {key: "rps"},
+	"core.DataSource":             {key: "source"},
+	"core.DataSink":               {key: "sink"},
+	"middleware.Middleware":       {key: "middlewares", list: true},
+	"postprocessor.Postprocessor": {key: "postprocessors", list: true},
+	"httpscenario.VariableSource": {key: "variable_sources", list: true},
+	"httpscenario.Templater":      {key: "templater"},
+	"autostop.Criterion":          {key: "autostop", list: true},
+}
+
+func pluginSnippet(p plugin.Info, conf interface{}) yaml.MapSlice {
+	pluginConf := yaml.MapSlice{{Key: "type", Value: p.Name}}
+	if encoded, ok := config.Encode(conf).(map[string]interface{}); ok {
+		keys := make([]string, 0, len(encoded))
+		for key := range encoded {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			pluginConf = append(pluginConf, yaml.MapItem{Key: key, Value: encoded[key]})
+		}
+	}
+	key, ok := pluginConfigKeys[p.Type.String()]
+	if !ok {
+		key.key = shortTypeName(p.Type)
+	}
+	if key.list {
+		return yaml.MapSlice{{Key: key.key, Value: []interface{}{pluginConf}}}
+	}
+	return yaml.MapSlice{{Key: key.key, Value: pluginConf}}
+}
+
+// matchPluginType returns true, if pluginType is like 'gun', 'Gun' or 'core.Gun'.
+func matchPluginType(t reflect.Type, pluginType string) bool {
+	return strings.EqualFold(t.String(), pluginType) || strings.EqualFold(shortTypeName(t), pluginType)
+}
+
+func shortTypeName(t reflect.Type) string {
+	return strings.ToLower(t.Name())
+}
+
+func printYAML(v interface{}) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		exitOnError(err)
+	}
+	_, _ = os.Stdout.Write(data)
+}
+
+func exitOnError(err error) {
+	fmt.Fprintf(os.Stderr, "%v\n", err)
+	os.Exit(1)
+}
+
+const exampleConfig = `pools:
+  - id: HTTP pool                    # pool name (for your choice)
+    gun:
+      type: http                     # gun type
+      target: example.com:80         # gun target
+    ammo:
+      type: uri                      # ammo format
+      file: ./ammo.uri               # ammo file
+    result:
+      type: phout                    # report format (phout is compatible with Yandex.Tank)
+      destination: ./phout.log       # report file name
+    rps:                             # shooting schedule
+      type: line                     # linear growth
+      from: 1                        # from 1 response per second
+      to: 5                          # to 5 responses per second
+      duration: 60s                  # for 60 seconds
+    startup:                         # instances startup schedule
+      type: once                     # start 10 instances
+      times: 10
+log:
+  level: info
+`
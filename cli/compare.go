@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/yandex/pandora/core/report"
+)
+
+// Exit codes of 'pandora compare'.
+const (
+	compareRegressionExitCode = 1
+	compareFailedExitCode     = 2
+)
+
+// runCompare runs 'pandora compare' subcommand, that compares results of two runs and exits
+// with non-zero code on regression.
+func runCompare(args []string) {
+	flags := flag.NewFlagSet("compare", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of Pandora compare: pandora compare [flags] <baseline_file> <current_file>\n"+
+			"Results files are written by phout or jsonlines aggregators. '-' means standard input.\n"+
+			"Exit code is %d on regression and %d on failure.\n", compareRegressionExitCode, compareFailedExitCode)
+		flags.PrintDefaults()
+	}
+	conf := report.DefaultCompareConfig()
+	quantiles := "50,95,99"
+	flags.StringVar(&quantiles, "quantiles", quantiles, "comma separated RTT quantiles in percents to compare")
+	flags.Float64Var(&conf.LatencyTolerance, "latency-tolerance", conf.LatencyTolerance, "allowed RTT quantile increase in percents")
+	flags.DurationVar(&conf.LatencySlack, "latency-slack", conf.LatencySlack, "allowed absolute RTT quantile increase")
+	flags.Float64Var(&conf.RPSTolerance, "rps-tolerance", conf.RPSTolerance, "allowed RPS decrease in percents")
+	flags.Float64Var(&conf.ErrorsTolerance, "errors-tolerance", conf.ErrorsTolerance, "allowed errors share increase in percentage points")
+	_ = flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(compareFailedExitCode)
+	}
+	diffs, err := compareResults(flags.Arg(0), flags.Arg(1), quantiles, conf)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Compare failed: %v\n", err)
+		os.Exit(compareFailedExitCode)
+	}
+	_ = report.WriteDiffTable(os.Stdout, diffs)
+	if report.HasRegression(diffs) {
+		fmt.Fprintf(os.Stderr, "Regression detected\n")
+		os.Exit(compareRegressionExitCode)
+	}
+}
+
+func compareResults(baselineFile, currentFile, quantiles string, conf report.CompareConfig) ([]report.Diff, error) {
+	reportConf := report.DefaultConfig()
+	reportConf.Quantiles = nil
+	for _, q := range strings.Split(quantiles, ",") {
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(q), 64)
+		if err != nil || parsed <= 0 || parsed > 100 {
+			return nil, errors.Errorf("invalid quantile %q", q)
+		}
+		reportConf.Quantiles = append(reportConf.Quantiles, parsed)
+	}
+	var reports []*report.Report
+	for _, input := range []string{baselineFile, currentFile} {
+		b := report.NewBuilder(reportConf)
+		err := readResults(input, b.Add)
+		if err != nil {
+			return nil, errors.WithMessage(err, input)
+		}
+		reports = append(reports, b.Build(input))
+	}
+	return report.Compare(reports[0], reports[1], conf), nil
+}
@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/yandex/pandora/core"
+	"github.com/yandex/pandora/core/config"
+	"github.com/yandex/pandora/core/engine"
+	"go.uber.org/zap"
+)
+
+const controlPathPrefix = "/control/"
+
+type controlConfig struct {
+	Enabled bool `config:"enabled"`
+}
+
+// registerControlHandlers registers HTTP API, that allows to change engine run on the fly.
+// All handlers except status are POST only. Pool is selected by 'pool' query parameter.
+// Empty pool means all pools for pause, resume and rps-multiplier.
+func registerControlHandlers(mux *http.ServeMux, pandora *engine.Engine) {
+	h := &controlHandler{engine: pandora, log: zap.L().Named("control")}
+	mux.HandleFunc(controlPathPrefix+"status", h.status)
+	mux.Handle(controlPathPrefix+"pause", h.action(func(r *http.Request, pool string) error {
+		return pandora.Pause(pool)
+	}))
+	mux.Handle(controlPathPrefix+"resume", h.action(func(r *http.Request, pool string) error {
+		return pandora.Resume(pool)
+	}))
+	mux.Handle(controlPathPrefix+"rps-multiplier", h.action(func(r *http.Request, pool string) error {
+		multiplier, err := strconv.ParseFloat(r.URL.Query().Get("value"), 64)
+		if err != nil {
+			return errors.WithMessage(err, "invalid 'value' parameter")
+		}
+		return pandora.SetRPSMultiplier(pool, multiplier)
+	}))
+	mux.Handle(controlPathPrefix+"schedule", h.action(func(r *http.Request, pool string) error {
+		newSchedule, err := decodeRPSSchedule(r.Body)
+		if err != nil {
+			return err
+		}
+		return pandora.SetRPSSchedule(pool, newSchedule)
+	}))
+	mux.Handle(controlPathPrefix+"stop", h.action(func(r *http.Request, pool string) error {
+		if pool == "" {
+			return errors.New("'pool' parameter is required")
+		}
+		return pandora.StopPool(pool)
+	}))
+}
+
+type controlHandler struct {
+	engine *engine.Engine
+	log    *zap.Logger
+}
+
+func (h *controlHandler) status(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.engine.PoolsStatus())
+}
+
+func (h *controlHandler) action(do func(r *http.Request, pool string) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		pool := r.URL.Query().Get("pool")
+		action := strings.TrimPrefix(r.URL.Path, controlPathPrefix)
+		err := do(r, pool)
+		if err != nil {
+			h.log.Warn("Control action failed", zap.String("action", action), zap.String("pool", pool), zap.Error(err))
+			code := http.StatusBadRequest
+			if errors.Cause(err) == engine.ErrPoolNotFound {
+				code = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), code)
+			return
+		}
+		h.log.Info("Control action applied", zap.String("action", action), zap.String("pool", pool))
+		h.status(w, r)
+	})
+}
+
+// decodeRPSSchedule decodes YAML or JSON document with 'rps' key, that has the same format, as pool rps section.
+func decodeRPSSchedule(r io.Reader) (func() (core.Schedule, error), error) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	err := v.ReadConfig(r)
+	if err != nil {
+		return nil, errors.WithMessage(err, "schedule parse failed")
+	}
+	var conf struct {
+		RPS func() (core.Schedule, error) `config:"rps" validate:"required"`
+	}
+	err = config.DecodeAndValidate(v.AllSettings(), &conf)
+	if err != nil {
+		return nil, errors.WithMessage(err, "schedule decode failed")
+	}
+	return conf.RPS, nil
+}
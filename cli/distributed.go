@@ -0,0 +1,241 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/rpc"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"github.com/yandex/pandora/core"
+	"github.com/yandex/pandora/core/config"
+	"github.com/yandex/pandora/core/distributed"
+	"github.com/yandex/pandora/core/engine"
+	"go.uber.org/zap"
+)
+
+// runAgent runs 'pandora agent' subcommand, that serves one coordinator connection and shoots
+// part of config load received from it.
+func runAgent(args []string) {
+	flags := flag.NewFlagSet("agent", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of Pandora agent: pandora agent [flags]\n"+
+			"Agent waits for coordinator connection, shoots part of config load received from it, and exits.\n"+
+			"Listening address is printed to stdout.\n")
+		flags.PrintDefaults()
+	}
+	var listen string
+	flags.StringVar(&listen, "listen", ":7070", "address to listen coordinator connection on")
+	_ = flags.Parse(args)
+	if flags.NArg() != 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	// Samples are sent to coordinator, so result files should not be created by agent.
+	useValidationFs()
+	log := newLogger(logConfig{Level: zap.InfoLevel, File: "stderr"})
+	zap.ReplaceGlobals(log)
+
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		exitOnError(err)
+	}
+	fmt.Println(listener.Addr())
+	conn, err := listener.Accept()
+	_ = listener.Close()
+	if err != nil {
+		exitOnError(err)
+	}
+	log.Info("Coordinator connected", zap.Stringer("addr", conn.RemoteAddr()))
+	agent := distributed.NewAgent(log, newEngineMetrics(), decodeAgentConfig)
+	agent.Serve(conn)
+	log.Info("Coordinator disconnected")
+}
+
+func decodeAgentConfig(settings map[string]interface{}) (engine.Config, error) {
+	conf := defaultConfig()
+	err := config.DecodeAndValidate(settings, conf)
+	return conf.Engine, err
+}
+
+// runCoordinator runs 'pandora coordinator' subcommand, that splits config load between agents and
+// writes merged results.
+func runCoordinator(args []string) {
+	flags := flag.NewFlagSet("coordinator", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of Pandora coordinator: pandora coordinator [flags] [<config_filename>]\n"+
+			"Load of every pool is split between agents, which are started with 'pandora agent' or by -local flag.\n"+
+			"Agents shoot, and pool results are written by coordinator.\n")
+		flags.PrintDefaults()
+	}
+	var (
+		agents string
+		local  int
+		conf   = distributed.DefaultCoordinatorConfig()
+	)
+	flags.StringVar(&agents, "agents", "", "comma separated agent addresses")
+	flags.IntVar(&local, "local", 0, "number of local agent processes to start")
+	flags.DurationVar(&conf.StartDelay, "start-delay", conf.StartDelay, "delay between agents are prepared and start of shooting; run fails, if agents are not started within it")
+	_ = flags.Parse(args)
+	if (agents == "") == (local <= 0) {
+		fmt.Fprintf(os.Stderr, "Exactly one of -agents and -local should be set\n")
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	settings := normalizeSettings(readConfigSettings(flags.Args())).(map[string]interface{})
+	log := newLogger(coordinatorLogConfig(settings))
+	zap.ReplaceGlobals(log)
+	var addrs []string
+	if agents != "" {
+		addrs = strings.Split(agents, ",")
+	}
+	err := coordinate(log, conf, settings, addrs, local)
+	if err != nil {
+		log.Error("Distributed run failed", zap.Error(err))
+		os.Exit(1)
+	}
+	log.Info("Distributed run successfully finished")
+}
+
+// coordinate connects to agents or starts local ones, and runs coordinator until agents are done.
+func coordinate(log *zap.Logger, conf distributed.CoordinatorConfig, settings map[string]interface{}, addrs []string, local int) error {
+	aggregators, err := coordinatorAggregators(settings)
+	if err != nil {
+		return errors.WithMessage(err, "config decode failed")
+	}
+
+	var clients []*rpc.Client
+	for i := 0; i < local; i++ {
+		client, cmd, err := startLocalAgent()
+		if err != nil {
+			return errors.WithMessage(err, "local agent start failed")
+		}
+		defer func() {
+			_ = client.Close()
+			_ = cmd.Wait()
+		}()
+		clients = append(clients, client)
+	}
+	for _, addr := range addrs {
+		client, err := distributed.Dial(strings.TrimSpace(addr))
+		if err != nil {
+			return errors.WithMessage(err, fmt.Sprintf("agent %q connect failed", addr))
+		}
+		defer client.Close()
+		clients = append(clients, client)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	go func() {
+		select {
+		case sig := <-sigs:
+			log.Info("Signal received. Stopping agents.", zap.Stringer("signal", sig))
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return distributed.NewCoordinator(log, conf, clients, aggregators).Run(ctx, settings)
+}
+
+func coordinatorLogConfig(settings map[string]interface{}) logConfig {
+	conf := struct {
+		Log logConfig `config:"log"`
+	}{defaultConfig().Log}
+	if logSettings, ok := settings["log"]; ok {
+		err := config.Decode(map[string]interface{}{"log": logSettings}, &conf)
+		if err != nil {
+			zap.L().Fatal("Log config decode failed", zap.Error(err))
+		}
+	}
+	return conf.Log
+}
+
+// coordinatorAggregators decodes pool results, so every pool has one result written by coordinator.
+func coordinatorAggregators(settings map[string]interface{}) ([]core.Aggregator, error) {
+	poolsSettings, ok := settings["pools"].([]interface{})
+	if !ok {
+		return nil, errors.New("config has no pools")
+	}
+	var aggregators []core.Aggregator
+	for i, poolSettings := range poolsSettings {
+		result, _ := lookupSetting(poolSettings, "result")
+		// Plugin decode modifies settings, so copy is decoded, and settings can be sent to agents.
+		result = normalizeSettings(result)
+		var pool struct {
+			Aggregator core.Aggregator `config:"result" validate:"required"`
+		}
+		err := config.DecodeAndValidate(map[string]interface{}{"result": result}, &pool)
+		if err != nil {
+			return nil, errors.WithMessage(err, fmt.Sprintf("pool %v result decode failed", i))
+		}
+		aggregators = append(aggregators, pool.Aggregator)
+	}
+	return aggregators, nil
+}
+
+// startLocalAgent starts agent process of the same binary, and connects to it.
+func startLocalAgent() (*rpc.Client, *exec.Cmd, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, nil, err
+	}
+	cmd := exec.Command(exe, "agent", "-listen", "127.0.0.1:0")
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return nil, nil, err
+	}
+	addr, err := bufio.NewReader(stdout).ReadString('\n')
+	if err == nil {
+		var client *rpc.Client
+		client, err = distributed.Dial(strings.TrimSpace(addr))
+		if err == nil {
+			return client, cmd, nil
+		}
+	}
+	_ = cmd.Process.Kill()
+	_ = cmd.Wait()
+	return nil, nil, errors.WithMessage(err, "agent connect failed")
+}
+
+// normalizeSettings returns deep copy of settings, where maps decoded from YAML are converted
+// to maps with string keys, so settings can be encoded as JSON.
+func normalizeSettings(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = normalizeSettings(value)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[key] = normalizeSettings(value)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, value := range v {
+			s[i] = normalizeSettings(value)
+		}
+		return s
+	}
+	return v
+}
@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/yandex/pandora/core/engine"
+)
+
+// runDryRun runs 'pandora dry-run' subcommand, that runs providers of all pools and prepares
+// every planned shot with gun, but sends nothing, and prints summary.
+func runDryRun(args []string) {
+	flags := flag.NewFlagSet("dry-run", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of Pandora dry-run: pandora dry-run [flags] [<config_filename>]\n"+
+			"Ammo is read and requests are built according to schedule, but nothing is sent.\n"+
+			"Ammo counts per tag, ammo errors and planned schedule are printed.\n")
+		flags.PrintDefaults()
+	}
+	conf := engine.DefaultDryRunConfig()
+	flags.IntVar(&conf.MaxShots, "max-shots", conf.MaxShots, "max number of planned and prepared shots of every pool")
+	_ = flags.Parse(args)
+	if conf.MaxShots <= 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	useValidationFs()
+	cliConf := readConfig(flags.Args())
+	log := newLogger(cliConf.Log)
+
+	pandora := engine.New(log, newEngineMetrics(), cliConf.Engine)
+	reports, err := pandora.DryRun(context.Background(), conf)
+	for i := range reports {
+		if i > 0 {
+			fmt.Println()
+		}
+		writeDryRunReport(os.Stdout, &reports[i])
+	}
+	if err != nil {
+		exitOnError(err)
+	}
+}
+
+func writeDryRunReport(w io.Writer, r *engine.PoolDryRunReport) {
+	fmt.Fprintf(w, "Pool %q\n", r.ID)
+	if r.Planned == nil {
+		fmt.Fprintf(w, "Schedule: closed loop, no planned shots\n")
+	} else {
+		fmt.Fprintf(w, "Schedule: %d shots planned for %v\n", r.PlannedShots(), time.Duration(len(r.Planned))*time.Second)
+	}
+	fmt.Fprintf(w, "Shots: %d\n", r.Shots)
+	if r.OutOfAmmo {
+		fmt.Fprintf(w, "WARNING: out of ammo before schedule finished\n")
+	}
+	if r.Truncated {
+		fmt.Fprintf(w, "WARNING: truncated by max shots limit\n")
+	}
+	if !r.GunDryShoot {
+		fmt.Fprintf(w, "Gun doesn't support dry-run: ammo has been only read\n")
+	} else {
+		writeCounts(w, "tag", r.Tags)
+	}
+	if len(r.Errors) > 0 {
+		writeCounts(w, "error", r.Errors)
+	}
+	if len(r.Planned) > 0 {
+		fmt.Fprintf(w, "Planned shots per second:\n")
+		const perRow = 10
+		for i := 0; i < len(r.Planned); i += perRow {
+			fmt.Fprintf(w, "  %4ds:", i)
+			for j := i; j < i+perRow && j < len(r.Planned); j++ {
+				fmt.Fprintf(w, " %d", r.Planned[j])
+			}
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+func writeCounts(w io.Writer, name string, counts map[string]int) {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\tcount\n", name)
+	for _, key := range keys {
+		fmt.Fprintf(tw, "%s\t%d\n", key, counts[key])
+	}
+	_ = tw.Flush()
+}
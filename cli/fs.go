@@ -0,0 +1,23 @@
+package cli
+
+import "github.com/spf13/afero"
+
+var pluginFs = &switchableFs{afero.NewOsFs()}
+
+// PluginFs returns OS file system, that should be passed to plugins imports.
+// 'pandora validate' makes it copy-on-write, so plugins created during validation can read files,
+// but files they create or truncate are kept in memory.
+// Only plugins, that access files via this file system, are sandboxed. Plugins, that use os package
+// directly, or file system returned by core/import.GetFs, still write to OS file system on validation.
+func PluginFs() afero.Fs {
+	return pluginFs
+}
+
+// switchableFs allows to replace file system after it has been passed to plugins imports.
+type switchableFs struct {
+	afero.Fs
+}
+
+func useValidationFs() {
+	pluginFs.Fs = afero.NewCopyOnWriteFs(afero.NewReadOnlyFs(pluginFs.Fs), afero.NewMemMapFs())
+}
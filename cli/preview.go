@@ -0,0 +1,217 @@
+package cli
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/yandex/pandora/core/config"
+	"github.com/yandex/pandora/core/engine"
+)
+
+// previewPoolKeys are pool config keys, that are needed to plan pool load.
+var previewPoolKeys = []string{"id", "rps", "startup", "rps-per-instance", "closed-loop", "start-delay"}
+
+// runPreview runs 'pandora preview' subcommand, that walks pools rps and startup schedules
+// in simulated time, and prints planned load.
+func runPreview(args []string) {
+	flags := flag.NewFlagSet("preview", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of Pandora preview: pandora preview [flags] [<config_filename>]\n"+
+			"Prints planned per second RPS and started instances of every pool. Only rps and startup\n"+
+			"schedules of pools are read from config, so ammo and guns are not required.\n")
+		flags.PrintDefaults()
+	}
+	var (
+		format   string
+		maxShots int
+	)
+	flags.StringVar(&format, "format", "table", "output format: 'table', 'csv' or 'chart'")
+	flags.IntVar(&maxShots, "max-shots", engine.DefaultDryRunConfig().MaxShots, "max number of planned shots and instances of every pool")
+	_ = flags.Parse(args)
+	if maxShots <= 0 || format != "table" && format != "csv" && format != "chart" {
+		flags.Usage()
+		os.Exit(2)
+	}
+	pools, err := previewPools(readConfigSettings(flags.Args()))
+	if err != nil {
+		exitOnError(err)
+	}
+	var timelines []engine.PoolTimeline
+	for _, pool := range pools {
+		timeline, err := engine.PlanPool(pool, maxShots)
+		if err != nil {
+			exitOnError(errors.WithMessage(err, fmt.Sprintf("%q pool plan failed", pool.ID)))
+		}
+		timelines = append(timelines, timeline)
+	}
+	if format == "csv" {
+		err = writeTimelinesCSV(os.Stdout, timelines)
+		if err != nil {
+			exitOnError(err)
+		}
+		return
+	}
+	for i := range timelines {
+		if i > 0 {
+			fmt.Println()
+		}
+		t := &timelines[i]
+		writeTimelineSummary(os.Stdout, t)
+		if format == "chart" {
+			if t.Shots != nil {
+				writeChart(os.Stdout, "rps", t.Shots)
+			}
+			writeChart(os.Stdout, "instances", t.Instances)
+			continue
+		}
+		writeTimelineTable(os.Stdout, t)
+	}
+}
+
+// previewPools decodes rps and startup schedules of pools from config settings.
+// Other pool keys are dropped, so they are not required and their plugins are not created.
+func previewPools(settings map[string]interface{}) ([]engine.InstancePoolConfig, error) {
+	poolsSettings, ok := settings["pools"].([]interface{})
+	if !ok {
+		return nil, errors.New("config has no pools")
+	}
+	var pools []engine.InstancePoolConfig
+	for i, poolSettings := range poolsSettings {
+		filtered := map[string]interface{}{}
+		for _, key := range previewPoolKeys {
+			value, ok := lookupSetting(poolSettings, key)
+			if ok {
+				filtered[key] = value
+			}
+		}
+		var pool engine.InstancePoolConfig
+		err := config.Decode(filtered, &pool)
+		if err != nil {
+			return nil, errors.WithMessage(err, fmt.Sprintf("pool %v decode failed", i))
+		}
+		if pool.ID == "" {
+			pool.ID = fmt.Sprintf("pool_%v", i)
+		}
+		pools = append(pools, pool)
+	}
+	return pools, nil
+}
+
+func lookupSetting(settings interface{}, key string) (interface{}, bool) {
+	switch settings := settings.(type) {
+	case map[string]interface{}:
+		value, ok := settings[key]
+		return value, ok
+	case map[interface{}]interface{}:
+		value, ok := settings[key]
+		return value, ok
+	}
+	return nil, false
+}
+
+func writeTimelineSummary(w io.Writer, t *engine.PoolTimeline) {
+	fmt.Fprintf(w, "Pool %q\n", t.ID)
+	if t.Shots == nil {
+		fmt.Fprintf(w, "Shots: closed loop, not planned\n")
+	} else {
+		fmt.Fprintf(w, "Shots: %d\n", t.TotalShots())
+	}
+	var instances int
+	if len(t.Instances) > 0 {
+		instances = t.Instances[len(t.Instances)-1]
+	}
+	fmt.Fprintf(w, "Instances: %d\n", instances)
+	fmt.Fprintf(w, "Duration: %v\n", t.Duration.Round(time.Millisecond))
+	if t.Truncated {
+		fmt.Fprintf(w, "WARNING: truncated by max shots limit\n")
+	}
+}
+
+func writeTimelineTable(w io.Writer, t *engine.PoolTimeline) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "second\trps\tinstances\t\n")
+	for i := range t.Instances {
+		fmt.Fprintf(tw, "%d\t%s\t%d\t\n", i, timelineShots(t, i), t.Instances[i])
+	}
+	_ = tw.Flush()
+}
+
+func writeTimelinesCSV(w io.Writer, timelines []engine.PoolTimeline) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"pool", "second", "rps", "instances"})
+	for i := range timelines {
+		t := &timelines[i]
+		for j := range t.Instances {
+			_ = cw.Write([]string{t.ID, strconv.Itoa(j), timelineShots(t, j), strconv.Itoa(t.Instances[j])})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func timelineShots(t *engine.PoolTimeline, i int) string {
+	if t.Shots == nil {
+		return "-"
+	}
+	return strconv.Itoa(t.Shots[i])
+}
+
+// writeChart writes ASCII bar chart of per second values. Long series are squeezed:
+// every column shows maximum of several seconds.
+func writeChart(w io.Writer, name string, values []int) {
+	const height, width = 10, 60
+	secondsPerColumn := (len(values) + width - 1) / width
+	if secondsPerColumn == 0 {
+		secondsPerColumn = 1
+	}
+	var columns []int
+	maxValue := 0
+	for i := 0; i < len(values); i += secondsPerColumn {
+		column := 0
+		for j := i; j < i+secondsPerColumn && j < len(values); j++ {
+			if values[j] > column {
+				column = values[j]
+			}
+		}
+		columns = append(columns, column)
+		if column > maxValue {
+			maxValue = column
+		}
+	}
+	label := strconv.Itoa(maxValue)
+	fmt.Fprintf(w, "%s:\n", name)
+	for row := height; row > 0; row-- {
+		rowLabel := ""
+		if row == height {
+			rowLabel = label
+		}
+		var line strings.Builder
+		for _, column := range columns {
+			// Bar height is value scaled to chart height and rounded up, so non zero values are visible.
+			if column*height > (row-1)*maxValue && column > 0 {
+				line.WriteByte('#')
+			} else {
+				line.WriteByte(' ')
+			}
+		}
+		fmt.Fprintf(w, "%*s |%s\n", len(label), rowLabel, strings.TrimRight(line.String(), " "))
+	}
+	fmt.Fprintf(w, "%*s +%s\n", len(label), "0", strings.Repeat("-", len(columns)))
+	end := fmt.Sprintf("%ds", len(values))
+	padding := len(columns) - len("0s") - len(end)
+	if padding < 1 {
+		padding = 1
+	}
+	fmt.Fprintf(w, "%*s  0s%s%s\n", len(label), "", strings.Repeat(" ", padding), end)
+	if secondsPerColumn > 1 {
+		fmt.Fprintf(w, "%*s  column is max of %d seconds\n", len(label), "", secondsPerColumn)
+	}
+}
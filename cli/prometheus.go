@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"net/http"
+
+	"github.com/yandex/pandora/core/engine"
+	"github.com/yandex/pandora/core/prometheus"
+)
+
+type prometheusConfig struct {
+	Enabled bool   `config:"enabled"`
+	Path    string `config:"path" validate:"required"`
+
+	prometheus.CollectorConfig `config:",squash"`
+}
+
+func defaultPrometheusConfig() *prometheusConfig {
+	return &prometheusConfig{
+		Path:            "/metrics",
+		CollectorConfig: prometheus.DefaultCollectorConfig(),
+	}
+}
+
+// registerPrometheusHandler registers handler exposing engine metrics and live stats of samples
+// of all pools in Prometheus text format. Should be called before engine run.
+func registerPrometheusHandler(mux *http.ServeMux, conf *prometheusConfig, m engine.Metrics, pandora *engine.Engine) {
+	samples := prometheus.NewSampleCollector(conf.CollectorConfig)
+	pandora.AddSampleObserver(samples)
+	mux.Handle(conf.Path, prometheus.NewHandler(m, pandora.PoolsStatus, samples))
+}
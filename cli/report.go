@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/yandex/pandora/core/aggregator/netsample"
+	"github.com/yandex/pandora/core/engine"
+	"github.com/yandex/pandora/core/report"
+	"github.com/yandex/pandora/lib/errutil"
+)
+
+// maxPlannedShots limits load profile planning, so infinite schedules don't hang report generation.
+const maxPlannedShots = 100 * 1000 * 1000
+
+// runReport runs 'pandora report' subcommand, that builds HTML report from phout or jsonlines results.
+func runReport(args []string) {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of Pandora report: pandora report [flags] <results_file>...\n"+
+			"Results files are written by phout or jsonlines aggregators. '-' means standard input.\n")
+		flags.PrintDefaults()
+	}
+	var (
+		output     string
+		title      string
+		configFile string
+	)
+	flags.StringVar(&output, "o", "report.html", "output HTML file")
+	flags.StringVar(&title, "title", "", "report title (results file names by default)")
+	flags.StringVar(&configFile, "config", "", "pandora config, which load profile is shown on RPS chart")
+	_ = flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if title == "" {
+		title = "Pandora report: " + strings.Join(flags.Args(), ", ")
+	}
+	err := buildReport(flags.Args(), configFile, output, title)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Report build failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Report has been written to %s\n", output)
+}
+
+func buildReport(inputs []string, configFile, output, title string) (err error) {
+	b := report.NewBuilder(report.DefaultConfig())
+	for _, input := range inputs {
+		err := readResults(input, b.Add)
+		if err != nil {
+			return errors.WithMessage(err, input)
+		}
+	}
+	if configFile != "" {
+		planned, err := readPlannedRPS(configFile)
+		if err != nil {
+			return errors.WithMessage(err, configFile)
+		}
+		for _, p := range planned {
+			b.AddPlanned(p)
+		}
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errutil.Join(err, f.Close())
+	}()
+	return report.WriteHTML(f, b.Build(title))
+}
+
+func readResults(input string, fn func(s *netsample.Sample)) error {
+	if input == stdinConfigSelector {
+		return report.Read(os.Stdin, fn)
+	}
+	f, err := os.Open(filepath.Clean(input))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return report.Read(f, fn)
+}
+
+// readPlannedRPS returns planned shots per second since engine start of every pool in config,
+// that is not closed-loop. Pools are planned as in 'pandora preview', so guns and providers
+// are not created.
+func readPlannedRPS(configFile string) ([][]float64, error) {
+	v := viper.New()
+	v.SetConfigFile(configFile)
+	err := v.ReadInConfig()
+	if err != nil {
+		return nil, err
+	}
+	pools, err := previewPools(v.AllSettings())
+	if err != nil {
+		return nil, errors.WithMessage(err, "load profile decode failed")
+	}
+	var planned [][]float64
+	for _, pool := range pools {
+		timeline, err := engine.PlanPool(pool, maxPlannedShots)
+		if err != nil {
+			return nil, errors.WithMessage(err, fmt.Sprintf("%q pool plan failed", pool.ID))
+		}
+		if timeline.Shots == nil {
+			continue // Closed-loop pool RPS is not planned.
+		}
+		perSecond := make([]float64, len(timeline.Shots))
+		for i, shots := range timeline.Shots {
+			perSecond[i] = float64(shots)
+		}
+		planned = append(planned, perSecond)
+	}
+	return planned, nil
+}
@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coreimport "github.com/yandex/pandora/core/import"
+)
+
+func TestReadPlannedRPS(t *testing.T) {
+	coreimport.Import(afero.NewMemMapFs())
+	configFile := filepath.Join(t.TempDir(), "load.yaml")
+	err := os.WriteFile(configFile, []byte(`
+pools:
+  - id: closed
+    closed-loop: {duration: 10s}
+    startup: {type: once, times: 2}
+  - id: per-instance
+    rps-per-instance: true
+    rps: {type: const, ops: 2, duration: 2s}
+    startup: {type: const, ops: 1, duration: 2s}
+    start-delay: 1s
+`), 0644)
+	require.NoError(t, err)
+	planned, err := readPlannedRPS(configFile)
+	require.NoError(t, err)
+	// Instances start at 1s and 2s, and shoot 2 RPS for 2s each.
+	assert.Equal(t, [][]float64{{0, 2, 4, 2}}, planned)
+}
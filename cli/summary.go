@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	"github.com/yandex/pandora/core/aggregator"
+	"github.com/yandex/pandora/core/autostop"
+	"github.com/yandex/pandora/core/engine"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Exit codes of pandora run, so CI can tell fail reason.
+// Code 2 is used on invalid command line flags.
+const (
+	exitCodeFailed         = 1 // Gun, instance or other failures.
+	exitCodeConfig         = 3
+	exitCodeProvider       = 4
+	exitCodeAggregator     = 5
+	exitCodeSamplesDropped = 6
+	exitCodeAutostop       = 7
+	// exitCodeInterrupted is exit code of run, that has been drained on signal. It is 128 + SIGINT,
+	// like in shells.
+	exitCodeInterrupted = 130
+)
+
+func exitCode(res runResult) int {
+	err := res.err
+	if err == nil {
+		if res.interrupted {
+			return exitCodeInterrupted
+		}
+		return 0
+	}
+	var (
+		drainErr      *drainError
+		autostopErr   *autostop.Error
+		providerErr   *engine.ProviderError
+		aggregatorErr *engine.AggregatorError
+	)
+	switch {
+	case errors.As(err, &drainErr):
+		// Shoots in flight are canceled, but run is stopped by signal anyway.
+		return exitCodeInterrupted
+	case errors.As(err, &autostopErr):
+		return exitCodeAutostop
+	case errors.As(err, &providerErr):
+		return exitCodeProvider
+	case errors.As(err, &aggregatorErr):
+		// Other aggregator fails are more important, than dropped samples.
+		if onlySamplesDropped(aggregatorErr.Err) {
+			return exitCodeSamplesDropped
+		}
+		return exitCodeAggregator
+	}
+	return exitCodeFailed
+}
+
+// onlySamplesDropped returns true, if err is aggregator.SomeSamplesDropped, or all errors joined
+// by errutil.Join are.
+func onlySamplesDropped(err error) bool {
+	var multi *multierror.Error
+	if errors.As(err, &multi) {
+		for _, err := range multi.Errors {
+			if !onlySamplesDropped(err) {
+				return false
+			}
+		}
+		return len(multi.Errors) > 0
+	}
+	var dropped *aggregator.SomeSamplesDropped
+	return errors.As(err, &dropped)
+}
+
+// exitOnFatal is zap fatal hook, that exits with code.
+type exitOnFatal int
+
+func (code exitOnFatal) OnWrite(*zapcore.CheckedEntry, []zapcore.Field) {
+	os.Exit(int(code))
+}
+
+type runSummaryConfig struct {
+	// File is path of JSON run summary, that is written at the end of run. Summary is not written, if empty.
+	File string `config:"file"`
+}
+
+// runSummary is machine-readable result of pandora run.
+type runSummary struct {
+	Version  string              `json:"version"`
+	Status   string              `json:"status"` // success, interrupted or failed.
+	ExitCode int                 `json:"exit_code"`
+	Start    time.Time           `json:"start"`
+	Finish   time.Time           `json:"finish"`
+	Duration float64             `json:"duration_seconds"`
+	Shots    int64               `json:"shots"`
+	Pools    []engine.PoolStatus `json:"pools"`
+	Errors   []string            `json:"errors,omitempty"`
+}
+
+func newRunSummary(res runResult, code int, m engine.Metrics, pools []engine.PoolStatus) runSummary {
+	summary := runSummary{
+		Version:  Version,
+		Status:   "success",
+		ExitCode: code,
+		Start:    res.start,
+		Finish:   res.finish,
+		Duration: res.finish.Sub(res.start).Seconds(),
+		Shots:    m.Response.Get(),
+		Pools:    pools,
+	}
+	var drainErr *drainError
+	switch {
+	case errors.As(res.err, &drainErr):
+		summary.Status = "interrupted"
+		summary.Errors = append(summary.Errors, res.err.Error())
+	case res.err != nil:
+		summary.Status = "failed"
+		summary.Errors = append(summary.Errors, res.err.Error())
+	case res.interrupted:
+		summary.Status = "interrupted"
+	}
+	return summary
+}
+
+func writeRunSummary(path string, summary runSummary) error {
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(path, append(data, '\n'), 0644)
+	if err != nil {
+		return err
+	}
+	zap.L().Info("Run summary written", zap.String("file", path))
+	return nil
+}
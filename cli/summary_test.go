@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/yandex/pandora/core/aggregator"
+	"github.com/yandex/pandora/core/autostop"
+	"github.com/yandex/pandora/core/engine"
+	"github.com/yandex/pandora/lib/errutil"
+)
+
+func TestExitCode(t *testing.T) {
+	dropped := &aggregator.SomeSamplesDropped{Dropped: 10}
+	aggregatorErr := func(err error) error {
+		return errors.WithMessage(&engine.AggregatorError{Err: err}, "aggregator failed")
+	}
+	tests := []struct {
+		name string
+		res  runResult
+		code int
+	}{
+		{"success", runResult{}, 0},
+		{"interrupted", runResult{interrupted: true}, exitCodeInterrupted},
+		{"failed", runResult{err: errors.New("gun failed")}, exitCodeFailed},
+		{"interrupted with fail", runResult{err: errors.New("drain failed"), interrupted: true}, exitCodeFailed},
+		{"drain timeout", runResult{err: &drainError{"drain timeout 10s exceeded"}, interrupted: true}, exitCodeInterrupted},
+		{"autostop", runResult{err: &autostop.Error{Err: errors.New("5xx exceeded 10%")}}, exitCodeAutostop},
+		{"provider", runResult{err: errors.WithMessage(&engine.ProviderError{Err: context.DeadlineExceeded}, "provider failed")}, exitCodeProvider},
+		{"aggregator", runResult{err: aggregatorErr(errors.New("write failed"))}, exitCodeAggregator},
+		{"samples dropped", runResult{err: aggregatorErr(errors.WithStack(dropped))}, exitCodeSamplesDropped},
+		{"joined samples dropped", runResult{err: aggregatorErr(errutil.Join(dropped, errors.WithMessage(dropped, "push")))}, exitCodeSamplesDropped},
+		{"joined samples dropped and fail", runResult{err: aggregatorErr(errutil.Join(dropped, errors.New("write failed")))}, exitCodeAggregator},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.code, exitCode(test.res))
+		})
+	}
+}
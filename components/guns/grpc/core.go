@@ -149,6 +149,7 @@ func (g *Gun) Shoot(am core.Ammo) {
 func (g *Gun) shoot(ammo *ammo.Ammo) {
 	code := 0
 	sample := netsample.Acquire(ammo.Tag)
+	sample.SetScheduledTime(ammo.ScheduledTime())
 	defer func() {
 		sample.SetProtoCode(code)
 		g.aggr.Report(sample)
@@ -215,6 +215,23 @@ func (b *BaseGun) Shoot(ammo Ammo) {
 	}
 }
 
+// DryShoot builds and tags request like Shoot, but doesn't send it.
+func (b *BaseGun) DryShoot(ammo Ammo) ([]string, error) {
+	req, sample := ammo.Request()
+	// Shoot releases sample by reporting it to Aggregator, but dry-run sample is not reported.
+	defer netsample.Release(sample)
+	if ammo.IsInvalid() {
+		return []string{EmptyTag}, errors.New("invalid ammo")
+	}
+	if b.Config.AutoTag.Enabled && (!b.Config.AutoTag.NoTagOnly || sample.Tags() == "") {
+		sample.AddTag(autotag(b.Config.AutoTag.URIElements, req.URL))
+	}
+	if sample.Tags() == "" {
+		sample.AddTag(EmptyTag)
+	}
+	return []string{sample.Tags()}, nil
+}
+
 func (b *BaseGun) Close() error {
 	if b.OnClose != nil {
 		return b.OnClose()
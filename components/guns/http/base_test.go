@@ -190,6 +190,41 @@ var _ = Describe("BaseGun", func() {
 		})
 	})
 
+	Context("DryShoot", func() {
+		var am *ammomock.Ammo
+		BeforeEach(func() {
+			base.Config.AutoTag.Enabled = true
+			base.Do = func(*http.Request) (_ *http.Response, _ error) {
+				Fail("should not be called")
+				return
+			}
+			am = ammomock.NewAmmo(GinkgoT())
+			am.On("Request").Return(httptest.NewRequest("GET", "/1/2/3/4", nil), netsample.Acquire(""))
+		})
+
+		It("autotagged", func() {
+			am.On("IsInvalid").Return(false)
+			tags, err := base.DryShoot(am)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tags).To(Equal([]string{"/1/2"}))
+		})
+
+		It("empty tag", func() {
+			base.Config.AutoTag.Enabled = false
+			am.On("IsInvalid").Return(false)
+			tags, err := base.DryShoot(am)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tags).To(Equal([]string{EmptyTag}))
+		})
+
+		It("invalid ammo failed", func() {
+			am.On("IsInvalid").Return(true)
+			tags, err := base.DryShoot(am)
+			Expect(err).To(HaveOccurred())
+			Expect(tags).To(Equal([]string{EmptyTag}))
+		})
+	})
+
 	DescribeTable("autotag",
 		func(path string, depth int, tag string) {
 			URL := &url.URL{Path: path}
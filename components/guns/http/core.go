@@ -10,6 +10,7 @@ import (
 
 	"github.com/yandex/pandora/core"
 	"github.com/yandex/pandora/core/aggregator/netsample"
+	"github.com/yandex/pandora/core/lifecycle"
 )
 
 //go:generate mockery --name=Ammo --case=underscore --outpkg=ammomock
@@ -31,19 +32,59 @@ type Gun interface {
 	Bind(sample netsample.Aggregator, deps core.GunDeps) error
 }
 
+// TypedGun is gun with ammo of type A, that reports netsample samples. Gun and scenario gun are TypedGun.
+type TypedGun[A any] interface {
+	Shoot(ammo A)
+	Bind(sample netsample.Aggregator, deps core.GunDeps) error
+}
+
+// TypedDryRunGun is TypedGun, that can prepare shoot without sending it. See dryrun.Gun.
+type TypedDryRunGun[A any] interface {
+	TypedGun[A]
+	DryShoot(ammo A) (tags []string, err error)
+}
+
+// DryRunGun is Gun, that can prepare shoot without sending it.
+type DryRunGun = TypedDryRunGun[Ammo]
+
 func WrapGun(g Gun) core.Gun {
 	if g == nil {
 		return nil
 	}
-	return &gunWrapper{g}
+	return WrapTypedGun[Ammo](g)
 }
 
-type gunWrapper struct{ Gun }
+// WrapTypedGun adapts TypedGun to core.Gun. Wrapper implements dryrun.Gun, if g is TypedDryRunGun,
+// and calls lifecycle hooks of g, if it implements them.
+func WrapTypedGun[A any](g TypedGun[A]) core.Gun {
+	if g == nil {
+		return nil
+	}
+	w := gunWrapper[A]{g, lifecycle.GunHooks{Gun: g}}
+	if dg, ok := g.(TypedDryRunGun[A]); ok {
+		return &dryRunGunWrapper[A]{w, dg}
+	}
+	return &w
+}
+
+type gunWrapper[A any] struct {
+	gun TypedGun[A]
+	lifecycle.GunHooks
+}
+
+type dryRunGunWrapper[A any] struct {
+	gunWrapper[A]
+	dryRunGun TypedDryRunGun[A]
+}
+
+func (g *dryRunGunWrapper[A]) DryShoot(ammo core.Ammo) ([]string, error) {
+	return g.dryRunGun.DryShoot(ammo.(A))
+}
 
-func (g *gunWrapper) Shoot(ammo core.Ammo) {
-	g.Gun.Shoot(ammo.(Ammo))
+func (g *gunWrapper[A]) Shoot(ammo core.Ammo) {
+	g.gun.Shoot(ammo.(A))
 }
 
-func (g *gunWrapper) Bind(a core.Aggregator, deps core.GunDeps) error {
-	return g.Gun.Bind(netsample.UnwrapAggregator(a), deps)
+func (g *gunWrapper[A]) Bind(a core.Aggregator, deps core.GunDeps) error {
+	return g.gun.Bind(netsample.UnwrapAggregator(a), deps)
 }
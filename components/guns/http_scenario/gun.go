@@ -78,7 +78,8 @@ func (g *BaseGun) Shoot(ammo Ammo) {
 	}
 
 	templateVars := map[string]any{
-		"source": ammo.Sources().Variables(),
+		"source":   ammo.Sources().Variables(),
+		"instance": g.instanceVars(),
 	}
 
 	err := g.shoot(ammo, templateVars)
@@ -88,6 +89,14 @@ func (g *BaseGun) Shoot(ammo Ammo) {
 	}
 }
 
+// instanceVars returns instance state values, that are available in templates as 'instance'.
+func (g *BaseGun) instanceVars() map[string]any {
+	if g.State == nil {
+		return map[string]any{}
+	}
+	return g.State.Values()
+}
+
 func (g *BaseGun) Do(req *http.Request) (*http.Response, error) {
 	return g.client.Do(req)
 }
@@ -110,10 +119,14 @@ func (g *BaseGun) shoot(ammo Ammo, templateVars map[string]any) error {
 	startAt := time.Now()
 	var idBuilder strings.Builder
 	rnd := strconv.Itoa(rand.Int())
-	for _, step := range ammo.Steps() {
+	for i, step := range ammo.Steps() {
 		tag := ammo.Name() + "." + step.GetTag()
 		g.buildLogID(&idBuilder, tag, ammo.ID(), rnd)
 		sample := netsample.Acquire(tag)
+		// Only the first step is scheduled. Next steps start, when previous are finished.
+		if scheduled, ok := ammo.(core.ScheduledAmmo); ok && i == 0 {
+			sample.SetScheduledTime(scheduled.ScheduledTime())
+		}
 
 		err := g.shootStep(step, sample, ammo.Name(), templateVars, requestVars, idBuilder.String())
 		if err != nil {
@@ -128,43 +141,36 @@ func (g *BaseGun) shoot(ammo Ammo, templateVars map[string]any) error {
 	return nil
 }
 
-func (g *BaseGun) shootStep(step Step, sample *netsample.Sample, ammoName string, templateVars map[string]any, requestVars map[string]any, stepLogID string) error {
-	const op = "base_gun.shootStep"
-
-	stepVars := map[string]any{}
-	requestVars[step.GetName()] = stepVars
-
-	// Preprocessor
-	preProcessor := step.Preprocessor()
-	if preProcessor != nil {
-		preProcVars, err := preProcessor.Process(templateVars)
+// DryShoot templates and builds requests of all ammo steps like Shoot, but doesn't send them.
+// Postprocessors are not run, so their variables are not available in templates.
+func (g *BaseGun) DryShoot(ammo Ammo) (tags []string, err error) {
+	requestVars := map[string]any{}
+	templateVars := map[string]any{
+		"source":   ammo.Sources().Variables(),
+		"request":  requestVars,
+		"instance": g.instanceVars(),
+	}
+	for _, step := range ammo.Steps() {
+		stepVars := map[string]any{}
+		requestVars[step.GetName()] = stepVars
+		_, err := g.prepareStep(step, ammo.Name(), templateVars, stepVars)
 		if err != nil {
-			return fmt.Errorf("%s preProcessor %w", op, err)
-		}
-		stepVars["preprocessor"] = preProcVars
-		if g.DebugLog {
-			g.GunDeps.Log.Debug("Preprocessor variables", zap.Any(fmt.Sprintf(".resuest.%s.preprocessor", step.GetName()), preProcVars))
+			return append(tags, EmptyTag), err
 		}
+		tags = append(tags, ammo.Name()+"."+step.GetTag())
 	}
+	return tags, nil
+}
 
-	// Entities
-	reqParts := RequestParts{
-		URL:     step.GetURL(),
-		Method:  step.GetMethod(),
-		Body:    step.GetBody(),
-		Headers: step.GetHeaders(),
-	}
+func (g *BaseGun) shootStep(step Step, sample *netsample.Sample, ammoName string, templateVars map[string]any, requestVars map[string]any, stepLogID string) error {
+	const op = "base_gun.shootStep"
 
-	// Template
-	templater := step.GetTemplater()
-	if err := templater.Apply(&reqParts, templateVars, ammoName, step.GetName()); err != nil {
-		return fmt.Errorf("%s templater.Apply %w", op, err)
-	}
+	stepVars := map[string]any{}
+	requestVars[step.GetName()] = stepVars
 
-	// Prepare request
-	req, err := g.prepareRequest(reqParts)
+	req, err := g.prepareStep(step, ammoName, templateVars, stepVars)
 	if err != nil {
-		return fmt.Errorf("%s prepareRequest %w", op, err)
+		return fmt.Errorf("%s %w", op, err)
 	}
 
 	var reqBytes []byte
@@ -246,6 +252,43 @@ func (g *BaseGun) shootStep(step Step, sample *netsample.Sample, ammoName string
 	return nil
 }
 
+// prepareStep runs step preprocessor, applies templater and builds request.
+func (g *BaseGun) prepareStep(step Step, ammoName string, templateVars map[string]any, stepVars map[string]any) (*http.Request, error) {
+	// Preprocessor
+	preProcessor := step.Preprocessor()
+	if preProcessor != nil {
+		preProcVars, err := preProcessor.Process(templateVars)
+		if err != nil {
+			return nil, fmt.Errorf("preProcessor %w", err)
+		}
+		stepVars["preprocessor"] = preProcVars
+		if g.DebugLog {
+			g.GunDeps.Log.Debug("Preprocessor variables", zap.Any(fmt.Sprintf(".resuest.%s.preprocessor", step.GetName()), preProcVars))
+		}
+	}
+
+	// Entities
+	reqParts := RequestParts{
+		URL:     step.GetURL(),
+		Method:  step.GetMethod(),
+		Body:    step.GetBody(),
+		Headers: step.GetHeaders(),
+	}
+
+	// Template
+	templater := step.GetTemplater()
+	if err := templater.Apply(&reqParts, templateVars, ammoName, step.GetName()); err != nil {
+		return nil, fmt.Errorf("templater.Apply %w", err)
+	}
+
+	// Prepare request
+	req, err := g.prepareRequest(reqParts)
+	if err != nil {
+		return nil, fmt.Errorf("prepareRequest %w", err)
+	}
+	return req, nil
+}
+
 func (g *BaseGun) buildLogID(idBuilder *strings.Builder, tag string, ammoID uint64, rnd string) {
 	idBuilder.Reset()
 	idBuilder.WriteString(tag)
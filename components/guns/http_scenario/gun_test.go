@@ -209,3 +209,119 @@ func commonStepMocks(t *testing.T, step *MockStep, name, tag, url, method string
 	step.On("GetName").Return(name).Times(2)
 	step.On("GetSleep").Return(time.Duration(0)).Times(1)
 }
+
+type testVariables map[string]any
+
+func (v testVariables) Variables() map[string]any { return v }
+
+func TestBaseGun_DryShoot(t *testing.T) {
+	templaterErr := fmt.Errorf("template error")
+	tests := []struct {
+		name      string
+		stepMocks []func(t *testing.T, m *MockStep)
+		wantTags  []string
+		wantErr   assert.ErrorAssertionFunc
+	}{
+		{
+			name: "default",
+			stepMocks: []func(t *testing.T, m *MockStep){
+				func(t *testing.T, step *MockStep) {
+					templater := NewMockTemplater(t)
+					templater.On("Apply", mock.Anything, mock.Anything, "testAmmo", "step 1").Return(nil)
+					step.On("Preprocessor").Return(nil)
+					step.On("GetURL").Return("http://localhost:8080")
+					step.On("GetMethod").Return("GET")
+					step.On("GetBody").Return(nil)
+					step.On("GetHeaders").Return(map[string]string{})
+					step.On("GetTemplater").Return(templater)
+					step.On("GetName").Return("step 1")
+					step.On("GetTag").Return("tag1")
+				},
+				func(t *testing.T, step *MockStep) {
+					templater := NewMockTemplater(t)
+					templater.On("Apply", mock.Anything, mock.Anything, "testAmmo", "step 2").Return(nil)
+					step.On("Preprocessor").Return(nil)
+					step.On("GetURL").Return("http://localhost:8080")
+					step.On("GetMethod").Return("POST")
+					step.On("GetBody").Return([]byte("body"))
+					step.On("GetHeaders").Return(map[string]string{})
+					step.On("GetTemplater").Return(templater)
+					step.On("GetName").Return("step 2")
+					step.On("GetTag").Return("tag2")
+				},
+			},
+			wantTags: []string{"testAmmo.tag1", "testAmmo.tag2"},
+			wantErr:  assert.NoError,
+		},
+		{
+			name: "templater failed",
+			stepMocks: []func(t *testing.T, m *MockStep){
+				func(t *testing.T, step *MockStep) {
+					templater := NewMockTemplater(t)
+					templater.On("Apply", mock.Anything, mock.Anything, "testAmmo", "step 1").Return(templaterErr)
+					step.On("Preprocessor").Return(nil)
+					step.On("GetURL").Return("http://localhost:8080")
+					step.On("GetMethod").Return("GET")
+					step.On("GetBody").Return(nil)
+					step.On("GetHeaders").Return(map[string]string{})
+					step.On("GetTemplater").Return(templater)
+					step.On("GetName").Return("step 1")
+				},
+				func(t *testing.T, step *MockStep) {},
+			},
+			wantTags: []string{EmptyTag},
+			wantErr: func(t assert.TestingT, err error, _ ...interface{}) bool {
+				return assert.ErrorIs(t, err, templaterErr)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps := make([]Step, 0, len(tt.stepMocks))
+			for _, step := range tt.stepMocks {
+				st := NewMockStep(t)
+				step(t, st)
+				steps = append(steps, st)
+			}
+
+			ammo := NewMockAmmo(t)
+			ammo.On("Steps").Return(steps)
+			ammo.On("Name").Return("testAmmo")
+			ammo.On("Sources").Return(testVariables{})
+
+			// Client without expectations fails test, if request is sent.
+			g := &BaseGun{client: NewMockClient(t)}
+			tags, err := g.DryShoot(ammo)
+			tt.wantErr(t, err)
+			assert.Equal(t, tt.wantTags, tags)
+		})
+	}
+}
+
+func TestBaseGun_DryShoot_instanceState(t *testing.T) {
+	templater := NewMockTemplater(t)
+	templater.On("Apply", mock.Anything, mock.MatchedBy(func(vars map[string]any) bool {
+		return assert.ObjectsAreEqual(map[string]any{"token": "secret"}, vars["instance"])
+	}), "testAmmo", "step 1").Return(nil)
+	step := NewMockStep(t)
+	step.On("Preprocessor").Return(nil)
+	step.On("GetURL").Return("http://localhost:8080")
+	step.On("GetMethod").Return("GET")
+	step.On("GetBody").Return(nil)
+	step.On("GetHeaders").Return(map[string]string{})
+	step.On("GetTemplater").Return(templater)
+	step.On("GetName").Return("step 1")
+	step.On("GetTag").Return("tag1")
+
+	ammo := NewMockAmmo(t)
+	ammo.On("Steps").Return([]Step{step})
+	ammo.On("Name").Return("testAmmo")
+	ammo.On("Sources").Return(testVariables{})
+
+	state := core.NewInstanceState()
+	core.NewStateKey[string]("token").Set(state, "secret")
+	g := &BaseGun{client: NewMockClient(t), GunDeps: core.GunDeps{State: state}}
+	tags, err := g.DryShoot(ammo)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"testAmmo.tag1"}, tags)
+}
@@ -6,7 +6,6 @@ import (
 	"github.com/spf13/afero"
 	phttp "github.com/yandex/pandora/components/guns/http"
 	"github.com/yandex/pandora/core"
-	"github.com/yandex/pandora/core/aggregator/netsample"
 	"github.com/yandex/pandora/core/register"
 	"github.com/yandex/pandora/lib/answlog"
 	"github.com/yandex/pandora/lib/netutil"
@@ -17,19 +16,7 @@ func WrapGun(g Gun) core.Gun {
 	if g == nil {
 		return nil
 	}
-	return &gunWrapper{Gun: g}
-}
-
-type gunWrapper struct {
-	Gun Gun
-}
-
-func (g *gunWrapper) Shoot(ammo core.Ammo) {
-	g.Gun.Shoot(ammo.(Ammo))
-}
-
-func (g *gunWrapper) Bind(a core.Aggregator, deps core.GunDeps) error {
-	return g.Gun.Bind(netsample.UnwrapAggregator(a), deps)
+	return phttp.WrapTypedGun[Ammo](g)
 }
 
 func Import(fs afero.Fs) {
@@ -0,0 +1,51 @@
+package httpscenario
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yandex/pandora/core"
+	"github.com/yandex/pandora/core/aggregator/netsample"
+	"github.com/yandex/pandora/core/lifecycle"
+)
+
+type testLifecycleGun struct {
+	started, stopped int
+}
+
+func (g *testLifecycleGun) Shoot(Ammo) {}
+
+func (g *testLifecycleGun) Bind(netsample.Aggregator, core.GunDeps) error { return nil }
+
+func (g *testLifecycleGun) OnInstanceStart(lifecycle.InstanceDeps) error {
+	g.started++
+	return errors.New("login failed")
+}
+
+func (g *testLifecycleGun) OnInstanceStop(lifecycle.InstanceDeps) error {
+	g.stopped++
+	return nil
+}
+
+func TestWrapGun_lifecycleHooks(t *testing.T) {
+	gun := &testLifecycleGun{}
+	wrapped, ok := WrapGun(gun).(interface {
+		lifecycle.InstanceStarter
+		lifecycle.InstanceStopper
+	})
+	require.True(t, ok)
+	assert.EqualError(t, wrapped.OnInstanceStart(lifecycle.InstanceDeps{}), "login failed")
+	assert.NoError(t, wrapped.OnInstanceStop(lifecycle.InstanceDeps{}))
+	assert.Equal(t, 1, gun.started)
+	assert.Equal(t, 1, gun.stopped)
+
+	// Hooks of gun without them do nothing.
+	wrapped = WrapGun(&BaseGun{}).(interface {
+		lifecycle.InstanceStarter
+		lifecycle.InstanceStopper
+	})
+	assert.NoError(t, wrapped.OnInstanceStart(lifecycle.InstanceDeps{}))
+	assert.NoError(t, wrapped.OnInstanceStop(lifecycle.InstanceDeps{}))
+}
@@ -5,6 +5,8 @@
 
 package ammo
 
+import "time"
+
 type Ammo struct {
 	Tag       string                 `json:"tag"`
 	Call      string                 `json:"call"`
@@ -12,10 +14,11 @@ type Ammo struct {
 	Payload   map[string]interface{} `json:"payload"`
 	id        uint64
 	isInvalid bool
+	scheduled time.Time
 }
 
 func (a *Ammo) Reset(tag string, call string, metadata map[string]string, payload map[string]interface{}) {
-	*a = Ammo{tag, call, metadata, payload, 0, false}
+	*a = Ammo{Tag: tag, Call: call, Metadata: metadata, Payload: payload}
 }
 
 func (a *Ammo) SetID(id uint64) {
@@ -37,3 +40,11 @@ func (a *Ammo) IsInvalid() bool {
 func (a *Ammo) IsValid() bool {
 	return !a.isInvalid
 }
+
+func (a *Ammo) ScheduledTime() time.Time {
+	return a.scheduled
+}
+
+func (a *Ammo) SetScheduledTime(t time.Time) {
+	a.scheduled = t
+}
@@ -7,8 +7,10 @@ package ammo
 
 import (
 	"net/http"
+	"time"
 
 	phttp "github.com/yandex/pandora/components/guns/http"
+	"github.com/yandex/pandora/core"
 	"github.com/yandex/pandora/core/aggregator/netsample"
 )
 
@@ -16,7 +18,11 @@ type Request interface {
 	http.Request
 }
 
-var _ phttp.Ammo = (*GunAmmo)(nil)
+var (
+	_ phttp.Ammo         = (*GunAmmo)(nil)
+	_ phttp.Ammo         = (*ScheduledGunAmmo)(nil)
+	_ core.ScheduledAmmo = (*ScheduledGunAmmo)(nil)
+)
 
 type GunAmmo struct {
 	req       *http.Request
@@ -46,3 +52,28 @@ func NewGunAmmo(req *http.Request, tag string, id uint64) GunAmmo {
 		tag: tag,
 	}
 }
+
+// ScheduledGunAmmo is GunAmmo, that keeps time, when its shoot was scheduled, and sets it
+// to sample. Scheduled time is set by instance after Acquire, so methods have pointer receivers.
+type ScheduledGunAmmo struct {
+	GunAmmo
+	scheduled time.Time
+}
+
+func (g *ScheduledGunAmmo) Request() (*http.Request, *netsample.Sample) {
+	req, sample := g.GunAmmo.Request()
+	sample.SetScheduledTime(g.scheduled)
+	return req, sample
+}
+
+func (g *ScheduledGunAmmo) ScheduledTime() time.Time {
+	return g.scheduled
+}
+
+func (g *ScheduledGunAmmo) SetScheduledTime(t time.Time) {
+	g.scheduled = t
+}
+
+func NewScheduledGunAmmo(req *http.Request, tag string, id uint64) *ScheduledGunAmmo {
+	return &ScheduledGunAmmo{GunAmmo: NewGunAmmo(req, tag, id)}
+}
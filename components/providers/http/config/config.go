@@ -21,4 +21,8 @@ type Config struct {
 	ChosenCases []string
 	Middlewares []middleware.Middleware
 	Preload     bool
+	// Ordered makes ammo shot in the file order at pool RPS schedule times. For example, requests
+	// from access log are shot at their recorded times with 'replay' schedule. Ammo acquire is serialized.
+	// See core.OrderedProvider.
+	Ordered bool
 }
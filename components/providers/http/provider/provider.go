@@ -43,7 +43,7 @@ func (p *Provider) Acquire() (core.Ammo, bool) {
 			return ammo, false
 		}
 	}
-	return httpProvider.NewGunAmmo(req, ammo.Tag(), p.NextID()), ok
+	return httpProvider.NewScheduledGunAmmo(req, ammo.Tag(), p.NextID()), ok
 }
 
 func (p *Provider) Release(a core.Ammo) {
@@ -53,6 +53,11 @@ func (p *Provider) Release(a core.Ammo) {
 	p.Decoder.Release(a)
 }
 
+// AmmoOrdered implements core.OrderedProvider.
+func (p *Provider) AmmoOrdered() bool {
+	return p.Config.Ordered
+}
+
 func (p *Provider) Run(ctx context.Context, deps core.ProviderDeps) (err error) {
 	p.Deps = deps
 	defer func() {
@@ -171,4 +176,4 @@ func (p *Provider) runPreloaded(ctx context.Context) error {
 	}
 }
 
-var _ core.Provider = (*Provider)(nil)
+var _ core.OrderedProvider = (*Provider)(nil)
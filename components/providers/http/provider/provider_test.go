@@ -134,3 +134,10 @@ func TestLoadAmmo_ChosenTags(t *testing.T) {
 	assert.Len(t, provider.ammos, len(expectedAmmos))
 	assert.Equal(t, provider.ammos, expectedAmmos)
 }
+
+func TestProvider_AmmoOrdered(t *testing.T) {
+	p := &Provider{}
+	assert.False(t, p.AmmoOrdered())
+	p.Config.Ordered = true
+	assert.True(t, p.AmmoOrdered())
+}
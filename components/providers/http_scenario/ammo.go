@@ -14,6 +14,7 @@ type Ammo struct {
 	name            string
 	minWaitingTime  time.Duration
 	variableStorage *SourceStorage
+	scheduled       time.Time
 }
 
 func (a *Ammo) GetMinWaitingTime() time.Duration {
@@ -40,6 +41,14 @@ func (a *Ammo) Name() string {
 	return a.name
 }
 
+func (a *Ammo) ScheduledTime() time.Time {
+	return a.scheduled
+}
+
+func (a *Ammo) SetScheduledTime(t time.Time) {
+	a.scheduled = t
+}
+
 type Request struct {
 	method         string
 	headers        map[string]string
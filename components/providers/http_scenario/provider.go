@@ -145,7 +145,9 @@ func (p *Provider) Acquire() (core.Ammo, bool) {
 	if !ok {
 		return nil, false
 	}
-	return ammo, true
+	// Preloaded ammo is shared between instances, so it is copied to set scheduled time of shoot.
+	shoot := *ammo
+	return &shoot, true
 }
 
 func (p *Provider) Release(_ core.Ammo) {
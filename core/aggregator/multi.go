@@ -0,0 +1,99 @@
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/yandex/pandora/core"
+	"github.com/yandex/pandora/lib/errutil"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+)
+
+// ClonableSample is Sample, that can be copied. Sample reported to Aggregator may be reused
+// or returned to pool after handling, so Aggregator that reports Sample to several others
+// should give everyone its own copy.
+type ClonableSample interface {
+	core.Sample
+	CloneSample() core.Sample
+}
+
+type MultiConfig struct {
+	Aggregators []core.Aggregator `config:"aggregators" validate:"required,dive,required"`
+}
+
+// NewMulti returns Aggregator, that reports every Sample to all nested Aggregators.
+// Every nested Aggregator gets own copy of ClonableSample. BorrowedSample, that can't be cloned,
+// is reported only to the first Aggregator. Other Samples are reported to all as is, so they
+// should not be modified by Aggregators.
+func NewMulti(conf MultiConfig) core.Aggregator {
+	return &multi{aggregators: conf.Aggregators}
+}
+
+type multi struct {
+	aggregators []core.Aggregator
+	notCloned   atomic.Int64
+}
+
+func (m *multi) Report(s core.Sample) {
+	switch s := s.(type) {
+	case ClonableSample:
+		// Clone before report, because nested Aggregator may release Sample right after Report.
+		samples := make([]core.Sample, len(m.aggregators))
+		samples[0] = s
+		for i := 1; i < len(samples); i++ {
+			samples[i] = s.CloneSample()
+		}
+		for i, a := range m.aggregators {
+			a.Report(samples[i])
+		}
+	case core.BorrowedSample:
+		if len(m.aggregators) > 1 {
+			m.notCloned.Inc()
+		}
+		m.aggregators[0].Report(s)
+	default:
+		for _, a := range m.aggregators {
+			a.Report(s)
+		}
+	}
+}
+
+func (m *multi) Run(ctx context.Context, deps core.AggregatorDeps) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs error
+	)
+	for i, a := range m.aggregators {
+		i, a := i, a
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			aDeps := deps
+			if aDeps.Log != nil {
+				aDeps.Log = aDeps.Log.With(zap.Int("aggregator", i))
+			}
+			err := a.Run(ctx, aDeps)
+			if errutil.IsCtxError(ctx, err) {
+				// Finished normally, or canceled because of other aggregator fail.
+				return
+			}
+			// Aggregator failed. Stop others, so run is not blocked until pool finish.
+			cancel()
+			mu.Lock()
+			errs = errutil.Join(errs, errors.WithMessage(err, fmt.Sprintf("aggregator %d", i)))
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if n := m.notCloned.Load(); n > 0 && deps.Log != nil {
+		deps.Log.Warn("Samples can't be cloned, so they were reported only to the first aggregator",
+			zap.Int64("samples", n))
+	}
+	return errs
+}
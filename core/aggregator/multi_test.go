@@ -0,0 +1,66 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yandex/pandora/core"
+	coremock "github.com/yandex/pandora/core/mocks"
+	"go.uber.org/zap"
+)
+
+type clonableTestSample struct {
+	value  int
+	clones *int
+}
+
+func (s *clonableTestSample) CloneSample() core.Sample {
+	*s.clones++
+	c := *s
+	return &c
+}
+
+type failingAggregator struct{ err error }
+
+func (a failingAggregator) Run(context.Context, core.AggregatorDeps) error { return a.err }
+func (a failingAggregator) Report(core.Sample)                             {}
+
+func TestMultiReport(t *testing.T) {
+	first, second := NewTest(), NewTest()
+	testee := NewMulti(MultiConfig{Aggregators: []core.Aggregator{first, second}})
+
+	var clones int
+	clonable := &clonableTestSample{value: 1, clones: &clones}
+	testee.Report(clonable)
+	assert.Equal(t, 1, clones)
+	require.Len(t, first.GetSamples(), 1)
+	require.Len(t, second.GetSamples(), 1)
+	assert.Same(t, clonable, first.GetSamples()[0])
+	assert.NotSame(t, clonable, second.GetSamples()[0])
+	assert.Equal(t, clonable, second.GetSamples()[0])
+
+	borrowed := &coremock.BorrowedSample{}
+	testee.Report(borrowed)
+	assert.Len(t, first.GetSamples(), 2)
+	assert.Len(t, second.GetSamples(), 1)
+
+	testee.Report("plain")
+	assert.Equal(t, "plain", first.GetSamples()[2])
+	assert.Equal(t, "plain", second.GetSamples()[1])
+}
+
+func TestMultiRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	testee := NewMulti(MultiConfig{Aggregators: []core.Aggregator{NewTest(), failingAggregator{context.Canceled}}})
+	assert.NoError(t, testee.Run(ctx, core.AggregatorDeps{Log: zap.NewNop()}))
+
+	failErr := errors.New("fail")
+	testee = NewMulti(MultiConfig{Aggregators: []core.Aggregator{NewTest(), failingAggregator{failErr}}})
+	err := testee.Run(context.Background(), core.AggregatorDeps{Log: zap.NewNop()})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "aggregator 1: fail")
+}
@@ -0,0 +1,303 @@
+package netsample
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/yandex/pandora/core"
+	"github.com/yandex/pandora/core/aggregator"
+	"github.com/yandex/pandora/core/coreutil"
+	"github.com/yandex/pandora/lib/histogram"
+	"github.com/yandex/pandora/lib/ioutil2"
+)
+
+const (
+	BucketsFormatJSONLines = "jsonlines"
+	BucketsFormatCSV       = "csv"
+)
+
+type BucketsConfig struct {
+	aggregator.EncoderAggregatorConfig `config:",squash"`
+	// Format is "jsonlines" or "csv".
+	Format string `config:"format"`
+	// Interval is bucket duration.
+	Interval time.Duration `config:"interval" validate:"min-time=1s"`
+	// Delay is time bucket is kept open after its end, waiting for samples of long requests.
+	// Sample, that came after its bucket has been written, is written in new bucket with same timestamp.
+	Delay time.Duration `config:"delay" validate:"min-time=0s"`
+	// Quantiles of durations in percents.
+	Quantiles []float64 `config:"quantiles" validate:"dive,min=0,max=100"`
+	// MaxTags limits number of tags, that have separate stats during the test. Samples of other
+	// tags are accounted under "other" tag. See coreutil.TagLimiter.
+	MaxTags int `config:"max-tags" validate:"min=1"`
+	// RTTFromSchedule makes RTT measured from scheduled shoot time, so queueing delay of late shoots
+	// is included. See Sample.ScheduledRTT.
+	RTTFromSchedule bool `config:"rtt-from-schedule"`
+}
+
+func DefaultBucketsConfig() BucketsConfig {
+	return BucketsConfig{
+		EncoderAggregatorConfig: aggregator.DefaultEncoderAggregatorConfig(),
+		Format:                  BucketsFormatJSONLines,
+		Interval:                time.Second,
+		Delay:                   5 * time.Second,
+		Quantiles:               []float64{50, 90, 95, 99},
+		MaxTags:                 100,
+	}
+}
+
+// NewBucketsAggregator returns aggregator, that groups samples in time buckets by tag,
+// and writes stats of every bucket as one JSON line or CSV row.
+func NewBucketsAggregator(conf BucketsConfig) (core.Aggregator, error) {
+	switch conf.Format {
+	case BucketsFormatJSONLines, BucketsFormatCSV:
+	default:
+		return nil, errors.Errorf("unknown buckets format %q: expected %q or %q",
+			conf.Format, BucketsFormatJSONLines, BucketsFormatCSV)
+	}
+	var newEncoder aggregator.NewSampleEncoder = func(w io.Writer, onFlush func()) aggregator.SampleEncoder {
+		w = ioutil2.NewCallbackWriter(w, onFlush)
+		return newBucketsEncoder(w, conf)
+	}
+	return aggregator.NewEncoderAggregator(newEncoder, conf.EncoderAggregatorConfig), nil
+}
+
+// BucketReport is stats of samples with the same tag, started during the bucket interval.
+// Durations are in microseconds.
+type BucketReport struct {
+	// Timestamp is unix time of bucket start in seconds.
+	Timestamp int64                `json:"ts"`
+	Tag       string               `json:"tag"`
+	Count     int64                `json:"count"`
+	RPS       float64              `json:"rps"`
+	Errors    int64                `json:"errors"`
+	RTT       BucketDurationReport `json:"rtt_us"`
+	Connect   BucketDurationReport `json:"connect_us"`
+	Send      BucketDurationReport `json:"send_us"`
+	Latency   BucketDurationReport `json:"latency_us"`
+	Receive   BucketDurationReport `json:"receive_us"`
+}
+
+type BucketDurationReport struct {
+	Min       int64            `json:"min"`
+	Mean      float64          `json:"mean"`
+	Max       int64            `json:"max"`
+	Quantiles map[string]int64 `json:"quantiles"`
+}
+
+// bucketDurations are sample durations, that have stats in bucket. Order matches CSV columns.
+var bucketDurations = []struct {
+	name   string
+	key    int
+	report func(r *BucketReport) *BucketDurationReport
+}{
+	{"rtt", keyRTTMicro, func(r *BucketReport) *BucketDurationReport { return &r.RTT }},
+	{"connect", keyConnectMicro, func(r *BucketReport) *BucketDurationReport { return &r.Connect }},
+	{"send", keySendMicro, func(r *BucketReport) *BucketDurationReport { return &r.Send }},
+	{"latency", keyLatencyMicro, func(r *BucketReport) *BucketDurationReport { return &r.Latency }},
+	{"receive", keyReceiveMicro, func(r *BucketReport) *BucketDurationReport { return &r.Receive }},
+}
+
+type bucketStats struct {
+	count     int64
+	errors    int64
+	durations []*histogram.Histogram // Microseconds. Indexed as bucketDurations.
+}
+
+func newBucketStats() *bucketStats {
+	s := &bucketStats{durations: make([]*histogram.Histogram, len(bucketDurations))}
+	for i := range s.durations {
+		s.durations[i] = histogram.New()
+	}
+	return s
+}
+
+// addToBucket adds sample to stats of its tag. Samples of tags over limit are accounted under "other" tag.
+func addToBucket(tags map[string]*bucketStats, sample *Sample, limiter *coreutil.TagLimiter, rttFromSchedule bool) {
+	tag := sample.Tags()
+	if tag == summaryEmptyTag {
+		tag = ""
+	}
+	tag = limiter.Tag(tag)
+	stats, ok := tags[tag]
+	if !ok {
+		stats = newBucketStats()
+		tags[tag] = stats
+	}
+	stats.add(sample, rttFromSchedule)
+}
+
+func (s *bucketStats) add(sample *Sample, rttFromSchedule bool) {
+	s.count++
+	if sample.Errno() != 0 || sample.ProtoCode() >= 500 {
+		s.errors++
+	}
+	for i, d := range bucketDurations {
+		if d.key == keyRTTMicro {
+			s.durations[i].Record(sampleRTT(sample, rttFromSchedule).Microseconds())
+			continue
+		}
+		s.durations[i].Record(int64(sample.get(d.key)))
+	}
+}
+
+type bucketsEncoder struct {
+	conf  BucketsConfig
+	buf   *bufio.Writer
+	write func(r *BucketReport) error
+	// open buckets by start unix nano.
+	open    map[int64]map[string]*bucketStats
+	limiter *coreutil.TagLimiter
+}
+
+func newBucketsEncoder(w io.Writer, conf BucketsConfig) *bucketsEncoder {
+	bufSize := conf.BufferSize
+	if bufSize == 0 {
+		bufSize = coreutil.DefaultBufferSize
+	}
+	e := &bucketsEncoder{
+		conf:    conf,
+		buf:     bufio.NewWriterSize(w, bufSize),
+		open:    map[int64]map[string]*bucketStats{},
+		limiter: coreutil.NewTagLimiter(conf.MaxTags),
+	}
+	switch conf.Format {
+	case BucketsFormatCSV:
+		e.write = newBucketsCSVWriter(e.buf, conf.Quantiles)
+	default:
+		encoder := json.NewEncoder(e.buf)
+		e.write = func(r *BucketReport) error { return encoder.Encode(r) }
+	}
+	return e
+}
+
+var _ aggregator.SampleEncodeCloser = (*bucketsEncoder)(nil)
+
+func (e *bucketsEncoder) Encode(s core.Sample) error {
+	sample := s.(*Sample)
+	start := sample.Timestamp().Truncate(e.conf.Interval).UnixNano()
+	tags, ok := e.open[start]
+	if !ok {
+		tags = map[string]*bucketStats{}
+		e.open[start] = tags
+	}
+	addToBucket(tags, sample, e.limiter, e.conf.RTTFromSchedule)
+	// Sample is reported at the end of shoot, so it's time is the latest known time.
+	finished := sample.Timestamp().Add(sample.RTT())
+	releaseSample(sample)
+	return e.writeFinished(finished)
+}
+
+// Flush writes buckets, that are finished by wall time, so stats are written even if there is no samples.
+func (e *bucketsEncoder) Flush() error {
+	if err := e.writeFinished(time.Now()); err != nil {
+		return err
+	}
+	return e.buf.Flush()
+}
+
+func (e *bucketsEncoder) Close() error {
+	if err := e.writeBuckets(func(int64) bool { return true }); err != nil {
+		return err
+	}
+	return e.buf.Flush()
+}
+
+func (e *bucketsEncoder) writeFinished(now time.Time) error {
+	closeBefore := now.Add(-e.conf.Interval - e.conf.Delay).UnixNano()
+	return e.writeBuckets(func(start int64) bool { return start <= closeBefore })
+}
+
+func (e *bucketsEncoder) writeBuckets(shouldClose func(start int64) bool) error {
+	var starts []int64
+	for start := range e.open {
+		if shouldClose(start) {
+			starts = append(starts, start)
+		}
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+	for _, start := range starts {
+		tags := e.open[start]
+		delete(e.open, start)
+		for _, tag := range sortedKeys(tags) {
+			err := e.write(newBucketReport(time.Unix(0, start), tag, tags[tag], e.conf.Interval, e.conf.Quantiles))
+			if err != nil {
+				return errors.WithMessage(err, "bucket write failed")
+			}
+		}
+	}
+	return nil
+}
+
+func newBucketReport(start time.Time, tag string, s *bucketStats, interval time.Duration, quantiles []float64) *BucketReport {
+	r := &BucketReport{
+		Timestamp: start.Unix(),
+		Tag:       tag,
+		Count:     s.count,
+		RPS:       float64(s.count) / interval.Seconds(),
+		Errors:    s.errors,
+	}
+	for i, d := range bucketDurations {
+		h := s.durations[i]
+		dr := d.report(r)
+		dr.Min = h.Min()
+		dr.Mean = h.Mean()
+		dr.Max = h.Max()
+		dr.Quantiles = make(map[string]int64, len(quantiles))
+		for _, q := range quantiles {
+			dr.Quantiles[formatQuantile(q)] = h.Quantile(q / 100)
+		}
+	}
+	return r
+}
+
+func newBucketsCSVWriter(w io.Writer, quantiles []float64) func(r *BucketReport) error {
+	cw := csv.NewWriter(w)
+	header := []string{"ts", "tag", "count", "rps", "errors"}
+	for _, d := range bucketDurations {
+		header = append(header, d.name+"_min", d.name+"_mean", d.name+"_max")
+		for _, q := range quantiles {
+			header = append(header, d.name+"_q"+formatQuantile(q))
+		}
+	}
+	var headerWritten bool
+	return func(r *BucketReport) error {
+		if !headerWritten {
+			headerWritten = true
+			if err := cw.Write(header); err != nil {
+				return err
+			}
+		}
+		row := make([]string, 0, len(header))
+		row = append(row,
+			strconv.FormatInt(r.Timestamp, 10),
+			r.Tag,
+			strconv.FormatInt(r.Count, 10),
+			strconv.FormatFloat(r.RPS, 'f', -1, 64),
+			strconv.FormatInt(r.Errors, 10),
+		)
+		for _, d := range bucketDurations {
+			dr := d.report(r)
+			row = append(row,
+				strconv.FormatInt(dr.Min, 10),
+				strconv.FormatFloat(dr.Mean, 'f', 1, 64),
+				strconv.FormatInt(dr.Max, 10),
+			)
+			for _, q := range quantiles {
+				row = append(row, strconv.FormatInt(dr.Quantiles[formatQuantile(q)], 10))
+			}
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+		// Data is flushed to underlying buffer, that is flushed by encoder.
+		cw.Flush()
+		return cw.Error()
+	}
+}
@@ -0,0 +1,125 @@
+package netsample
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/yandex/pandora/core"
+	"github.com/yandex/pandora/core/datasink"
+	"go.uber.org/zap"
+)
+
+var _ = Describe("Buckets", func() {
+	var (
+		conf  BucketsConfig
+		start time.Time
+	)
+	BeforeEach(func() {
+		conf = DefaultBucketsConfig()
+		conf.Quantiles = []float64{50, 99}
+		start = time.Unix(1484660999, 0)
+	})
+	newSample := func(tag string, offset, rtt time.Duration, code, errno int) *Sample {
+		s := Acquire(tag)
+		s.timeStamp = start.Add(offset)
+		s.setDuration(keyRTTMicro, rtt)
+		s.setDuration(keyConnectMicro, rtt/10)
+		s.set(keyProtoCode, code)
+		s.set(keyErrno, errno)
+		return s
+	}
+	decode := func(data []byte) []BucketReport {
+		var reports []BucketReport
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		for decoder.More() {
+			var r BucketReport
+			Expect(decoder.Decode(&r)).To(Succeed())
+			reports = append(reports, r)
+		}
+		return reports
+	}
+
+	It("aggregates by seconds and tags", func() {
+		sink := datasink.NewBuffer()
+		conf.Sink = sink
+		testee, err := NewBucketsAggregator(conf)
+		Expect(err).NotTo(HaveOccurred())
+		for i := 0; i < 10; i++ {
+			testee.Report(newSample("a", time.Duration(i)*100*time.Millisecond, time.Duration(i+1)*time.Millisecond, 200, 0))
+		}
+		testee.Report(newSample("b", 500*time.Millisecond, time.Second, ProtoCodeError, 110))
+		testee.Report(newSample("a", 1500*time.Millisecond, time.Millisecond, 503, 0))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		Expect(testee.Run(ctx, core.AggregatorDeps{Log: zap.NewNop()})).To(Succeed())
+
+		reports := decode(sink.Bytes())
+		Expect(reports).To(HaveLen(3))
+		a := reports[0]
+		Expect(a.Timestamp).To(BeEquivalentTo(start.Unix()))
+		Expect(a.Tag).To(Equal("a"))
+		Expect(a.Count).To(BeEquivalentTo(10))
+		Expect(a.RPS).To(BeEquivalentTo(10))
+		Expect(a.Errors).To(BeZero())
+		Expect(a.RTT.Min).To(BeEquivalentTo(1000))
+		Expect(a.RTT.Max).To(BeEquivalentTo(10000))
+		Expect(a.RTT.Mean).To(BeNumerically("~", 5500, 1))
+		Expect(a.RTT.Quantiles).To(HaveKey("99"))
+		Expect(a.Connect.Max).To(BeEquivalentTo(1000))
+
+		b := reports[1]
+		Expect(b.Tag).To(Equal("b"))
+		Expect(b.Errors).To(BeEquivalentTo(1))
+
+		next := reports[2]
+		Expect(next.Timestamp).To(BeEquivalentTo(start.Unix() + 1))
+		Expect(next.Errors).To(BeEquivalentTo(1))
+	})
+
+	It("unknown format", func() {
+		conf.Sink = datasink.NewBuffer()
+		conf.Format = "xml"
+		_, err := NewBucketsAggregator(conf)
+		Expect(err).To(MatchError(ContainSubstring(`unknown buckets format "xml"`)))
+	})
+
+	It("writes bucket after delay", func() {
+		conf.Delay = time.Second
+		out := &bytes.Buffer{}
+		encoder := newBucketsEncoder(out, conf)
+		Expect(encoder.Encode(newSample("", 0, time.Millisecond, 200, 0))).To(Succeed())
+		Expect(encoder.Encode(newSample("", 1500*time.Millisecond, time.Millisecond, 200, 0))).To(Succeed())
+		Expect(encoder.buf.Flush()).To(Succeed())
+		Expect(out.Len()).To(BeZero())
+
+		Expect(encoder.Encode(newSample("", 2*time.Second, time.Millisecond, 200, 0))).To(Succeed())
+		Expect(encoder.buf.Flush()).To(Succeed())
+		Expect(decode(out.Bytes())).To(HaveLen(1))
+
+		Expect(encoder.Flush()).To(Succeed())
+		Expect(decode(out.Bytes())).To(HaveLen(3))
+	})
+
+	It("encodes CSV", func() {
+		conf.Format = BucketsFormatCSV
+		out := &bytes.Buffer{}
+		encoder := newBucketsEncoder(out, conf)
+		Expect(encoder.Encode(newSample("__EMPTY__", 0, time.Millisecond, 200, 0))).To(Succeed())
+		Expect(encoder.Encode(newSample("", time.Second, 3*time.Millisecond, 200, 0))).To(Succeed())
+		Expect(encoder.Close()).To(Succeed())
+
+		rows, err := csv.NewReader(out).ReadAll()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rows).To(HaveLen(3))
+		Expect(strings.Join(rows[0][:10], ",")).To(Equal("ts,tag,count,rps,errors,rtt_min,rtt_mean,rtt_max,rtt_q50,rtt_q99"))
+		Expect(rows[0]).To(HaveLen(len(rows[1])))
+		Expect(rows[1][:8]).To(Equal([]string{"1484660999", "", "1", "1", "0", "1000", "1000.0", "1000"}))
+		Expect(rows[2][0]).To(Equal("1484661000"))
+	})
+})
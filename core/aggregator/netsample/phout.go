@@ -4,8 +4,10 @@ import (
 	"bufio"
 	"context"
 	"io"
+	"math"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/c2h5oh/datasize"
@@ -139,3 +141,35 @@ func appendTimestamp(ts time.Time, dst []byte) []byte {
 	dst[dotIndex] = '.'
 	return dst
 }
+
+// ParsePhout parses line written by phout aggregator. Tag suffix like "#42" is parsed
+// as ammo id, as it is written with ID option.
+// Returned sample is not acquired from pool, so it should not be reported to aggregators.
+func ParsePhout(line string) (*Sample, error) {
+	fields := strings.Split(line, string(phoutDelimiter))
+	if len(fields) != 2+fieldsNum {
+		return nil, errors.Errorf("invalid phout line: expected %v tab separated fields, got %v",
+			2+fieldsNum, len(fields))
+	}
+	ts, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid phout timestamp")
+	}
+	s := &Sample{
+		// Phout timestamp has millisecond precision, so round float error.
+		timeStamp: time.UnixMilli(int64(math.Round(ts * 1000))),
+		tags:      fields[1],
+	}
+	if i := strings.LastIndexByte(s.tags, '#'); i >= 0 {
+		if id, err := strconv.ParseUint(s.tags[i+1:], 10, 64); err == nil {
+			s.tags, s.id = s.tags[:i], id
+		}
+	}
+	for i := range s.fields {
+		s.fields[i], err = strconv.Atoi(fields[2+i])
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid phout field %v", 2+i)
+		}
+	}
+	return s, nil
+}
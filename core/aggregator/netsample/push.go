@@ -0,0 +1,436 @@
+package netsample
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/yandex/pandora/core"
+	"github.com/yandex/pandora/core/aggregator"
+	"github.com/yandex/pandora/core/coreutil"
+	"github.com/yandex/pandora/lib/errutil"
+	"github.com/yandex/pandora/lib/monitoring"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+)
+
+const (
+	PushProtocolInflux   = "influx"
+	PushProtocolGraphite = "graphite"
+
+	// maxUDPPayload limits datagram size, so it is not fragmented in most networks.
+	maxUDPPayload = 1400
+)
+
+type PushConfig struct {
+	// Protocol is "influx" for InfluxDB line protocol, or "graphite" for Graphite plaintext protocol.
+	Protocol string `config:"protocol"`
+	// Address is backend URL with scheme tcp, udp, http or https.
+	// For example: "udp://localhost:8089", "http://localhost:8086/write?db=pandora".
+	Address string `config:"address" validate:"required"`
+	// Prefix is Influx measurement name, or Graphite metric path prefix.
+	Prefix string `config:"prefix" validate:"required"`
+	// Tags are added to every Influx line. Ignored for Graphite.
+	Tags map[string]string `config:"tags"`
+	// Interval is period of samples aggregation.
+	Interval  time.Duration `config:"interval" validate:"min-time=1s"`
+	Quantiles []float64     `config:"quantiles" validate:"dive,min=0,max=100"`
+	// MaxTags limits number of tags, that have separate series. See BucketsConfig.MaxTags.
+	MaxTags int `config:"max-tags" validate:"min=1"`
+	// RTTFromSchedule makes RTT measured from scheduled shoot time, so queueing delay of late shoots
+	// is included. See Sample.ScheduledRTT.
+	RTTFromSchedule bool `config:"rtt-from-schedule"`
+	// BatchSize is maximum number of lines in one write.
+	BatchSize int `config:"batch-size" validate:"min=1"`
+	// BufferSize is maximum number of lines waiting for send. On overflow, lines are dropped.
+	BufferSize int `config:"buffer-size" validate:"min=1"`
+	// Retries is number of write retries after fail. Batch is dropped after last retry fail.
+	Retries       int           `config:"retries" validate:"min=0"`
+	RetryInterval time.Duration `config:"retry-interval" validate:"min-time=0s"`
+	// Timeout of one write. Also, lines that are not sent in Timeout after run finish, are dropped.
+	Timeout        time.Duration             `config:"timeout" validate:"min-time=1ms"`
+	ReporterConfig aggregator.ReporterConfig `config:",squash"`
+}
+
+func DefaultPushConfig() PushConfig {
+	return PushConfig{
+		Protocol:       PushProtocolInflux,
+		Prefix:         "pandora",
+		Interval:       time.Second,
+		Quantiles:      []float64{50, 90, 95, 99},
+		MaxTags:        100,
+		BatchSize:      1000,
+		BufferSize:     100000,
+		Retries:        3,
+		RetryInterval:  time.Second,
+		Timeout:        5 * time.Second,
+		ReporterConfig: aggregator.DefaultReporterConfig(),
+	}
+}
+
+// PushLinesDropped counts metric lines of all push aggregators, that were not sent, because of
+// buffer overflow, or backend write fails. Dropped lines don't fail shooting.
+var PushLinesDropped = monitoring.NewCounter("aggregator_PushLinesDropped")
+
+// NewPush returns aggregator, that aggregates samples by tag every interval, and pushes stats
+// to InfluxDB or Graphite. Samples are aggregated in the same way as in buckets aggregator.
+func NewPush(conf PushConfig) (Aggregator, error) {
+	var format func(start time.Time, r *BucketReport) [][]byte
+	switch conf.Protocol {
+	case PushProtocolInflux:
+		format = newInfluxFormat(conf.Prefix, conf.Tags)
+	case PushProtocolGraphite:
+		format = newGraphiteFormat(conf.Prefix)
+	default:
+		return nil, errors.Errorf("unknown push protocol %q: expected %q or %q",
+			conf.Protocol, PushProtocolInflux, PushProtocolGraphite)
+	}
+	transport, err := newPushTransport(conf.Address, conf.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &pushAggregator{
+		Reporter:  *aggregator.NewReporter(conf.ReporterConfig),
+		conf:      conf,
+		format:    format,
+		transport: transport,
+		lines:     make(chan []byte, conf.BufferSize),
+		current:   map[string]*bucketStats{},
+		limiter:   coreutil.NewTagLimiter(conf.MaxTags),
+	}, nil
+}
+
+type pushAggregator struct {
+	aggregator.Reporter
+	conf      PushConfig
+	log       *zap.Logger
+	format    func(start time.Time, r *BucketReport) [][]byte
+	transport pushTransport
+
+	lines        chan []byte
+	linesDropped atomic.Int64
+
+	currentStart time.Time
+	current      map[string]*bucketStats
+	limiter      *coreutil.TagLimiter
+}
+
+func (a *pushAggregator) Report(s *Sample) { a.Reporter.Report(s) }
+
+func (a *pushAggregator) Run(ctx context.Context, deps core.AggregatorDeps) (err error) {
+	a.log = deps.Log
+	if a.log == nil {
+		a.log = zap.NewNop()
+	}
+	sendCtx, cancelSend := context.WithCancel(context.Background())
+	defer cancelSend()
+	sendDone := make(chan struct{})
+	go func() {
+		defer close(sendDone)
+		a.sendLoop(sendCtx)
+	}()
+	defer func() {
+		a.flush(time.Now())
+		close(a.lines)
+		select {
+		case <-sendDone:
+		case <-time.After(a.conf.Timeout):
+			a.log.Warn("Metrics send timeout exceeded. Dropping not sent lines")
+			cancelSend()
+			<-sendDone
+		}
+		err = errutil.Join(err, a.DroppedErr())
+		if dropped := a.linesDropped.Load(); dropped > 0 {
+			a.log.Warn("Some metric lines were not sent", zap.Int64("dropped", dropped))
+		}
+		err = errutil.Join(err, a.transport.Close())
+	}()
+
+	a.currentStart = time.Now()
+	ticker := time.NewTicker(a.conf.Interval)
+	defer ticker.Stop()
+loop:
+	for {
+		select {
+		case s := <-a.Incomming:
+			a.handle(s.(*Sample))
+		case now := <-ticker.C:
+			a.flush(now)
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	for {
+		// Context is done, but we should read all data from sink.
+		select {
+		case s := <-a.Incomming:
+			a.handle(s.(*Sample))
+		default:
+			return nil
+		}
+	}
+}
+
+func (a *pushAggregator) handle(s *Sample) {
+	addToBucket(a.current, s, a.limiter, a.conf.RTTFromSchedule)
+	releaseSample(s)
+}
+
+// flush formats stats of current interval and puts lines into send buffer.
+func (a *pushAggregator) flush(now time.Time) {
+	start, tags := a.currentStart, a.current
+	a.currentStart, a.current = now, map[string]*bucketStats{}
+	interval := now.Sub(start)
+	if len(tags) == 0 || interval <= 0 {
+		return
+	}
+	for _, tag := range sortedKeys(tags) {
+		report := newBucketReport(start, tag, tags[tag], interval, a.conf.Quantiles)
+		for _, line := range a.format(start, report) {
+			select {
+			case a.lines <- line:
+			default:
+				a.dropLines(1, "Metrics send buffer is full")
+			}
+		}
+	}
+}
+
+func (a *pushAggregator) dropLines(n int, reason string, fields ...zap.Field) {
+	PushLinesDropped.Add(int64(n))
+	if a.linesDropped.Add(int64(n)) == int64(n) {
+		a.log.Warn(reason+". First metric lines are dropped. Total number is logged on finish", fields...)
+	}
+}
+
+func (a *pushAggregator) sendLoop(ctx context.Context) {
+	for line := range a.lines {
+		batch := [][]byte{line}
+	batchLoop:
+		for len(batch) < a.conf.BatchSize {
+			select {
+			case line, ok := <-a.lines:
+				if !ok {
+					break batchLoop
+				}
+				batch = append(batch, line)
+			default:
+				break batchLoop
+			}
+		}
+		err := a.send(ctx, batch)
+		if err != nil {
+			a.dropLines(len(batch), "Metrics send failed", zap.Error(err))
+		}
+	}
+}
+
+func (a *pushAggregator) send(ctx context.Context, batch [][]byte) (err error) {
+	for attempt := 0; attempt <= a.conf.Retries; attempt++ {
+		if attempt > 0 {
+			a.log.Debug("Retrying metrics send", zap.Int("attempt", attempt), zap.Error(err))
+			select {
+			case <-time.After(a.conf.RetryInterval):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		err = a.transport.Write(ctx, batch)
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func newInfluxFormat(measurement string, extraTags map[string]string) func(start time.Time, r *BucketReport) [][]byte {
+	var tags strings.Builder
+	for _, k := range sortedKeys(extraTags) {
+		fmt.Fprintf(&tags, ",%s=%s", influxEscaper.Replace(k), influxEscaper.Replace(extraTags[k]))
+	}
+	measurement = influxEscaper.Replace(measurement)
+	return func(start time.Time, r *BucketReport) [][]byte {
+		var b bytes.Buffer
+		b.WriteString(measurement)
+		b.WriteString(tags.String())
+		if r.Tag != "" {
+			// Influx doesn't allow empty tag values, so untagged samples have no tag.
+			b.WriteString(",tag=")
+			b.WriteString(influxEscaper.Replace(r.Tag))
+		}
+		fmt.Fprintf(&b, " count=%di,rps=%s,errors=%di", r.Count, formatPushFloat(r.RPS), r.Errors)
+		forEachDurationField(r, func(name string, value string) {
+			b.WriteString(",")
+			b.WriteString(name)
+			b.WriteString("=")
+			b.WriteString(value)
+		})
+		fmt.Fprintf(&b, " %d\n", start.UnixNano())
+		return [][]byte{b.Bytes()}
+	}
+}
+
+var influxEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+
+func newGraphiteFormat(prefix string) func(start time.Time, r *BucketReport) [][]byte {
+	return func(start time.Time, r *BucketReport) [][]byte {
+		tag := r.Tag
+		if tag == "" {
+			tag = "untagged"
+		}
+		path := prefix + "." + graphiteSanitize(tag) + "."
+		ts := strconv.FormatInt(start.Unix(), 10)
+		line := func(name, value string) []byte {
+			return []byte(path + name + " " + value + " " + ts + "\n")
+		}
+		lines := [][]byte{
+			line("count", strconv.FormatInt(r.Count, 10)),
+			line("rps", formatPushFloat(r.RPS)),
+			line("errors", strconv.FormatInt(r.Errors, 10)),
+		}
+		forEachDurationField(r, func(name string, value string) {
+			lines = append(lines, line(name, value))
+		})
+		return lines
+	}
+}
+
+func graphiteSanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// forEachDurationField calls fn with name and value of every duration stat, like "rtt_q99", in microseconds.
+func forEachDurationField(r *BucketReport, fn func(name string, value string)) {
+	for _, d := range bucketDurations {
+		dr := d.report(r)
+		fn(d.name+"_min", strconv.FormatInt(dr.Min, 10))
+		fn(d.name+"_mean", formatPushFloat(dr.Mean))
+		fn(d.name+"_max", strconv.FormatInt(dr.Max, 10))
+		quantiles := make([]float64, 0, len(dr.Quantiles))
+		for q := range dr.Quantiles {
+			f, _ := strconv.ParseFloat(q, 64)
+			quantiles = append(quantiles, f)
+		}
+		sort.Float64s(quantiles)
+		for _, q := range quantiles {
+			key := formatQuantile(q)
+			fn(d.name+"_q"+strings.ReplaceAll(key, ".", "_"), strconv.FormatInt(dr.Quantiles[key], 10))
+		}
+	}
+}
+
+func formatPushFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+type pushTransport interface {
+	// Write writes batch of lines. Called from one goroutine.
+	Write(ctx context.Context, batch [][]byte) error
+	Close() error
+}
+
+func newPushTransport(address string, timeout time.Duration) (pushTransport, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid push address")
+	}
+	switch u.Scheme {
+	case "tcp", "udp":
+		if u.Host == "" {
+			return nil, errors.Errorf("invalid push address %q: no host", address)
+		}
+		return &connTransport{network: u.Scheme, address: u.Host, timeout: timeout}, nil
+	case "http", "https":
+		return &httpTransport{url: address, client: &http.Client{Timeout: timeout}}, nil
+	}
+	return nil, errors.Errorf("invalid push address %q: scheme should be tcp, udp, http or https", address)
+}
+
+// connTransport writes lines to TCP or UDP connection. Connection is established lazily,
+// and reestablished after write fail.
+type connTransport struct {
+	network string
+	address string
+	timeout time.Duration
+	conn    net.Conn
+}
+
+func (t *connTransport) Write(ctx context.Context, batch [][]byte) error {
+	if t.conn == nil {
+		dialer := net.Dialer{Timeout: t.timeout}
+		conn, err := dialer.DialContext(ctx, t.network, t.address)
+		if err != nil {
+			return err
+		}
+		t.conn = conn
+	}
+	err := t.write(batch)
+	if err != nil {
+		_ = t.conn.Close()
+		t.conn = nil
+	}
+	return err
+}
+
+func (t *connTransport) write(batch [][]byte) error {
+	err := t.conn.SetWriteDeadline(time.Now().Add(t.timeout))
+	if err != nil {
+		return err
+	}
+	var buf []byte
+	for _, line := range batch {
+		if t.network == "udp" && len(buf) > 0 && len(buf)+len(line) > maxUDPPayload {
+			if _, err := t.conn.Write(buf); err != nil {
+				return err
+			}
+			buf = buf[:0]
+		}
+		buf = append(buf, line...)
+	}
+	_, err = t.conn.Write(buf)
+	return err
+}
+
+func (t *connTransport) Close() error {
+	if t.conn == nil {
+		return nil
+	}
+	return t.conn.Close()
+}
+
+type httpTransport struct {
+	url    string
+	client *http.Client
+}
+
+func (t *httpTransport) Write(ctx context.Context, batch [][]byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(bytes.Join(batch, nil)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	res, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return errors.Errorf("unexpected response status %q", res.Status)
+	}
+	return nil
+}
+
+func (t *httpTransport) Close() error { return nil }
@@ -0,0 +1,169 @@
+package netsample
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/yandex/pandora/core"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+)
+
+var _ = Describe("Push", func() {
+	var (
+		conf     PushConfig
+		received chan string
+	)
+	BeforeEach(func() {
+		conf = DefaultPushConfig()
+		conf.Quantiles = []float64{99.9}
+		conf.RetryInterval = 10 * time.Millisecond
+		conf.Timeout = time.Second
+		received = make(chan string, 100)
+	})
+	newSample := func(tag string, rtt time.Duration, code int) *Sample {
+		s := Acquire(tag)
+		s.setDuration(keyRTTMicro, rtt)
+		s.set(keyProtoCode, code)
+		return s
+	}
+	run := func(samples ...*Sample) error {
+		testee, err := NewPush(conf)
+		Expect(err).NotTo(HaveOccurred())
+		for _, s := range samples {
+			testee.Report(s)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		return testee.Run(ctx, core.AggregatorDeps{Log: zap.NewNop()})
+	}
+	receivedChunks := func() []string {
+		var chunks []string
+		for {
+			select {
+			case d := <-received:
+				chunks = append(chunks, d)
+			case <-time.After(100 * time.Millisecond):
+				return chunks
+			}
+		}
+	}
+	receivedData := func() string {
+		return strings.Join(receivedChunks(), "")
+	}
+
+	It("pushes influx lines over tcp", func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		defer listener.Close()
+		go func() {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			data, _ := io.ReadAll(conn)
+			received <- string(data)
+		}()
+		conf.Address = "tcp://" + listener.Addr().String()
+		conf.Tags = map[string]string{"env": "test run"}
+
+		err = run(newSample("a", time.Millisecond, 200), newSample("a", 3*time.Millisecond, 503), newSample("", time.Millisecond, 200))
+		Expect(err).NotTo(HaveOccurred())
+		lines := strings.Split(strings.TrimSpace(receivedData()), "\n")
+		Expect(lines).To(HaveLen(2))
+		Expect(lines[0]).To(MatchRegexp(`^pandora,env=test\\ run count=1i,rps=[0-9.]+,errors=0i,rtt_min=1000,`))
+		Expect(lines[1]).To(MatchRegexp(`^pandora,env=test\\ run,tag=a count=2i,rps=[0-9.]+,errors=1i,rtt_min=1000,rtt_mean=2000,rtt_max=3000,rtt_q99_9=3000,connect_min=0,`))
+		Expect(lines[1]).To(MatchRegexp(` \d{19}$`))
+	})
+
+	It("pushes graphite lines over udp", func() {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		defer conn.Close()
+		go func() {
+			buf := make([]byte, 64*1024)
+			for {
+				n, _, err := conn.ReadFrom(buf)
+				if err != nil {
+					return
+				}
+				received <- string(buf[:n])
+			}
+		}()
+		conf.Address = "udp://" + conn.LocalAddr().String()
+		conf.Protocol = PushProtocolGraphite
+		conf.Prefix = "load.test"
+
+		var samples []*Sample
+		for _, tag := range []string{"a", "b/c", "d", "e", "f"} {
+			samples = append(samples, newSample(tag, time.Millisecond, 200))
+		}
+		Expect(run(samples...)).To(Succeed())
+		datagrams := receivedChunks()
+		for _, d := range datagrams {
+			Expect(len(d)).To(BeNumerically("<=", maxUDPPayload))
+		}
+		data := strings.Join(datagrams, "")
+		Expect(data).To(MatchRegexp(`(?m)^load\.test\.a\.count 1 \d+$`))
+		Expect(data).To(MatchRegexp(`(?m)^load\.test\.b_c\.rtt_q99_9 1000 \d+$`))
+		Expect(strings.Count(data, "\n")).To(Equal(5 * (3 + 5*4)))
+	})
+
+	It("retries http writes", func() {
+		var requests atomic.Int64
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if requests.Inc() == 1 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			data, _ := io.ReadAll(r.Body)
+			received <- string(data)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+		conf.Address = server.URL + "/write?db=pandora"
+
+		Expect(run(newSample("a", time.Millisecond, 200))).To(Succeed())
+		Expect(receivedData()).To(HavePrefix("pandora,tag=a count=1i"))
+		Expect(requests.Load()).To(BeEquivalentTo(2))
+	})
+
+	It("drops lines without fail when backend is slow", func() {
+		unblock := make(chan struct{})
+		var once sync.Once
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-unblock
+		}))
+		defer server.Close()
+		defer once.Do(func() { close(unblock) })
+		conf.Address = server.URL
+		conf.BufferSize = 1
+		conf.BatchSize = 1
+		conf.Retries = 0
+		conf.Timeout = 100 * time.Millisecond
+
+		droppedBefore := PushLinesDropped.Get()
+		err := run(newSample("a", time.Millisecond, 200), newSample("b", time.Millisecond, 200), newSample("c", time.Millisecond, 200))
+		once.Do(func() { close(unblock) })
+		Expect(err).NotTo(HaveOccurred())
+		Expect(PushLinesDropped.Get() - droppedBefore).To(BeNumerically(">", 0))
+	})
+
+	It("invalid config", func() {
+		conf.Address = "ftp://localhost"
+		_, err := NewPush(conf)
+		Expect(err).To(MatchError(ContainSubstring("scheme should be tcp, udp, http or https")))
+		conf.Address = "udp://localhost:1"
+		conf.Protocol = "statsd"
+		_, err = NewPush(conf)
+		Expect(err).To(MatchError(ContainSubstring(`unknown push protocol "statsd"`)))
+	})
+})
@@ -6,6 +6,8 @@
 package netsample
 
 import (
+	"encoding/json"
+	"math"
 	"net"
 	"net/url"
 	"os"
@@ -14,6 +16,7 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/yandex/pandora/core"
 )
 
 const (
@@ -47,16 +50,64 @@ func Acquire(tag string) *Sample {
 
 func releaseSample(s *Sample) { samplePool.Put(s) }
 
+// Release returns sample, that is not reported to Aggregator, to pool. For example, sample that
+// was acquired by ammo, but not shot in dry-run. Sample must not be used after Release.
+func Release(s *Sample) { releaseSample(s) }
+
 var samplePool = &sync.Pool{New: func() interface{} { return &Sample{} }}
 
 type Sample struct {
 	timeStamp time.Time
+	scheduled time.Time
 	tags      string
 	id        uint64
 	fields    [fieldsNum]int
 	err       error
 }
 
+// Clone returns copy of Sample, acquired from pool. Copy is released independently of original,
+// so both can be reported to different Aggregators.
+func (s *Sample) Clone() *Sample {
+	c := samplePool.Get().(*Sample)
+	*c = *s
+	return c
+}
+
+// CloneSample implements aggregator.ClonableSample.
+func (s *Sample) CloneSample() core.Sample { return s.Clone() }
+
+func (s *Sample) Timestamp() time.Time { return s.timeStamp }
+
+// ScheduledTime returns time, when shoot was scheduled by pool schedule. Zero, if unknown.
+func (s *Sample) ScheduledTime() time.Time     { return s.scheduled }
+func (s *Sample) SetScheduledTime(t time.Time) { s.scheduled = t }
+
+// ScheduleLag returns time between scheduled shoot time and sample start. It is not zero, when
+// shoot is late, because instances can't keep up with schedule.
+func (s *Sample) ScheduleLag() time.Duration {
+	if s.scheduled.IsZero() {
+		return 0
+	}
+	lag := s.timeStamp.Sub(s.scheduled)
+	if lag < 0 {
+		return 0
+	}
+	return lag
+}
+
+// ScheduledRTT returns RTT measured from scheduled shoot time. Unlike RTT, it includes queueing delay
+// of late shoots, so latency is not understated, when target is saturated (coordinated omission).
+func (s *Sample) ScheduledRTT() time.Duration {
+	return s.RTT() + s.ScheduleLag()
+}
+
+func sampleRTT(s *Sample, fromSchedule bool) time.Duration {
+	if fromSchedule {
+		return s.ScheduledRTT()
+	}
+	return s.RTT()
+}
+
 func (s *Sample) Tags() string { return s.tags }
 func (s *Sample) AddTag(tag string) {
 	if s.tags == "" {
@@ -82,8 +133,18 @@ func (s *Sample) SetErr(err error) {
 	s.setRTT()
 }
 
+func (s *Sample) Errno() int                 { return s.get(keyErrno) }
+func (s *Sample) RTT() time.Duration         { return s.getDuration(keyRTTMicro) }
+func (s *Sample) ConnectTime() time.Duration { return s.getDuration(keyConnectMicro) }
+func (s *Sample) SendTime() time.Duration    { return s.getDuration(keySendMicro) }
+func (s *Sample) Latency() time.Duration     { return s.getDuration(keyLatencyMicro) }
+func (s *Sample) ReceiveTime() time.Duration { return s.getDuration(keyReceiveMicro) }
+func (s *Sample) RequestBytes() int          { return s.get(keyRequestBytes) }
+func (s *Sample) ResponseBytes() int         { return s.get(keyResponseBytes) }
+
 func (s *Sample) get(k int) int                      { return s.fields[k] }
 func (s *Sample) set(k, v int)                       { s.fields[k] = v }
+func (s *Sample) getDuration(k int) time.Duration    { return time.Duration(s.get(k)) * time.Microsecond }
 func (s *Sample) setDuration(k int, d time.Duration) { s.set(k, int(d.Nanoseconds()/1000)) }
 func (s *Sample) setRTT() {
 	if s.get(keyRTTMicro) == 0 {
@@ -131,6 +192,71 @@ func (s *Sample) String() string {
 	return string(appendPhout(s, nil, true))
 }
 
+// sampleJSON is JSON representation of Sample, that is written by jsonlines aggregator, and read
+// back by report. Field names are the same as phout column names. Durations are in microseconds.
+type sampleJSON struct {
+	Time          float64 `json:"time"` // Unix time in seconds.
+	Tag           string  `json:"tag"`
+	ID            uint64  `json:"id,omitempty"`
+	IntervalReal  int     `json:"interval_real"`
+	ConnectTime   int     `json:"connect_time"`
+	SendTime      int     `json:"send_time"`
+	Latency       int     `json:"latency"`
+	ReceiveTime   int     `json:"receive_time"`
+	IntervalEvent int     `json:"interval_event"`
+	SizeOut       int     `json:"size_out"`
+	SizeIn        int     `json:"size_in"`
+	NetCode       int     `json:"net_code"`
+	ProtoCode     int     `json:"proto_code"`
+	ScheduleLag   int     `json:"schedule_lag,omitempty"`
+}
+
+func (s *Sample) MarshalJSON() ([]byte, error) {
+	return json.Marshal(sampleJSON{
+		Time:          float64(s.timeStamp.UnixNano()/1e3) / 1e6,
+		Tag:           s.tags,
+		ID:            s.id,
+		IntervalReal:  s.get(keyRTTMicro),
+		ConnectTime:   s.get(keyConnectMicro),
+		SendTime:      s.get(keySendMicro),
+		Latency:       s.get(keyLatencyMicro),
+		ReceiveTime:   s.get(keyReceiveMicro),
+		IntervalEvent: s.get(keyIntervalEventMicro),
+		SizeOut:       s.get(keyRequestBytes),
+		SizeIn:        s.get(keyResponseBytes),
+		NetCode:       s.get(keyErrno),
+		ProtoCode:     s.get(keyProtoCode),
+		ScheduleLag:   int(s.ScheduleLag().Microseconds()),
+	})
+}
+
+func (s *Sample) UnmarshalJSON(data []byte) error {
+	var j sampleJSON
+	err := json.Unmarshal(data, &j)
+	if err != nil {
+		return err
+	}
+	*s = Sample{
+		timeStamp: time.UnixMicro(int64(math.Round(j.Time * 1e6))),
+		tags:      j.Tag,
+		id:        j.ID,
+	}
+	s.set(keyRTTMicro, j.IntervalReal)
+	s.set(keyConnectMicro, j.ConnectTime)
+	s.set(keySendMicro, j.SendTime)
+	s.set(keyLatencyMicro, j.Latency)
+	s.set(keyReceiveMicro, j.ReceiveTime)
+	s.set(keyIntervalEventMicro, j.IntervalEvent)
+	s.set(keyRequestBytes, j.SizeOut)
+	s.set(keyResponseBytes, j.SizeIn)
+	s.set(keyErrno, j.NetCode)
+	s.set(keyProtoCode, j.ProtoCode)
+	if j.ScheduleLag > 0 {
+		s.scheduled = s.timeStamp.Add(-time.Duration(j.ScheduleLag) * time.Microsecond)
+	}
+	return nil
+}
+
 func getErrno(err error) int {
 	//
 	if e, ok := err.(net.Error); ok && e.Timeout() {
@@ -6,6 +6,7 @@
 package netsample
 
 import (
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
@@ -19,6 +20,9 @@ import (
 	"github.com/facebookgo/stackerr"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yandex/pandora/core/aggregator"
+	"github.com/yandex/pandora/core/datasink"
 )
 
 func TestSampleBehaviour(t *testing.T) {
@@ -123,3 +127,89 @@ func BenchmarkAppendTimestamp(b *testing.B) {
 		}
 	})
 }
+
+func TestSampleClone(t *testing.T) {
+	sample := Acquire("tag")
+	sample.SetProtoCode(200)
+	clone := sample.Clone()
+	assert.NotSame(t, sample, clone)
+	assert.Equal(t, sample, clone)
+	clone.AddTag("other")
+	assert.Equal(t, "tag", sample.Tags())
+}
+
+func TestSampleJSON(t *testing.T) {
+	sample := newTestSample()
+	sample.setDuration(keyConnectMicro, time.Millisecond)
+	sample.set(keyResponseBytes, 100)
+	data, err := json.Marshal(sample)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"time":1484660999.002,"tag":"tag1|tag2","id":42,"interval_real":333333,"connect_time":1000,
+"send_time":0,"latency":0,"receive_time":0,"interval_event":0,"size_out":0,"size_in":100,"net_code":13,"proto_code":999}`,
+		string(data))
+
+	parsed := &Sample{}
+	require.NoError(t, json.Unmarshal(data, parsed))
+	assert.Equal(t, sample, parsed)
+	assert.Equal(t, sample.Timestamp().UnixNano(), parsed.Timestamp().UnixNano())
+}
+
+func TestSampleScheduleLag(t *testing.T) {
+	sample := newTestSample()
+	assert.Zero(t, sample.ScheduleLag(), "scheduled time is unknown")
+	assert.Equal(t, sample.RTT(), sample.ScheduledRTT())
+
+	sample.SetScheduledTime(sample.Timestamp().Add(-10 * time.Millisecond))
+	assert.Equal(t, 10*time.Millisecond, sample.ScheduleLag())
+	assert.Equal(t, sample.RTT()+10*time.Millisecond, sample.ScheduledRTT())
+
+	data, err := json.Marshal(sample)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"schedule_lag":10000`)
+	parsed := &Sample{}
+	require.NoError(t, json.Unmarshal(data, parsed))
+	assert.Equal(t, sample.ScheduleLag(), parsed.ScheduleLag())
+
+	sample.SetScheduledTime(sample.Timestamp().Add(time.Millisecond))
+	assert.Zero(t, sample.ScheduleLag(), "shoot is earlier than scheduled")
+}
+
+// TestSampleJSONLines checks sample line written by jsonlines aggregator encoder.
+func TestSampleJSONLines(t *testing.T) {
+	sample := newTestSample()
+	sample.setDuration(keyConnectMicro, time.Millisecond)
+	sink := datasink.NewBuffer()
+	enc := aggregator.NewJSONEncoder(sink, aggregator.JSONLineEncoderConfig{})
+	require.NoError(t, enc.Encode(sample))
+	require.NoError(t, enc.Flush())
+
+	line, err := sink.ReadBytes('\n')
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"time":1484660999.002,"tag":"tag1|tag2","id":42,"interval_real":333333,"connect_time":1000,
+"send_time":0,"latency":0,"receive_time":0,"interval_event":0,"size_out":0,"size_in":0,"net_code":13,"proto_code":999}`,
+		string(line))
+	assert.Zero(t, sink.Len())
+
+	parsed := &Sample{}
+	require.NoError(t, json.Unmarshal(line, parsed))
+	assert.Equal(t, sample.String(), parsed.String())
+}
+
+func TestParsePhout(t *testing.T) {
+	sample, err := ParsePhout(testSamplePhout)
+	require.NoError(t, err)
+	expected := newTestSample()
+	assert.Equal(t, expected.Timestamp().UnixNano(), sample.Timestamp().UnixNano())
+	sample.timeStamp = expected.timeStamp
+	assert.Equal(t, expected, sample)
+
+	sample, err = ParsePhout(testSampleNoIDPhout)
+	require.NoError(t, err)
+	assert.Equal(t, "tag1|tag2", sample.Tags())
+	assert.Zero(t, sample.ID())
+
+	_, err = ParsePhout("1484660999.002\ttag\t1")
+	assert.Error(t, err)
+	_, err = ParsePhout(strings.Replace(testSamplePhout, "333333", "x", 1))
+	assert.Error(t, err)
+}
@@ -0,0 +1,335 @@
+package netsample
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/yandex/pandora/core"
+	"github.com/yandex/pandora/core/aggregator"
+	"github.com/yandex/pandora/core/coreutil"
+	"github.com/yandex/pandora/lib/errutil"
+	"github.com/yandex/pandora/lib/histogram"
+)
+
+const (
+	summaryTotalKey = "total"
+	// summaryEmptyTag is tag, that HTTP guns set for untagged ammo.
+	summaryEmptyTag = "__EMPTY__"
+)
+
+type SummaryConfig struct {
+	// Sink receives human readable report.
+	Sink core.DataSink `config:"sink" validate:"required"`
+	// JSONSink optionally receives report in JSON.
+	JSONSink core.DataSink `config:"json-sink"`
+	// Quantiles of RTT in percents.
+	Quantiles []float64 `config:"quantiles" validate:"dive,min=0,max=100"`
+	// MaxTags limits number of tags, that have separate stats. Samples of other tags
+	// are accounted under "other" key. See coreutil.TagLimiter.
+	MaxTags int `config:"max-tags" validate:"min=1"`
+	// RTTFromSchedule makes RTT measured from scheduled shoot time, so queueing delay of late shoots
+	// is included. See Sample.ScheduledRTT.
+	RTTFromSchedule bool                      `config:"rtt-from-schedule"`
+	ReporterConfig  aggregator.ReporterConfig `config:",squash"`
+}
+
+func DefaultSummaryConfig() SummaryConfig {
+	return SummaryConfig{
+		Quantiles:      []float64{50, 90, 95, 99, 99.9},
+		MaxTags:        100,
+		ReporterConfig: aggregator.DefaultReporterConfig(),
+	}
+}
+
+// NewSummary returns aggregator, that keeps streaming RTT histograms and code counters
+// by sample tags, and writes summary report at the end of run.
+func NewSummary(conf SummaryConfig) Aggregator {
+	return &summaryAggregator{
+		Reporter: *aggregator.NewReporter(conf.ReporterConfig),
+		conf:     conf,
+		total:    newSummaryStats(),
+		tags:     map[string]*summaryStats{},
+		limiter:  coreutil.NewTagLimiter(conf.MaxTags),
+	}
+}
+
+type summaryAggregator struct {
+	aggregator.Reporter
+	conf    SummaryConfig
+	total   *summaryStats
+	tags    map[string]*summaryStats
+	limiter *coreutil.TagLimiter
+}
+
+func (a *summaryAggregator) Report(s *Sample) { a.Reporter.Report(s) }
+
+func (a *summaryAggregator) Run(ctx context.Context, _ core.AggregatorDeps) (err error) {
+	defer func() {
+		err = errutil.Join(err, a.DroppedErr())
+	}()
+loop:
+	for {
+		select {
+		case s := <-a.Incomming:
+			a.handle(s.(*Sample))
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	for {
+		// Context is done, but we should read all data from sink.
+		select {
+		case s := <-a.Incomming:
+			a.handle(s.(*Sample))
+		default:
+			return a.writeReports()
+		}
+	}
+}
+
+func (a *summaryAggregator) handle(s *Sample) {
+	rtt := sampleRTT(s, a.conf.RTTFromSchedule)
+	a.total.add(s, rtt)
+	tag := s.Tags()
+	if tag == summaryEmptyTag {
+		tag = ""
+	}
+	tag = a.limiter.Tag(tag)
+	stats, ok := a.tags[tag]
+	if !ok {
+		stats = newSummaryStats()
+		a.tags[tag] = stats
+	}
+	stats.add(s, rtt)
+	releaseSample(s)
+}
+
+func (a *summaryAggregator) writeReports() error {
+	report := a.report()
+	err := writeToSink(a.conf.Sink, func(w io.Writer) error {
+		return report.writeTable(w)
+	})
+	if err != nil {
+		return errors.WithMessage(err, "summary write failed")
+	}
+	if a.conf.JSONSink == nil {
+		return nil
+	}
+	err = writeToSink(a.conf.JSONSink, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	})
+	return errors.WithMessage(err, "summary JSON write failed")
+}
+
+func writeToSink(sink core.DataSink, write func(w io.Writer) error) (err error) {
+	w, err := sink.OpenSink()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errutil.Join(err, w.Close())
+	}()
+	return write(w)
+}
+
+type summaryStats struct {
+	count      int64
+	failed     int64
+	first      time.Time
+	last       time.Time
+	rtt        *histogram.Histogram // Microseconds.
+	protoCodes map[int]int64
+	netCodes   map[int]int64
+}
+
+func newSummaryStats() *summaryStats {
+	return &summaryStats{
+		rtt:        histogram.New(),
+		protoCodes: map[int]int64{},
+		netCodes:   map[int]int64{},
+	}
+}
+
+func (s *summaryStats) add(sample *Sample, rtt time.Duration) {
+	ts := sample.Timestamp()
+	if s.count == 0 || ts.Before(s.first) {
+		s.first = ts
+	}
+	if ts.After(s.last) {
+		s.last = ts
+	}
+	s.count++
+	errno, code := sample.Errno(), sample.ProtoCode()
+	if errno != 0 || code >= 500 {
+		s.failed++
+	}
+	if errno != 0 {
+		s.netCodes[errno]++
+	}
+	s.protoCodes[code]++
+	s.rtt.Record(rtt.Microseconds())
+}
+
+// SummaryReport is report written by summary aggregator in JSON.
+type SummaryReport struct {
+	Total SummaryStats            `json:"total"`
+	Tags  map[string]SummaryStats `json:"tags,omitempty"`
+}
+
+// SummaryStats is stats of samples with the same tag. Durations are in microseconds.
+type SummaryStats struct {
+	Count  int64   `json:"count"`
+	Failed int64   `json:"failed"`
+	RPS    float64 `json:"rps"`
+	RTT    struct {
+		Min       int64            `json:"min"`
+		Mean      float64          `json:"mean"`
+		Max       int64            `json:"max"`
+		Quantiles map[string]int64 `json:"quantiles"`
+	} `json:"rtt_us"`
+	ProtoCodes map[string]int64 `json:"proto_codes"`
+	NetCodes   map[string]int64 `json:"net_codes"`
+}
+
+func (a *summaryAggregator) report() *SummaryReport {
+	r := &SummaryReport{
+		Total: a.total.report(a.conf.Quantiles),
+		Tags:  map[string]SummaryStats{},
+	}
+	for tag, stats := range a.tags {
+		r.Tags[tag] = stats.report(a.conf.Quantiles)
+	}
+	if len(r.Tags) == 1 {
+		if _, ok := r.Tags[""]; ok {
+			// Samples are not tagged. Tags stats are the same as total.
+			r.Tags = nil
+		}
+	}
+	return r
+}
+
+func (s *summaryStats) report(quantiles []float64) SummaryStats {
+	r := SummaryStats{
+		Count:      s.count,
+		Failed:     s.failed,
+		ProtoCodes: codesReport(s.protoCodes),
+		NetCodes:   codesReport(s.netCodes),
+	}
+	if elapsed := s.last.Sub(s.first).Seconds(); elapsed >= 1 {
+		r.RPS = float64(s.count) / elapsed
+	} else {
+		r.RPS = float64(s.count)
+	}
+	r.RTT.Min = s.rtt.Min()
+	r.RTT.Mean = s.rtt.Mean()
+	r.RTT.Max = s.rtt.Max()
+	r.RTT.Quantiles = map[string]int64{}
+	for _, q := range quantiles {
+		r.RTT.Quantiles[formatQuantile(q)] = s.rtt.Quantile(q / 100)
+	}
+	return r
+}
+
+func codesReport(codes map[int]int64) map[string]int64 {
+	r := make(map[string]int64, len(codes))
+	for code, count := range codes {
+		r[strconv.Itoa(code)] = count
+	}
+	return r
+}
+
+func formatQuantile(q float64) string {
+	return strconv.FormatFloat(q, 'f', -1, 64)
+}
+
+func (r *SummaryReport) writeTable(w io.Writer) error {
+	rows := []string{summaryTotalKey}
+	stats := map[string]SummaryStats{summaryTotalKey: r.Total}
+	for _, tag := range sortedKeys(r.Tags) {
+		row := "tag " + tag
+		if tag == "" {
+			row = "tag -"
+		}
+		rows = append(rows, row)
+		stats[row] = r.Tags[tag]
+	}
+	quantiles := sortedKeys(r.Total.RTT.Quantiles)
+	sort.Slice(quantiles, func(i, j int) bool {
+		qi, _ := strconv.ParseFloat(quantiles[i], 64)
+		qj, _ := strconv.ParseFloat(quantiles[j], 64)
+		return qi < qj
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(tw, "\tcount\trps\tfailed\tmin\tmean")
+	for _, q := range quantiles {
+		fmt.Fprintf(tw, "\tq%s", q)
+	}
+	fmt.Fprint(tw, "\tmax\t\n")
+	for _, row := range rows {
+		s := stats[row]
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%s\t%s\t%s", row, s.Count, s.RPS,
+			formatShare(s.Failed, s.Count), formatMicros(float64(s.RTT.Min)), formatMicros(s.RTT.Mean))
+		for _, q := range quantiles {
+			fmt.Fprintf(tw, "\t%s", formatMicros(float64(s.RTT.Quantiles[q])))
+		}
+		fmt.Fprintf(tw, "\t%s\t\n", formatMicros(float64(s.RTT.Max)))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if err := writeCodesTable(w, "proto code", rows, stats, func(s SummaryStats) map[string]int64 {
+		return s.ProtoCodes
+	}); err != nil {
+		return err
+	}
+	if len(r.Total.NetCodes) == 0 {
+		return nil
+	}
+	return writeCodesTable(w, "net code", rows, stats, func(s SummaryStats) map[string]int64 {
+		return s.NetCodes
+	})
+}
+
+func writeCodesTable(w io.Writer, name string, rows []string, stats map[string]SummaryStats,
+	getCodes func(s SummaryStats) map[string]int64) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "\n\t%s\tcount\tshare\t\n", name)
+	for _, row := range rows {
+		s := stats[row]
+		codes := getCodes(s)
+		for _, code := range sortedKeys(codes) {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t\n", row, code, codes[code], formatShare(codes[code], s.Count))
+		}
+	}
+	return tw.Flush()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatMicros(us float64) string {
+	return strconv.FormatFloat(us/1000, 'f', 3, 64) + "ms"
+}
+
+func formatShare(n, total int64) string {
+	if total == 0 {
+		return "0.00%"
+	}
+	return strconv.FormatFloat(float64(n)*100/float64(total), 'f', 2, 64) + "%"
+}
@@ -0,0 +1,125 @@
+package netsample
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/yandex/pandora/core"
+	"github.com/yandex/pandora/core/datasink"
+)
+
+var _ = Describe("Summary", func() {
+	var (
+		conf     SummaryConfig
+		sink     *datasink.Buffer
+		jsonSink *datasink.Buffer
+		testee   Aggregator
+		start    time.Time
+	)
+	BeforeEach(func() {
+		sink = datasink.NewBuffer()
+		jsonSink = datasink.NewBuffer()
+		conf = DefaultSummaryConfig()
+		conf.Sink = sink
+		conf.JSONSink = jsonSink
+		start = time.Unix(1484660999, 0)
+	})
+	JustBeforeEach(func() {
+		testee = NewSummary(conf)
+	})
+	newSample := func(tag string, offset, rtt time.Duration, code, errno int) *Sample {
+		s := Acquire(tag)
+		s.timeStamp = start.Add(offset)
+		s.setDuration(keyRTTMicro, rtt)
+		s.set(keyProtoCode, code)
+		s.set(keyErrno, errno)
+		return s
+	}
+	run := func(samples ...*Sample) *SummaryReport {
+		for _, s := range samples {
+			testee.Report(s)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		Expect(testee.Run(ctx, core.AggregatorDeps{})).To(Succeed())
+		report := &SummaryReport{}
+		Expect(json.Unmarshal(jsonSink.Bytes(), report)).To(Succeed())
+		return report
+	}
+
+	It("aggregates by tags", func() {
+		var samples []*Sample
+		for i := 1; i <= 100; i++ {
+			samples = append(samples, newSample("a", time.Duration(i)*20*time.Millisecond, time.Duration(i)*time.Millisecond, 200, 0))
+		}
+		samples = append(samples,
+			newSample("b", 0, time.Second, 503, 0),
+			newSample("b", time.Second, 2*time.Second, ProtoCodeError, 110),
+		)
+		report := run(samples...)
+
+		Expect(report.Total.Count).To(BeEquivalentTo(102))
+		Expect(report.Total.Failed).To(BeEquivalentTo(2))
+		Expect(report.Total.ProtoCodes).To(Equal(map[string]int64{"200": 100, "503": 1, "999": 1}))
+		Expect(report.Total.NetCodes).To(Equal(map[string]int64{"110": 1}))
+		Expect(report.Total.RTT.Max).To(BeEquivalentTo(2000000))
+		Expect(report.Total.RPS).To(BeNumerically("~", 51, 0.01))
+
+		Expect(report.Tags).To(HaveLen(2))
+		a := report.Tags["a"]
+		Expect(a.Count).To(BeEquivalentTo(100))
+		Expect(a.Failed).To(BeZero())
+		Expect(a.RTT.Min).To(BeEquivalentTo(1000))
+		Expect(a.RTT.Mean).To(BeNumerically("~", 50500, 1))
+		Expect(a.RTT.Quantiles["50"]).To(BeNumerically("~", 50000, 50000/64))
+		Expect(a.RTT.Quantiles["99"]).To(BeNumerically("~", 99000, 99000/64))
+		Expect(a.RPS).To(BeNumerically("~", 100/1.98, 0.01))
+
+		table := sink.String()
+		Expect(table).To(ContainSubstring("q99.9"))
+		Expect(table).To(ContainSubstring("tag a"))
+		Expect(table).To(ContainSubstring("net code"))
+		Expect(table).To(MatchRegexp(`tag b\s+503\s+1\s+50.00%`))
+	})
+
+	Context("RTT from schedule", func() {
+		BeforeEach(func() {
+			conf.RTTFromSchedule = true
+		})
+		It("schedule lag is added to RTT", func() {
+			s := newSample("a", 0, time.Millisecond, 200, 0)
+			s.SetScheduledTime(s.Timestamp().Add(-time.Second))
+			report := run(s, newSample("a", 0, time.Millisecond, 200, 0))
+			Expect(report.Total.RTT.Min).To(BeEquivalentTo(1000))
+			Expect(report.Total.RTT.Max).To(BeEquivalentTo(1001000))
+		})
+	})
+
+	It("untagged samples have no tags breakdown", func() {
+		report := run(
+			newSample("", 0, time.Millisecond, 200, 0),
+			newSample("__EMPTY__", 0, time.Millisecond, 200, 0),
+		)
+		Expect(report.Total.Count).To(BeEquivalentTo(2))
+		Expect(report.Tags).To(BeEmpty())
+		Expect(sink.String()).NotTo(ContainSubstring("net code"))
+	})
+
+	Context("tags limit", func() {
+		BeforeEach(func() {
+			conf.MaxTags = 1
+		})
+		It("other tags are merged", func() {
+			report := run(
+				newSample("a", 0, time.Millisecond, 200, 0),
+				newSample("b", 0, time.Millisecond, 200, 0),
+				newSample("c", 0, time.Millisecond, 200, 0),
+			)
+			Expect(report.Tags).To(HaveLen(2))
+			Expect(report.Tags["other"].Count).To(BeEquivalentTo(2))
+		})
+	})
+})
@@ -0,0 +1,183 @@
+// Package autostop implements run level stop conditions, that are checked every second
+// against samples reported by all pools.
+package autostop
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/yandex/pandora/core"
+	"github.com/yandex/pandora/core/aggregator/netsample"
+	"github.com/yandex/pandora/core/register"
+	"github.com/yandex/pandora/lib/histogram"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+)
+
+// Criterion decides, whether test should be stopped.
+type Criterion interface {
+	// Check is called every second with stats of samples reported during that second.
+	// Returns non-nil error describing stop reason, if test should be stopped.
+	Check(s *Second) error
+}
+
+// Register registers autostop criterion plugin. Criterion constructor and default config follow
+// plugin package conventions.
+func Register(name string, newCriterion interface{}, defaultConfigOptional ...interface{}) {
+	var ptr *Criterion
+	register.RegisterPtr(ptr, name, newCriterion, defaultConfigOptional...)
+}
+
+// Second is stats of samples reported during one second.
+type Second struct {
+	// Active is true, if shooting has been active during the whole second. For example, it is false
+	// before start of pools, and while they are paused. See Autostop.SetActive.
+	Active bool
+	Count  int64
+	// Failed is number of samples with net error or proto code >= 500.
+	Failed     int64
+	ProtoCodes map[int]int64
+	// NetCodes counts samples by errno. Samples without net error are not counted.
+	NetCodes map[int]int64
+	RTT      *histogram.Histogram // Microseconds.
+}
+
+func newSecond() *Second {
+	return &Second{
+		ProtoCodes: map[int]int64{},
+		NetCodes:   map[int]int64{},
+		RTT:        histogram.New(),
+	}
+}
+
+// Sample is subset of netsample.Sample methods, that is required for criteria check.
+type Sample interface {
+	RTT() time.Duration
+	Errno() int
+	ProtoCode() int
+}
+
+func (s *Second) add(sample Sample) {
+	s.Count++
+	errno, code := sample.Errno(), sample.ProtoCode()
+	if errno != 0 {
+		s.NetCodes[errno]++
+	}
+	if errno != 0 || code >= 500 {
+		s.Failed++
+	}
+	s.ProtoCodes[code]++
+	s.RTT.Record(sample.RTT().Microseconds())
+}
+
+func (s *Second) merge(other *Second) {
+	s.Count += other.Count
+	s.Failed += other.Failed
+	for code, n := range other.ProtoCodes {
+		s.ProtoCodes[code] += n
+	}
+	for errno, n := range other.NetCodes {
+		s.NetCodes[errno] += n
+	}
+	s.RTT.Merge(other.RTT)
+}
+
+// secondShard is part of current second stats. Samples are spread over shards,
+// so concurrent instances rarely wait for each other.
+type secondShard struct {
+	mu      sync.Mutex
+	current *Second
+}
+
+// Error is returned from Autostop.Run, when one of criteria is triggered.
+type Error struct {
+	Err error
+}
+
+func (e *Error) Error() string { return "autostop: " + e.Err.Error() }
+func (e *Error) Unwrap() error { return e.Err }
+
+// Autostop gathers reported samples, and checks criteria every second.
+// Should be passed to engine as sample observer.
+type Autostop struct {
+	log      *zap.Logger
+	criteria []Criterion
+
+	next   atomic.Uint64
+	shards []secondShard
+
+	active     func() bool // Nil means always active.
+	lastActive bool        // Result of active on previous check.
+}
+
+func New(log *zap.Logger, criteria []Criterion) *Autostop {
+	shards := make([]secondShard, runtime.GOMAXPROCS(0))
+	for i := range shards {
+		shards[i].current = newSecond()
+	}
+	return &Autostop{
+		log:        log,
+		criteria:   criteria,
+		shards:     shards,
+		lastActive: true,
+	}
+}
+
+// SetActive sets function, that reports, whether shooting is active at the moment.
+// Second is Active, if shooting was active at its start and end. MUST be called before Run.
+func (a *Autostop) SetActive(active func() bool) {
+	a.active = active
+	a.lastActive = active()
+}
+
+func (a *Autostop) ObserveSample(sample core.Sample) {
+	s, ok := sample.(Sample)
+	if !ok || s.Errno() == netsample.DiscardedShootCodeError {
+		return
+	}
+	shard := &a.shards[a.next.Inc()%uint64(len(a.shards))]
+	shard.mu.Lock()
+	shard.current.add(s)
+	shard.mu.Unlock()
+}
+
+// Run checks criteria every second. Blocks until ctx is done, or criterion is triggered.
+// Returns *Error in case of trigger.
+func (a *Autostop) Run(ctx context.Context) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if err := a.check(); err != nil {
+			a.log.Error("Autostop criterion triggered", zap.Error(err))
+			return err
+		}
+	}
+}
+
+func (a *Autostop) check() error {
+	second := newSecond()
+	active := a.active == nil || a.active()
+	second.Active = active && a.lastActive
+	a.lastActive = active
+	for i := range a.shards {
+		shard := &a.shards[i]
+		shard.mu.Lock()
+		current := shard.current
+		shard.current = newSecond()
+		shard.mu.Unlock()
+		second.merge(current)
+	}
+	for _, c := range a.criteria {
+		if err := c.Check(second); err != nil {
+			return &Error{Err: err}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package autostop
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type testSample struct {
+	rtt       time.Duration
+	errno     int
+	protoCode int
+}
+
+func (s testSample) RTT() time.Duration { return s.rtt }
+func (s testSample) Errno() int         { return s.errno }
+func (s testSample) ProtoCode() int     { return s.protoCode }
+
+func newTestSecond(samples ...testSample) *Second {
+	s := newSecond()
+	s.Active = true
+	for _, sample := range samples {
+		s.add(sample)
+	}
+	return s
+}
+
+func repeat(n int, s testSample) []testSample {
+	samples := make([]testSample, n)
+	for i := range samples {
+		samples[i] = s
+	}
+	return samples
+}
+
+var (
+	ok200   = testSample{rtt: 10 * time.Millisecond, protoCode: 200}
+	err503  = testSample{rtt: 10 * time.Millisecond, protoCode: 503}
+	timeout = testSample{rtt: time.Second, errno: 110, protoCode: 999}
+)
+
+func TestThreshold(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected Threshold
+		n, total int64
+		exceeded bool
+	}{
+		{"10", Threshold{Value: 10}, 11, 100, true},
+		{"10", Threshold{Value: 10}, 10, 100, false},
+		{"10%", Threshold{Value: 10, Percent: true}, 11, 100, true},
+		{"10%", Threshold{Value: 10, Percent: true}, 10, 100, false},
+		{" 0.5% ", Threshold{Value: 0.5, Percent: true}, 1, 100, true},
+		{"0", Threshold{}, 1, 100, true},
+		{"0%", Threshold{Percent: true}, 0, 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			var th Threshold
+			require.NoError(t, th.UnmarshalText([]byte(test.text)))
+			assert.Equal(t, test.expected, th)
+			assert.Equal(t, test.exceeded, th.Exceeded(test.n, test.total))
+		})
+	}
+	for _, invalid := range []string{"", "x", "-1", "101%"} {
+		var th Threshold
+		assert.Error(t, th.UnmarshalText([]byte(invalid)), invalid)
+	}
+}
+
+func TestCodesMatcher(t *testing.T) {
+	m, err := parseCodes("5xx, 429")
+	require.NoError(t, err)
+	assert.True(t, m.match(500))
+	assert.True(t, m.match(599))
+	assert.True(t, m.match(429))
+	assert.False(t, m.match(404))
+	assert.False(t, m.match(5000))
+	assert.EqualValues(t, 3, m.count(map[int]int64{200: 10, 502: 2, 429: 1}))
+
+	_, err = parseCodes("5x?")
+	assert.Error(t, err)
+	_, err = parseCodes(" , ")
+	assert.Error(t, err)
+}
+
+func TestHTTPCodes(t *testing.T) {
+	c, err := NewHTTPCodes(HTTPCodesConfig{
+		Codes:     "5xx",
+		Threshold: Threshold{Value: 10, Percent: true},
+		Duration:  2 * time.Second,
+	})
+	require.NoError(t, err)
+	failing := newTestSecond(append(repeat(8, ok200), repeat(2, err503)...)...)
+	passing := newTestSecond(append(repeat(9, ok200), err503)...)
+	assert.NoError(t, c.Check(failing))
+	assert.NoError(t, c.Check(passing), "streak should be broken")
+	assert.NoError(t, c.Check(failing))
+	err = c.Check(failing)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "http codes 5xx exceeded 10% for 2s: 2 of 10")
+}
+
+func TestNetCodes(t *testing.T) {
+	c, err := NewNetCodes(NetCodesConfig{Duration: time.Second})
+	require.NoError(t, err)
+	assert.NoError(t, c.Check(newTestSecond(ok200, err503)))
+	assert.Error(t, c.Check(newTestSecond(ok200, timeout)))
+
+	c, err = NewNetCodes(NetCodesConfig{Codes: "111", Duration: time.Second})
+	require.NoError(t, err)
+	assert.NoError(t, c.Check(newTestSecond(timeout)))
+}
+
+func TestQuantile(t *testing.T) {
+	c := NewQuantile(QuantileConfig{Quantile: 90, Latency: 500 * time.Millisecond, Duration: time.Second})
+	assert.NoError(t, c.Check(newTestSecond(append(repeat(9, ok200), timeout)...)))
+	assert.NoError(t, c.Check(newTestSecond()))
+	err := c.Check(newTestSecond(append(repeat(8, ok200), timeout, timeout)...))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "q90 RTT 1s exceeded 500ms")
+}
+
+func TestTotalErrors(t *testing.T) {
+	c := NewTotalErrors(TotalErrorsConfig{Threshold: Threshold{Value: 2}})
+	assert.NoError(t, c.Check(newTestSecond(ok200, err503)))
+	assert.NoError(t, c.Check(newTestSecond(timeout)))
+	assert.Error(t, c.Check(newTestSecond(err503)))
+}
+
+func TestNoResponse(t *testing.T) {
+	c := NewNoResponse(NoResponseConfig{Duration: 2 * time.Second})
+	assert.NoError(t, c.Check(newTestSecond()))
+	assert.NoError(t, c.Check(newTestSecond(ok200)))
+	assert.NoError(t, c.Check(newTestSecond()))
+	assert.Error(t, c.Check(newTestSecond()))
+}
+
+func TestNoResponse_NotActive(t *testing.T) {
+	c := NewNoResponse(NoResponseConfig{Duration: 2 * time.Second})
+	notActive := newSecond()
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, c.Check(notActive))
+	}
+	assert.NoError(t, c.Check(newTestSecond()))
+	assert.Error(t, c.Check(newTestSecond()))
+}
+
+func TestAutostop_Active(t *testing.T) {
+	var seconds []*Second
+	a := New(zap.NewNop(), []Criterion{criterionFunc(func(s *Second) error {
+		seconds = append(seconds, s)
+		return nil
+	})})
+	active := false
+	a.SetActive(func() bool { return active })
+	for _, activeAtEnd := range []bool{false, true, true, false, true} {
+		active = activeAtEnd
+		require.NoError(t, a.check())
+	}
+	var got []bool
+	for _, s := range seconds {
+		got = append(got, s.Active)
+	}
+	assert.Equal(t, []bool{false, false, true, false, false}, got)
+}
+
+func TestAutostop(t *testing.T) {
+	c := NewTotalErrors(TotalErrorsConfig{Threshold: Threshold{Value: 1}})
+	a := New(zap.NewNop(), []Criterion{c})
+	a.ObserveSample(err503)
+	a.ObserveSample(struct{}{})
+	a.ObserveSample(testSample{errno: 777})
+	assert.NoError(t, a.check())
+	a.ObserveSample(err503)
+	err := a.check()
+	var stopErr *Error
+	require.True(t, errors.As(err, &stopErr))
+	assert.Contains(t, err.Error(), "autostop: total errors exceeded 1: 2 of 2 samples failed")
+}
+
+func TestAutostop_ConcurrentObserve(t *testing.T) {
+	var got *Second
+	a := New(zap.NewNop(), []Criterion{criterionFunc(func(s *Second) error {
+		got = s
+		return nil
+	})})
+	const goroutines, perGoroutine = 8, 1000
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perGoroutine; j++ {
+				a.ObserveSample(err503)
+				a.ObserveSample(testSample{protoCode: 200, rtt: time.Millisecond})
+			}
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, a.check())
+	const total = goroutines * perGoroutine
+	assert.EqualValues(t, 2*total, got.Count)
+	assert.EqualValues(t, total, got.Failed)
+	assert.Equal(t, map[int]int64{200: total, 503: total}, got.ProtoCodes)
+	assert.EqualValues(t, 2*total, got.RTT.Count())
+
+	require.NoError(t, a.check())
+	assert.EqualValues(t, 0, got.Count)
+}
+
+type criterionFunc func(s *Second) error
+
+func (f criterionFunc) Check(s *Second) error { return f(s) }
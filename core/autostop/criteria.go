@@ -0,0 +1,297 @@
+package autostop
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Threshold is absolute number of samples per second, or share of samples, if it has percent suffix.
+// Examples: "100", "10%", "0.5%". Zero threshold is exceeded by any matching sample.
+type Threshold struct {
+	Value   float64
+	Percent bool
+}
+
+func (t *Threshold) UnmarshalText(text []byte) error {
+	str := strings.TrimSpace(string(text))
+	percent := strings.HasSuffix(str, "%")
+	value, err := strconv.ParseFloat(strings.TrimSuffix(str, "%"), 64)
+	if err != nil || value < 0 || percent && value > 100 {
+		return fmt.Errorf("invalid threshold %q: expected non-negative number or percent", str)
+	}
+	*t = Threshold{Value: value, Percent: percent}
+	return nil
+}
+
+func (t Threshold) String() string {
+	value := strconv.FormatFloat(t.Value, 'f', -1, 64)
+	if t.Percent {
+		return value + "%"
+	}
+	return value
+}
+
+// Exceeded returns true, if n of total samples exceeds threshold.
+func (t Threshold) Exceeded(n, total int64) bool {
+	if !t.Percent {
+		return float64(n) > t.Value
+	}
+	return total > 0 && float64(n)*100 > t.Value*float64(total)
+}
+
+var thresholdType = reflect.TypeOf(Threshold{})
+
+// NumberToThresholdHook allows to set absolute threshold by YAML number, not only by string.
+func NumberToThresholdHook(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+	if t != thresholdType {
+		return data, nil
+	}
+	v := reflect.ValueOf(data)
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Threshold{Value: float64(v.Int())}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Threshold{Value: float64(v.Uint())}, nil
+	case reflect.Float32, reflect.Float64:
+		return Threshold{Value: v.Float()}, nil
+	}
+	return data, nil
+}
+
+// codesMatcher matches codes by comma separated list of patterns, like "5xx,404".
+// Pattern matches code of the same length, if every pattern digit is equal to code one, or is 'x'.
+type codesMatcher []string
+
+func parseCodes(codes string) (codesMatcher, error) {
+	var m codesMatcher
+	for _, pattern := range strings.Split(codes, ",") {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" {
+			continue
+		}
+		for _, r := range pattern {
+			if r != 'x' && (r < '0' || r > '9') {
+				return nil, fmt.Errorf("invalid code pattern %q", pattern)
+			}
+		}
+		m = append(m, pattern)
+	}
+	if len(m) == 0 {
+		return nil, fmt.Errorf("no code patterns in %q", codes)
+	}
+	return m, nil
+}
+
+func (m codesMatcher) match(code int) bool {
+	str := strconv.Itoa(code)
+	for _, pattern := range m {
+		if matchCode(pattern, str) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchCode(pattern, code string) bool {
+	if len(pattern) != len(code) {
+		return false
+	}
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != 'x' && pattern[i] != code[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (m codesMatcher) count(codes map[int]int64) (n int64) {
+	for code, c := range codes {
+		if m.match(code) {
+			n += c
+		}
+	}
+	return
+}
+
+// streak counts consecutive seconds, when condition holds.
+type streak struct {
+	need int
+	have int
+}
+
+func newStreak(d time.Duration) streak {
+	return streak{need: int(math.Ceil(d.Seconds()))}
+}
+
+func (s *streak) hit(ok bool) bool {
+	if !ok {
+		s.have = 0
+		return false
+	}
+	s.have++
+	return s.have >= s.need
+}
+
+type HTTPCodesConfig struct {
+	// Codes is comma separated list of code patterns, like "5xx,429".
+	Codes     string `validate:"required"`
+	Threshold Threshold
+	Duration  time.Duration `validate:"min-time=1s"`
+}
+
+// NewHTTPCodes returns criterion, that is triggered when number or share of samples with matching
+// proto codes exceeds threshold every second for duration.
+func NewHTTPCodes(conf HTTPCodesConfig) (Criterion, error) {
+	m, err := parseCodes(conf.Codes)
+	if err != nil {
+		return nil, err
+	}
+	return &codesCriterion{
+		name:     "http codes",
+		conf:     codesConf{conf.Codes, conf.Threshold, conf.Duration},
+		matcher:  m,
+		getCodes: func(s *Second) map[int]int64 { return s.ProtoCodes },
+		streak:   newStreak(conf.Duration),
+	}, nil
+}
+
+type NetCodesConfig struct {
+	// Codes is comma separated list of errno patterns, like "110,104". Empty means any net error.
+	Codes     string
+	Threshold Threshold
+	Duration  time.Duration `validate:"min-time=1s"`
+}
+
+// NewNetCodes returns criterion, that is triggered when number or share of samples with matching
+// errno exceeds threshold every second for duration.
+func NewNetCodes(conf NetCodesConfig) (Criterion, error) {
+	c := &codesCriterion{
+		name:     "net codes",
+		conf:     codesConf{conf.Codes, conf.Threshold, conf.Duration},
+		getCodes: func(s *Second) map[int]int64 { return s.NetCodes },
+		streak:   newStreak(conf.Duration),
+	}
+	if conf.Codes == "" {
+		c.conf.Codes = "any"
+		return c, nil
+	}
+	var err error
+	c.matcher, err = parseCodes(conf.Codes)
+	return c, err
+}
+
+type codesConf struct {
+	Codes     string
+	Threshold Threshold
+	Duration  time.Duration
+}
+
+type codesCriterion struct {
+	name     string
+	conf     codesConf
+	matcher  codesMatcher // Nil matches any code.
+	getCodes func(s *Second) map[int]int64
+	streak   streak
+}
+
+func (c *codesCriterion) Check(s *Second) error {
+	codes := c.getCodes(s)
+	var n int64
+	if c.matcher == nil {
+		for _, count := range codes {
+			n += count
+		}
+	} else {
+		n = c.matcher.count(codes)
+	}
+	if !c.streak.hit(c.conf.Threshold.Exceeded(n, s.Count)) {
+		return nil
+	}
+	return fmt.Errorf("%s %s exceeded %s for %v: %d of %d samples in last second",
+		c.name, c.conf.Codes, c.conf.Threshold, c.conf.Duration, n, s.Count)
+}
+
+type QuantileConfig struct {
+	// Quantile is percent of samples, that should have RTT less than Latency.
+	Quantile float64       `validate:"min=0,max=100"`
+	Latency  time.Duration `validate:"min-time=1us"`
+	Duration time.Duration `validate:"min-time=1s"`
+}
+
+func DefaultQuantileConfig() QuantileConfig {
+	return QuantileConfig{Quantile: 99}
+}
+
+// NewQuantile returns criterion, that is triggered when RTT quantile is greater than latency
+// every second for duration. Seconds without samples break the streak.
+func NewQuantile(conf QuantileConfig) Criterion {
+	return &quantileCriterion{conf: conf, streak: newStreak(conf.Duration)}
+}
+
+type quantileCriterion struct {
+	conf   QuantileConfig
+	streak streak
+}
+
+func (c *quantileCriterion) Check(s *Second) error {
+	rtt := time.Duration(s.RTT.Quantile(c.conf.Quantile/100)) * time.Microsecond
+	if !c.streak.hit(s.Count > 0 && rtt > c.conf.Latency) {
+		return nil
+	}
+	return fmt.Errorf("q%v RTT %v exceeded %v for %v", c.conf.Quantile, rtt, c.conf.Latency, c.conf.Duration)
+}
+
+type TotalErrorsConfig struct {
+	// Threshold of failed samples since test start. Percent threshold is share of all samples.
+	Threshold Threshold
+}
+
+// NewTotalErrors returns criterion, that is triggered when number or share of failed samples
+// since test start exceeds threshold. Failed samples are ones with net error or proto code >= 500.
+func NewTotalErrors(conf TotalErrorsConfig) Criterion {
+	return &totalErrorsCriterion{conf: conf}
+}
+
+type totalErrorsCriterion struct {
+	conf   TotalErrorsConfig
+	total  int64
+	failed int64
+}
+
+func (c *totalErrorsCriterion) Check(s *Second) error {
+	c.total += s.Count
+	c.failed += s.Failed
+	if !c.conf.Threshold.Exceeded(c.failed, c.total) {
+		return nil
+	}
+	return fmt.Errorf("total errors exceeded %s: %d of %d samples failed", c.conf.Threshold, c.failed, c.total)
+}
+
+type NoResponseConfig struct {
+	Duration time.Duration `validate:"min-time=1s"`
+}
+
+// NewNoResponse returns criterion, that is triggered when there is no samples for duration.
+// Only Active seconds are counted, so waiting for pools start and pauses don't trigger it.
+func NewNoResponse(conf NoResponseConfig) Criterion {
+	return &noResponseCriterion{conf: conf, streak: newStreak(conf.Duration)}
+}
+
+type noResponseCriterion struct {
+	conf   NoResponseConfig
+	streak streak
+}
+
+func (c *noResponseCriterion) Check(s *Second) error {
+	if !s.Active {
+		return nil
+	}
+	if !c.streak.hit(s.Count == 0) {
+		return nil
+	}
+	return fmt.Errorf("no responses for %v", c.conf.Duration)
+}
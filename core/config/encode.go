@@ -0,0 +1,130 @@
+package config
+
+import (
+	"encoding"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Encode converts struct into map, that decodes back into the same struct. It is inverse of Decode,
+// and is used to show plugin default configs. Map keys are taken from config tags.
+// Durations, URLs and types implementing encoding.TextMarshaler are encoded as strings.
+// Nil pointers, interfaces, funcs and chans are omitted: usually they are nested plugins,
+// that have no default.
+func Encode(conf interface{}) interface{} {
+	v, _ := encodeValue(reflect.ValueOf(conf))
+	return v
+}
+
+var (
+	durationType      = reflect.TypeOf(time.Duration(0))
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func encodeValue(v reflect.Value) (_ interface{}, ok bool) {
+	if !v.IsValid() {
+		return nil, false
+	}
+	switch {
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String(), true
+	case v.Type() == urlType:
+		u := v.Interface().(url.URL)
+		return u.String(), true
+	case v.Type().Implements(textMarshalerType) && (v.Kind() != reflect.Ptr || !v.IsNil()):
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return nil, false
+		}
+		return string(text), true
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, false
+		}
+		return encodeValue(v.Elem())
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return nil, false
+	case reflect.Struct:
+		m := map[string]interface{}{}
+		encodeStruct(v, m)
+		return m, true
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, false
+		}
+		m := map[string]interface{}{}
+		iter := v.MapRange()
+		for iter.Next() {
+			key, ok := encodeValue(iter.Key())
+			if !ok {
+				continue
+			}
+			keyStr, isString := key.(string)
+			if !isString {
+				continue
+			}
+			if elem, ok := encodeValue(iter.Value()); ok {
+				m[keyStr] = elem
+			}
+		}
+		return m, true
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, false
+		}
+		s := make([]interface{}, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			if elem, ok := encodeValue(v.Index(i)); ok {
+				s = append(s, elem)
+			}
+		}
+		return s, true
+	}
+	return v.Interface(), true
+}
+
+func encodeStruct(v reflect.Value, m map[string]interface{}) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue // Unexported.
+		}
+		name, squash := parseTag(field)
+		if name == "-" {
+			continue
+		}
+		fieldValue := v.Field(i)
+		if squash {
+			for fieldValue.Kind() == reflect.Ptr && !fieldValue.IsNil() {
+				fieldValue = fieldValue.Elem()
+			}
+			if fieldValue.Kind() == reflect.Struct {
+				encodeStruct(fieldValue, m)
+			}
+			continue
+		}
+		if encoded, ok := encodeValue(fieldValue); ok {
+			m[name] = encoded
+		}
+	}
+}
+
+// parseTag returns config key of field, and is it squashed, as mapstructure does.
+func parseTag(field reflect.StructField) (name string, squash bool) {
+	parts := strings.Split(field.Tag.Get(TagName), ",")
+	name = parts[0]
+	for _, opt := range parts[1:] {
+		if opt == "squash" {
+			squash = true
+		}
+	}
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	return
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+type encodeNested struct {
+	Size datasize.ByteSize `config:"size"`
+}
+
+type EncodeSquashed struct {
+	Level zapcore.Level `config:"level"`
+}
+
+type encodeTestConfig struct {
+	EncodeSquashed `config:",squash"`
+	Timeout        time.Duration           `config:"timeout"`
+	URL            url.URL                 `config:"url"`
+	IP             net.IP                  `config:"ip"`
+	Quantiles      []float64               `config:"quantiles"`
+	Nested         *encodeNested           `config:"nested"`
+	Tags           map[string]encodeNested `config:"tags"`
+	Untagged       string
+	NewPlugin      func() (interface{}, error) `config:"plugin"`
+	Skipped        string                      `config:"-"`
+	unexported     int
+}
+
+func TestEncode(t *testing.T) {
+	conf := encodeTestConfig{
+		EncodeSquashed: EncodeSquashed{Level: zapcore.DebugLevel},
+		Timeout:        1500 * time.Millisecond,
+		URL:            url.URL{Scheme: "http", Host: "localhost:8086", Path: "/write"},
+		IP:             net.ParseIP("127.0.0.1"),
+		Quantiles:      []float64{50, 99},
+		Nested:         &encodeNested{Size: 2 * datasize.KB},
+		Tags:           map[string]encodeNested{"a": {Size: datasize.MB}},
+		Untagged:       "value",
+		Skipped:        "skipped",
+		unexported:     1,
+	}
+	encoded := Encode(conf)
+	assert.Equal(t, map[string]interface{}{
+		"level":     "debug",
+		"timeout":   "1.5s",
+		"url":       "http://localhost:8086/write",
+		"ip":        "127.0.0.1",
+		"quantiles": []interface{}{50.0, 99.0},
+		"nested":    map[string]interface{}{"size": "2KB"},
+		"tags":      map[string]interface{}{"a": map[string]interface{}{"size": "1MB"}},
+		"untagged":  "value",
+	}, encoded)
+
+	var decoded encodeTestConfig
+	require.NoError(t, Decode(encoded, &decoded))
+	conf.Skipped, conf.unexported = "", 0
+	assert.Equal(t, conf, decoded)
+}
@@ -36,6 +36,15 @@ type ResettableAmmo interface {
 	Reset()
 }
 
+// ScheduledAmmo is optional Ammo interface. Instance sets time, when shoot of ammo was scheduled
+// by pool schedule, before Shoot. Gun sets it to samples of the shoot at their acquire, so latency
+// can be measured from intended shoot start. Scheduled time is zero, if it's not set.
+type ScheduledAmmo interface {
+	Ammo
+	ScheduledTime() time.Time
+	SetScheduledTime(t time.Time)
+}
+
 //go:generate mockery --name=Provider --case=underscore --outpkg=coremock
 
 // Provider is routine that generates ammo for Instance shoots.
@@ -59,6 +68,16 @@ type Provider interface {
 	Release(ammo Ammo)
 }
 
+// OrderedProvider is optional Provider interface, for Providers, which ammo order matters.
+// For example, ammo of recorded traffic, that is replayed by recorded schedule.
+// If AmmoOrdered returns true, and Pool RPS schedule is shared between Instances, Instances
+// Acquire ammo and take schedule tokens in the same order, so i'th acquired Ammo is shot at i'th
+// schedule time. Acquire calls are serialized in such case.
+type OrderedProvider interface {
+	Provider
+	AmmoOrdered() bool
+}
+
 // ProviderDeps are passed to Provider in Run.
 // WARN: another fields could be added in next MINOR versions.
 // That is NOT considered as a breaking compatibility change.
@@ -107,6 +126,9 @@ type GunDeps struct {
 	// Instance with lower InstanceId gets it's Ammo earlier.
 	InstanceID int
 	PoolID     string
+	// State is Instance state, shared between Gun shoots and lifecycle hooks.
+	// See lifecycle.InstanceStarter and lifecycle.InstanceStopper.
+	State *InstanceState
 
 	// TODO(skipor): https://github.com/yandex/pandora/issues/71
 	// Pass parallelism value. InstanceId MUST be -1 if parallelism > 1.
@@ -185,6 +207,51 @@ type Schedule interface {
 	Left() int
 }
 
+// ScheduleDeps are passed to Pool RPS Schedule, that implements BoundSchedule, before Start.
+// WARN: another fields could be added in next MINOR versions.
+// That is NOT considered as a breaking compatibility change.
+type ScheduleDeps struct {
+	Log *zap.Logger
+	// ScheduleTime returns moment of schedule timeline, that corresponds to passed real moment.
+	// Pool may run schedule on its own timeline, that is stopped on pause, and goes faster or
+	// slower than real time, when rate is changed. Schedule, that relates real moments, like
+	// sample scheduled time or current time, to its tokens, should map them with ScheduleTime.
+	// Nil means, that schedule timeline is real time.
+	ScheduleTime func(real time.Time) time.Time
+	// PerInstance is true, if Schedule is RPS schedule of single Instance in rps-per-instance mode.
+	// Otherwise, Schedule is shared by all Pool Instances.
+	PerInstance bool
+	// InstanceID is id of Instance, that Schedule belongs to. Zero, if Schedule is shared.
+	InstanceID int
+}
+
+// BoundSchedule is optional Schedule interface, for Schedules that need Pool dependencies.
+// Schedule, that is not bound, is not run by Pool. For example, it is planned by preview.
+type BoundSchedule interface {
+	Schedule
+	Bind(deps ScheduleDeps)
+}
+
+// DeferredSchedule is optional Schedule interface, for Schedules that may not know next token yet,
+// because it depends on future events, like shooting results. Pool calls TryNext instead of Next,
+// and waits retry moment itself, so it doesn't block other Schedule users and control of Pool.
+type DeferredSchedule interface {
+	Schedule
+	// TryNext is non-blocking Next. If next token is not known yet, returns zero ts, false ok, and
+	// moment of schedule timeline, after which TryNext should be called again. Otherwise, returns
+	// Next results and zero retryAt.
+	TryNext() (ts time.Time, ok bool, retryAt time.Time)
+}
+
+// InstanceStopSchedule is optional Schedule interface for Pool startup schedule, that can decrease
+// number of active Instances. Every token is either start of new Instance, or stop of one of
+// active Instances. Stopped Instance finishes shoot in flight and exits.
+type InstanceStopSchedule interface {
+	Schedule
+	// IsStop returns true, if token withdrawn by the last Next call is Instance stop.
+	IsStop() bool
+}
+
 //go:generate mockery --name=DataSource --case=underscore --outpkg=coremock
 
 // DataSource is abstract, ready to only open, source of data.
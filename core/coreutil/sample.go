@@ -5,7 +5,11 @@
 
 package coreutil
 
-import "github.com/yandex/pandora/core"
+import (
+	"context"
+
+	"github.com/yandex/pandora/core"
+)
 
 func ReturnSampleIfBorrowed(s core.Sample) {
 	borrowed, ok := s.(core.BorrowedSample)
@@ -14,3 +18,37 @@ func ReturnSampleIfBorrowed(s core.Sample) {
 	}
 	borrowed.Return()
 }
+
+// SampleObserver is notified about Samples reported to Aggregator.
+// Observer gets Sample before Aggregator, so Sample is valid during ObserveSample call,
+// but Observer MUST NOT modify Sample, or retain reference to it.
+// ObserveSample MUST be goroutine safe and SHOULD be lightweight, because it is called
+// in Instance goroutine.
+type SampleObserver interface {
+	ObserveSample(s core.Sample)
+}
+
+// NewObservedAggregator returns Aggregator that passes every reported Sample to observers,
+// before report it to wrapped Aggregator.
+func NewObservedAggregator(a core.Aggregator, observers ...SampleObserver) core.Aggregator {
+	if len(observers) == 0 {
+		return a
+	}
+	return &observedAggregator{a, observers}
+}
+
+type observedAggregator struct {
+	aggregator core.Aggregator
+	observers  []SampleObserver
+}
+
+func (a *observedAggregator) Run(ctx context.Context, deps core.AggregatorDeps) error {
+	return a.aggregator.Run(ctx, deps)
+}
+
+func (a *observedAggregator) Report(s core.Sample) {
+	for _, o := range a.observers {
+		o.ObserveSample(s)
+	}
+	a.aggregator.Report(s)
+}
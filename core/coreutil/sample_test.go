@@ -0,0 +1,30 @@
+package coreutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/yandex/pandora/core"
+	coremock "github.com/yandex/pandora/core/mocks"
+)
+
+type testObserver []core.Sample
+
+func (o *testObserver) ObserveSample(s core.Sample) { *o = append(*o, s) }
+
+func TestObservedAggregator(t *testing.T) {
+	aggr := &coremock.Aggregator{}
+	assert.Equal(t, aggr, NewObservedAggregator(aggr))
+
+	var o1, o2 testObserver
+	testee := NewObservedAggregator(aggr, &o1, &o2)
+	aggr.On("Report", mock.Anything).Run(func(args mock.Arguments) {
+		assert.Len(t, o1, 1)
+		assert.Len(t, o2, 1)
+	}).Once()
+	testee.Report(1)
+	aggr.AssertExpectations(t)
+	assert.Equal(t, testObserver{1}, o1)
+	assert.Equal(t, testObserver{1}, o2)
+}
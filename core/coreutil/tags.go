@@ -0,0 +1,28 @@
+package coreutil
+
+// OtherTag is tag, that samples of tags over TagLimiter limit are accounted under.
+const OtherTag = "other"
+
+// TagLimiter limits number of sample tags, that have separate stats, so stats memory and
+// number of metric series are bounded, when tags are generated. It's not goroutine safe.
+type TagLimiter struct {
+	max  int
+	tags map[string]struct{}
+}
+
+// NewTagLimiter returns limiter, that passes max first tags. Max should be positive.
+func NewTagLimiter(max int) *TagLimiter {
+	return &TagLimiter{max: max, tags: map[string]struct{}{}}
+}
+
+// Tag returns tag, if it has been passed before, or limit is not reached yet. Otherwise returns OtherTag.
+func (l *TagLimiter) Tag(tag string) string {
+	if _, ok := l.tags[tag]; ok {
+		return tag
+	}
+	if len(l.tags) >= l.max {
+		return OtherTag
+	}
+	l.tags[tag] = struct{}{}
+	return tag
+}
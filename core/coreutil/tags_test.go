@@ -0,0 +1,16 @@
+package coreutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTagLimiter(t *testing.T) {
+	l := NewTagLimiter(2)
+	assert.Equal(t, "a", l.Tag("a"))
+	assert.Equal(t, "", l.Tag(""))
+	assert.Equal(t, OtherTag, l.Tag("b"))
+	assert.Equal(t, "a", l.Tag("a"), "passed tag is passed after limit")
+	assert.Equal(t, OtherTag, l.Tag("c"))
+}
@@ -17,6 +17,7 @@ type Waiter struct {
 	sched         core.Schedule
 	ctx           context.Context
 	slowDownItems int
+	scheduled     time.Time
 
 	// Lazy initialized.
 	timer   *time.Timer
@@ -43,6 +44,7 @@ func (w *Waiter) Wait() (ok bool) {
 		w.slowDownItems = 0
 		return false
 	}
+	w.scheduled = next
 	// Get current time lazily.
 	// For once schedule, for example, we need to get it only once.
 	if next.Before(w.lastNow) {
@@ -70,6 +72,12 @@ func (w *Waiter) Wait() (ok bool) {
 	}
 }
 
+// Scheduled returns schedule time of last successfully waited event.
+// It is earlier than current time, if waiter is late.
+func (w *Waiter) Scheduled() time.Time {
+	return w.scheduled
+}
+
 // IsSlowDown returns true, if schedule contains 2 elements before current time.
 func (w *Waiter) IsSlowDown() (ok bool) {
 	select {
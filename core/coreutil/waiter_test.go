@@ -44,6 +44,20 @@ func TestWaiter_WaitAsExpected(t *testing.T) {
 	require.True(t, dur >= duration*(times-1)/times)
 	require.True(t, dur < 3*duration) // Smaller interval will be more flaky.
 }
+
+func TestWaiter_Scheduled(t *testing.T) {
+	sched := schedule.NewConst(10, time.Second)
+	start := time.Now().Add(-time.Second)
+	sched.Start(start)
+	w := NewWaiter(sched, context.Background())
+	require.True(t, w.Wait())
+	require.Equal(t, start, w.Scheduled())
+	require.False(t, w.IsSlowDown()) // Only one late token.
+	require.True(t, w.Wait())
+	require.Equal(t, start.Add(100*time.Millisecond), w.Scheduled())
+	require.True(t, w.IsSlowDown())
+}
+
 func TestWaiter_ContextCanceledBeforeWait(t *testing.T) {
 	sched := schedule.NewOnce(1)
 	ctx, cancel := context.WithCancel(context.Background())
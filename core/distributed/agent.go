@@ -0,0 +1,208 @@
+package distributed
+
+import (
+	"context"
+	"io"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/yandex/pandora/core"
+	"github.com/yandex/pandora/core/aggregator/netsample"
+	"github.com/yandex/pandora/core/engine"
+	"go.uber.org/zap"
+)
+
+// DecodeFunc decodes Pandora config into engine config.
+type DecodeFunc func(conf map[string]interface{}) (engine.Config, error)
+
+// Agent runs part of config load, received from coordinator, and forwards reported
+// samples back to it.
+type Agent struct {
+	log     *zap.Logger
+	metrics engine.Metrics
+	decode  DecodeFunc
+
+	mu       sync.Mutex
+	conf     *engine.Config
+	started  bool
+	cancel   context.CancelFunc
+	stopped  bool
+	finished chan struct{}
+	samples  [][]*netsample.Sample
+	dropped  int
+	done     bool
+	err      error
+}
+
+func NewAgent(log *zap.Logger, m engine.Metrics, decode DecodeFunc) *Agent {
+	return &Agent{
+		log:      log,
+		metrics:  m,
+		decode:   decode,
+		finished: make(chan struct{}),
+	}
+}
+
+// Serve serves coordinator RPC on conn, until conn is closed by coordinator.
+// Shooting is canceled and awaited, if coordinator has closed conn before agent is done.
+func (a *Agent) Serve(conn io.ReadWriteCloser) {
+	server := rpc.NewServer()
+	_ = server.RegisterName("Agent", &agentRPC{a})
+	server.ServeCodec(jsonrpc.NewServerCodec(conn))
+	a.mu.Lock()
+	started := a.started
+	a.stopLocked()
+	a.mu.Unlock()
+	if started {
+		<-a.finished
+	}
+}
+
+func (a *Agent) prepare(args *PrepareArgs) error {
+	if args.Parts <= 0 || args.Part < 0 || args.Part >= args.Parts {
+		return errors.Errorf("invalid agent part %v of %v", args.Part, args.Parts)
+	}
+	conf, err := a.decode(args.Config)
+	if err != nil {
+		return errors.WithMessage(err, "config decode failed")
+	}
+	for i, pool := range conf.Pools {
+		pool = SplitPool(pool, args.Part, args.Parts)
+		pool.Aggregator = &forwardAggregator{agent: a, pool: i}
+		conf.Pools[i] = pool
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.started {
+		return errors.New("agent is already started")
+	}
+	a.conf = &conf
+	a.samples = make([][]*netsample.Sample, len(conf.Pools))
+	return nil
+}
+
+func (a *Agent) start(args *StartArgs) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	switch {
+	case a.conf == nil:
+		return errors.New("agent is not prepared")
+	case a.started:
+		return errors.New("agent is already started")
+	case a.stopped:
+		return errors.New("agent is stopped")
+	}
+	a.started = true
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancel = cancel
+	go a.run(ctx, args.StartAt)
+	return nil
+}
+
+func (a *Agent) run(ctx context.Context, startAt time.Time) {
+	defer close(a.finished)
+	wait := time.Until(startAt)
+	if wait < 0 {
+		a.log.Warn("Start time has already passed", zap.Duration("late", -wait))
+	}
+	var err error
+	select {
+	case <-time.After(wait):
+		a.log.Info("Agent shooting started")
+		pandora := engine.New(a.log, a.metrics, *a.conf)
+		err = pandora.Run(ctx)
+		pandora.Wait()
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.stopped {
+		err = nil
+	}
+	if err != nil {
+		a.log.Error("Agent shooting failed", zap.Error(err))
+	} else {
+		a.log.Info("Agent shooting finished")
+	}
+	a.done, a.err = true, err
+}
+
+func (a *Agent) poll(reply *PollReply) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	reply.Done = a.done
+	if a.err != nil {
+		reply.Err = a.err.Error()
+	}
+	reply.Samples = a.samples
+	reply.Dropped = a.dropped
+	a.samples = make([][]*netsample.Sample, len(a.samples))
+	a.dropped = 0
+}
+
+func (a *Agent) stopLocked() {
+	a.stopped = true
+	if a.cancel != nil {
+		a.cancel()
+	}
+	if !a.started {
+		// Nothing to wait for.
+		a.done = true
+	}
+}
+
+func (a *Agent) report(pool int, s core.Sample) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	sample, ok := s.(*netsample.Sample)
+	if !ok {
+		a.dropped++
+		return
+	}
+	a.samples[pool] = append(a.samples[pool], sample)
+}
+
+// agentRPC is Agent RPC service. It is separated from Agent, because net/rpc registers
+// all exported methods.
+type agentRPC struct {
+	agent *Agent
+}
+
+func (r *agentRPC) Prepare(args *PrepareArgs, _ *struct{}) error {
+	return r.agent.prepare(args)
+}
+
+func (r *agentRPC) Start(args *StartArgs, _ *struct{}) error {
+	return r.agent.start(args)
+}
+
+func (r *agentRPC) Poll(_ *struct{}, reply *PollReply) error {
+	r.agent.poll(reply)
+	return nil
+}
+
+func (r *agentRPC) Stop(_ *struct{}, _ *struct{}) error {
+	r.agent.mu.Lock()
+	defer r.agent.mu.Unlock()
+	r.agent.stopLocked()
+	return nil
+}
+
+// forwardAggregator replaces pool aggregator on agent, and buffers samples until coordinator polls them.
+type forwardAggregator struct {
+	agent *Agent
+	pool  int
+}
+
+func (a *forwardAggregator) Run(ctx context.Context, _ core.AggregatorDeps) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (a *forwardAggregator) Report(s core.Sample) {
+	a.agent.report(a.pool, s)
+}
@@ -0,0 +1,183 @@
+package distributed
+
+import (
+	"context"
+	"fmt"
+	"net/rpc"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/yandex/pandora/core"
+	"go.uber.org/zap"
+)
+
+type CoordinatorConfig struct {
+	// StartDelay is time between all agents are prepared and start of shooting.
+	// It should be greater than RPC latency, so all agents start at the same time.
+	StartDelay time.Duration
+	// PollInterval is interval of agents samples polling.
+	PollInterval time.Duration
+}
+
+func DefaultCoordinatorConfig() CoordinatorConfig {
+	return CoordinatorConfig{
+		StartDelay:   time.Second,
+		PollInterval: 100 * time.Millisecond,
+	}
+}
+
+// Coordinator splits config load between agents, and reports samples, received from agents,
+// to pool aggregators, so there is one merged result of every pool.
+type Coordinator struct {
+	log         *zap.Logger
+	conf        CoordinatorConfig
+	agents      []*rpc.Client
+	aggregators []core.Aggregator
+}
+
+// NewCoordinator returns coordinator of agents, connected via clients created by NewClient or Dial.
+// Aggregators are pool aggregators, by pool index in config.
+func NewCoordinator(log *zap.Logger, conf CoordinatorConfig, agents []*rpc.Client, aggregators []core.Aggregator) *Coordinator {
+	return &Coordinator{
+		log:         log,
+		conf:        conf,
+		agents:      agents,
+		aggregators: aggregators,
+	}
+}
+
+// Run prepares config on all agents, starts them at the same time and awaits they are done.
+// If some agent prepare fails, already prepared agents are stopped. Run fails, if agents were
+// not started before start time, because agents would not shoot at the same time then.
+// Samples are reported to aggregators, until all agents are done.
+// If ctx is canceled, agents are stopped, and their remaining samples are awaited.
+func (c *Coordinator) Run(ctx context.Context, config map[string]interface{}) error {
+	if len(c.agents) == 0 {
+		return errors.New("no agents")
+	}
+	for i, agent := range c.agents {
+		args := &PrepareArgs{Config: config, Part: i, Parts: len(c.agents)}
+		err := agent.Call("Agent.Prepare", args, &struct{}{})
+		if err != nil {
+			c.stopAgents(c.agents[:i])
+			return errors.WithMessage(err, fmt.Sprintf("agent %v prepare failed", i))
+		}
+	}
+
+	aggregatorsCtx, cancelAggregators := context.WithCancel(context.Background())
+	defer cancelAggregators()
+	aggregatorErrs := make(chan error, len(c.aggregators))
+	for _, aggregator := range c.aggregators {
+		aggregator := aggregator
+		go func() {
+			aggregatorErrs <- aggregator.Run(aggregatorsCtx, core.AggregatorDeps{Log: c.log})
+		}()
+	}
+
+	startAt := time.Now().Add(c.conf.StartDelay)
+	c.log.Info("Starting agents", zap.Int("agents", len(c.agents)), zap.Time("start_at", startAt))
+	var runErr error
+	for i, agent := range c.agents {
+		err := agent.Call("Agent.Start", &StartArgs{StartAt: startAt}, &struct{}{})
+		if err != nil {
+			runErr = errors.WithMessage(err, fmt.Sprintf("agent %v start failed", i))
+			c.stopAgents(c.agents)
+			break
+		}
+	}
+	if late := time.Since(startAt); runErr == nil && late > 0 {
+		runErr = errors.Errorf("agents were started %v after start time; start delay should be increased", late)
+		c.stopAgents(c.agents)
+	}
+	err := c.poll(ctx)
+	if runErr == nil {
+		runErr = err
+	}
+
+	cancelAggregators()
+	for range c.aggregators {
+		err := <-aggregatorErrs
+		if err != nil && runErr == nil {
+			runErr = errors.WithMessage(err, "aggregator failed")
+		}
+	}
+	return runErr
+}
+
+func (c *Coordinator) poll(ctx context.Context) error {
+	ticker := time.NewTicker(c.conf.PollInterval)
+	defer ticker.Stop()
+	var (
+		runErr  error
+		stopped bool
+		done    = make([]bool, len(c.agents))
+		left    = len(c.agents)
+		ctxDone = ctx.Done()
+	)
+	stop := func(err error) {
+		if runErr == nil {
+			runErr = err
+		}
+		if !stopped {
+			stopped = true
+			c.stopAgents(c.agents)
+		}
+	}
+	for left > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctxDone:
+			// Remaining samples are polled after stop.
+			ctxDone = nil
+			stop(ctx.Err())
+		}
+		for i, agent := range c.agents {
+			if done[i] {
+				continue
+			}
+			var reply PollReply
+			err := agent.Call("Agent.Poll", &struct{}{}, &reply)
+			if err != nil {
+				// Agent is lost, so there is nothing to wait for.
+				done[i] = true
+				left--
+				stop(errors.WithMessage(err, fmt.Sprintf("agent %v poll failed", i)))
+				continue
+			}
+			c.report(i, &reply)
+			if reply.Done {
+				done[i] = true
+				left--
+				if reply.Err != "" {
+					stop(errors.Errorf("agent %v failed: %s", i, reply.Err))
+				}
+			}
+		}
+	}
+	return runErr
+}
+
+func (c *Coordinator) report(agent int, reply *PollReply) {
+	if reply.Dropped > 0 {
+		c.log.Warn("Agent dropped samples, that can't be sent", zap.Int("agent", agent), zap.Int("dropped", reply.Dropped))
+	}
+	for pool, samples := range reply.Samples {
+		if pool >= len(c.aggregators) {
+			c.log.Error("Samples of unknown pool received", zap.Int("agent", agent), zap.Int("pool", pool))
+			continue
+		}
+		for _, s := range samples {
+			c.aggregators[pool].Report(s)
+		}
+	}
+}
+
+func (c *Coordinator) stopAgents(agents []*rpc.Client) {
+	c.log.Info("Stopping agents")
+	for i, agent := range agents {
+		err := agent.Call("Agent.Stop", &struct{}{}, &struct{}{})
+		if err != nil {
+			c.log.Warn("Agent stop failed", zap.Int("agent", i), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package distributed
+
+import (
+	"context"
+	"net"
+	"net/rpc"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yandex/pandora/core"
+	"github.com/yandex/pandora/core/aggregator"
+	"github.com/yandex/pandora/core/aggregator/netsample"
+	"github.com/yandex/pandora/core/engine"
+	"github.com/yandex/pandora/core/provider"
+	"github.com/yandex/pandora/core/schedule"
+	"github.com/yandex/pandora/lib/monitoring"
+	"go.uber.org/zap"
+)
+
+type testGun struct {
+	aggr core.Aggregator
+}
+
+func (g *testGun) Bind(aggr core.Aggregator, _ core.GunDeps) error {
+	g.aggr = aggr
+	return nil
+}
+
+func (g *testGun) Shoot(core.Ammo) {
+	g.aggr.Report(netsample.Acquire("tag"))
+}
+
+// testDecode returns config of pool, that makes "shots" shots at once.
+func testDecode(conf map[string]interface{}) (engine.Config, error) {
+	shots, ok := conf["shots"].(float64)
+	if !ok {
+		return engine.Config{}, errors.New("no shots")
+	}
+	return engine.Config{Pools: []engine.InstancePoolConfig{{
+		ID:              "pool",
+		Provider:        provider.NewNum(-1),
+		Aggregator:      aggregator.NewTest(),
+		NewGun:          func() (core.Gun, error) { return &testGun{}, nil },
+		NewRPSSchedule:  func() (core.Schedule, error) { return schedule.NewOnce(int64(shots)), nil },
+		StartupSchedule: schedule.NewOnce(2),
+	}}}, nil
+}
+
+func testMetrics() engine.Metrics {
+	return engine.Metrics{
+		Request:        &monitoring.Counter{},
+		Response:       &monitoring.Counter{},
+		InstanceStart:  &monitoring.Counter{},
+		InstanceFinish: &monitoring.Counter{},
+	}
+}
+
+func startAgents(t *testing.T, n int) []*rpc.Client {
+	var clients []*rpc.Client
+	for i := 0; i < n; i++ {
+		clients = append(clients, startAgent(t, testDecode))
+	}
+	return clients
+}
+
+func startAgent(t *testing.T, decode DecodeFunc) *rpc.Client {
+	agentConn, coordinatorConn := net.Pipe()
+	agent := NewAgent(zap.NewNop(), testMetrics(), decode)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		agent.Serve(agentConn)
+	}()
+	client := NewClient(coordinatorConn)
+	t.Cleanup(func() {
+		_ = client.Close()
+		<-done
+	})
+	return client
+}
+
+func testCoordinatorConfig() CoordinatorConfig {
+	return CoordinatorConfig{StartDelay: 10 * time.Millisecond, PollInterval: 10 * time.Millisecond}
+}
+
+func TestCoordinator_Run(t *testing.T) {
+	aggr := aggregator.NewTest()
+	coordinator := NewCoordinator(zap.NewNop(), testCoordinatorConfig(), startAgents(t, 3), []core.Aggregator{aggr})
+	err := coordinator.Run(context.Background(), map[string]interface{}{"shots": 10})
+	require.NoError(t, err)
+	samples := aggr.GetSamples()
+	assert.Len(t, samples, 10)
+	for _, s := range samples {
+		assert.Equal(t, "tag", s.(*netsample.Sample).Tags())
+	}
+}
+
+func TestCoordinator_Run_PrepareFailed(t *testing.T) {
+	coordinator := NewCoordinator(zap.NewNop(), testCoordinatorConfig(), startAgents(t, 2), []core.Aggregator{aggregator.NewTest()})
+	err := coordinator.Run(context.Background(), map[string]interface{}{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "agent 0 prepare failed")
+}
+
+func TestCoordinator_Run_PrepareFailedStopsPrepared(t *testing.T) {
+	failDecode := func(map[string]interface{}) (engine.Config, error) { return engine.Config{}, errors.New("bad config") }
+	clients := []*rpc.Client{startAgent(t, testDecode), startAgent(t, failDecode)}
+	coordinator := NewCoordinator(zap.NewNop(), testCoordinatorConfig(), clients, []core.Aggregator{aggregator.NewTest()})
+	err := coordinator.Run(context.Background(), map[string]interface{}{"shots": 10})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "agent 1 prepare failed")
+
+	err = clients[0].Call("Agent.Start", &StartArgs{StartAt: time.Now()}, &struct{}{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "agent is stopped")
+	var reply PollReply
+	require.NoError(t, clients[0].Call("Agent.Poll", &struct{}{}, &reply))
+	assert.True(t, reply.Done)
+}
+
+func TestCoordinator_Run_StartedLate(t *testing.T) {
+	coordinator := NewCoordinator(zap.NewNop(), CoordinatorConfig{PollInterval: 10 * time.Millisecond},
+		startAgents(t, 2), []core.Aggregator{aggregator.NewTest()})
+	err := coordinator.Run(context.Background(), map[string]interface{}{"shots": 10})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "start delay should be increased")
+}
+
+func TestCoordinator_Run_Canceled(t *testing.T) {
+	clients := startAgents(t, 2)
+	coordinator := NewCoordinator(zap.NewNop(), CoordinatorConfig{StartDelay: time.Hour, PollInterval: 10 * time.Millisecond},
+		clients, []core.Aggregator{aggregator.NewTest()})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := coordinator.Run(ctx, map[string]interface{}{"shots": 10})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestSplitPool(t *testing.T) {
+	conf := engine.InstancePoolConfig{
+		NewRPSSchedule:  func() (core.Schedule, error) { return schedule.NewOnce(5), nil },
+		StartupSchedule: schedule.NewOnce(5),
+	}
+	left := func(conf engine.InstancePoolConfig) (rps, instances int) {
+		s, err := conf.NewRPSSchedule()
+		require.NoError(t, err)
+		return s.Left(), conf.StartupSchedule.Left()
+	}
+
+	rps, instances := left(SplitPool(conf, 0, 2))
+	assert.Equal(t, 3, rps)
+	assert.Equal(t, 5, instances)
+
+	conf.RPSPerInstance = true
+	rps, instances = left(SplitPool(conf, 1, 2))
+	assert.Equal(t, 5, rps)
+	assert.Equal(t, 2, instances)
+}
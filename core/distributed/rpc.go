@@ -0,0 +1,48 @@
+package distributed
+
+import (
+	"io"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"time"
+
+	"github.com/yandex/pandora/core/aggregator/netsample"
+)
+
+// Agent RPC is JSON-RPC 1.0 over connection opened by coordinator.
+// Coordinator calls Agent.Prepare, then Agent.Start on all agents, and polls samples with Agent.Poll
+// until agent is done. Agent.Stop cancels shooting.
+
+type PrepareArgs struct {
+	// Config is whole Pandora config. It is decoded by agent, and checked as on run.
+	Config map[string]interface{}
+	// Part is index of agent in [0, Parts). Pool load is split between agents by SplitPool.
+	Part  int
+	Parts int
+}
+
+type StartArgs struct {
+	// StartAt is time, when agent should start shooting. It is the same for all agents.
+	StartAt time.Time
+}
+
+type PollReply struct {
+	// Samples are samples reported since previous poll by pool index.
+	Samples [][]*netsample.Sample
+	// Dropped is number of samples, that were not netsample.Sample, so can't be sent.
+	Dropped int
+	// Done is true, when shooting is finished and all samples have been polled.
+	Done bool
+	// Err is shooting error. Empty, if shooting succeeded or has been stopped.
+	Err string
+}
+
+// NewClient returns agent RPC client on conn.
+func NewClient(conn io.ReadWriteCloser) *rpc.Client {
+	return jsonrpc.NewClient(conn)
+}
+
+// Dial connects to agent listening on addr.
+func Dial(addr string) (*rpc.Client, error) {
+	return jsonrpc.Dial("tcp", addr)
+}
@@ -0,0 +1,28 @@
+package distributed
+
+import (
+	"github.com/yandex/pandora/core"
+	"github.com/yandex/pandora/core/engine"
+	"github.com/yandex/pandora/core/schedule"
+)
+
+// SplitPool returns config of pool load part, so parts of all agents make up whole pool load.
+// Shared RPS schedule is split by tokens: agent makes every parts-th shot. Instances are not
+// split in that case, so every agent has enough instances to make whole pool RPS.
+// Instances of rps-per-instance and closed-loop pools are split instead: agent starts every
+// parts-th instance, so startup schedule should start at least parts instances.
+func SplitPool(conf engine.InstancePoolConfig, part, parts int) engine.InstancePoolConfig {
+	if conf.RPSPerInstance || conf.ClosedLoop != nil {
+		conf.StartupSchedule = schedule.NewPart(conf.StartupSchedule, part, parts)
+		return conf
+	}
+	newRPSSchedule := conf.NewRPSSchedule
+	conf.NewRPSSchedule = func() (core.Schedule, error) {
+		s, err := newRPSSchedule()
+		if err != nil {
+			return nil, err
+		}
+		return schedule.NewPart(s, part, parts), nil
+	}
+	return conf
+}
@@ -0,0 +1,11 @@
+package dryrun
+
+import "github.com/yandex/pandora/core"
+
+// Gun is optional core.Gun interface, that is used instead of Shoot in engine dry-run mode.
+type Gun interface {
+	// DryShoot does everything Shoot does except sending: ammo is decoded, requests are built
+	// and templated. Returns tags of samples, that would be reported by Shoot.
+	// Gun is bound before DryShoot calls.
+	DryShoot(ammo core.Ammo) (tags []string, err error)
+}
@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/yandex/pandora/core"
+)
+
+func (p *instancePool) buildClosedLoopSchedule(finish time.Time) func(int) (core.Schedule, error) {
+	thinkTime := p.ClosedLoop.ThinkTime
+	return func(int) (core.Schedule, error) {
+		return newClosedLoopSchedule(thinkTime, finish), nil
+	}
+}
+
+// reportClosedLoop logs achieved pool throughput every second, because it is not
+// known in advance for closed workload.
+func (p *instancePool) reportClosedLoop(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	log := p.log.Sugar()
+	shots := p.poolMetrics.Shots.Get()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		shotsNew := p.poolMetrics.Shots.Get()
+		log.Infof("[CLOSED LOOP] %d resp/s; %d users", shotsNew-shots, p.poolMetrics.ActiveInstances.Get())
+		shots = shotsNew
+	}
+}
+
+// newClosedLoopSchedule returns per instance schedule of closed-loop pool.
+// First token is emitted immediately, and every next one think time after the moment of
+// Next call. Start does not affect tokens, so first token is immediate whether Start was
+// called or not. Instance calls Next after previous shoot is finished, so it shoots
+// back-to-back with think time pauses. Zero finish means, that schedule is infinite.
+func newClosedLoopSchedule(thinkTime time.Duration, finish time.Time) core.Schedule {
+	return &closedLoopSchedule{thinkTime: thinkTime, finish: finish}
+}
+
+type closedLoopSchedule struct {
+	thinkTime time.Duration
+	finish    time.Time
+
+	mu      sync.Mutex
+	emitted bool // First token was emitted.
+}
+
+func (s *closedLoopSchedule) Start(time.Time) {}
+
+func (s *closedLoopSchedule) Next() (ts time.Time, ok bool) {
+	now := time.Now()
+	s.mu.Lock()
+	emitted := s.emitted
+	s.emitted = true
+	s.mu.Unlock()
+	ts = now
+	if emitted {
+		ts = now.Add(s.thinkTime)
+	}
+	if !s.finish.IsZero() && !ts.Before(s.finish) {
+		return s.finish, false
+	}
+	return ts, true
+}
+
+func (s *closedLoopSchedule) Left() int {
+	if !s.finish.IsZero() && !time.Now().Before(s.finish) {
+		return 0
+	}
+	return -1
+}
@@ -0,0 +1,550 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/yandex/pandora/core"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+)
+
+var ErrPoolNotFound = errors.New("pool not found")
+
+// PoolState is pool run stage or result.
+type PoolState string
+
+const (
+	// PoolPending pool awaits gun warm-up and start conditions.
+	PoolPending PoolState = "pending"
+	PoolRunning PoolState = "running"
+	// PoolFinished pool has finished successfully: schedule or ammo is finished.
+	PoolFinished PoolState = "finished"
+	// PoolDrained pool has finished successfully on engine drain.
+	PoolDrained PoolState = "drained"
+	// PoolStopped pool has been stopped via StopPool.
+	PoolStopped  PoolState = "stopped"
+	PoolCanceled PoolState = "canceled"
+	PoolFailed   PoolState = "failed"
+)
+
+// PoolStatus describes running pool state, that can be changed via Engine control methods.
+type PoolStatus struct {
+	ID              string    `json:"id"`
+	State           PoolState `json:"state"`
+	Paused          bool      `json:"paused"`
+	Stopped         bool      `json:"stopped"`
+	RPSMultiplier   float64   `json:"rps_multiplier"`
+	ActiveInstances int64     `json:"active_instances"`
+	Shots           int64     `json:"shots"`
+	// OutOfAmmo is true, if some of pool instances has been finished because of ammo end.
+	OutOfAmmo bool `json:"out_of_ammo"`
+	// Error is pool run error, if pool has failed.
+	Error string `json:"error,omitempty"`
+}
+
+// Pause pauses shooting and instances start of pool with passed id, or all pools if id is empty.
+// Schedules of paused pool are shifted on pause duration after resume, so there is no burst
+// of delayed shoots.
+func (e *Engine) Pause(poolID string) error {
+	return e.forPools(poolID, func(p *instancePool) error {
+		p.control.Pause()
+		return nil
+	})
+}
+
+// Resume resumes shooting of pool with passed id, or all pools if id is empty.
+func (e *Engine) Resume(poolID string) error {
+	return e.forPools(poolID, func(p *instancePool) error {
+		p.control.Resume()
+		return nil
+	})
+}
+
+// SetRPSMultiplier sets multiplier of RPS schedule rate of pool with passed id, or all not closed-loop
+// pools if id is empty. Multiplier affects only remaining part of schedule.
+func (e *Engine) SetRPSMultiplier(poolID string, multiplier float64) error {
+	if multiplier <= 0 {
+		return errors.Errorf("RPS multiplier should be positive, but got %v", multiplier)
+	}
+	return e.forPools(poolID, func(p *instancePool) error {
+		if poolID == "" && p.ClosedLoop != nil {
+			return nil
+		}
+		return p.control.SetRPSMultiplier(multiplier)
+	})
+}
+
+// SetRPSSchedule replaces remaining part of pool RPS schedule. New schedule is started
+// at the moment of call.
+func (e *Engine) SetRPSSchedule(poolID string, newSchedule func() (core.Schedule, error)) error {
+	p, err := e.pool(poolID)
+	if err != nil {
+		return err
+	}
+	return p.control.SetRPSSchedule(newSchedule)
+}
+
+// StopPool cancels run of pool with passed id. Stopped pool is considered to be finished successfully,
+// so other pools continue shooting.
+func (e *Engine) StopPool(poolID string) error {
+	p, err := e.pool(poolID)
+	if err != nil {
+		return err
+	}
+	return p.control.Stop()
+}
+
+// PoolsStatus returns status of running pools in config order.
+func (e *Engine) PoolsStatus() []PoolStatus {
+	e.poolsMu.Lock()
+	pools := e.pools
+	e.poolsMu.Unlock()
+	statuses := make([]PoolStatus, 0, len(pools))
+	for _, p := range pools {
+		statuses = append(statuses, p.status())
+	}
+	return statuses
+}
+
+func (e *Engine) pool(id string) (*instancePool, error) {
+	e.poolsMu.Lock()
+	defer e.poolsMu.Unlock()
+	for _, p := range e.pools {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return nil, errors.WithMessage(ErrPoolNotFound, id)
+}
+
+func (e *Engine) forPools(id string, fn func(p *instancePool) error) error {
+	if id != "" {
+		p, err := e.pool(id)
+		if err != nil {
+			return err
+		}
+		return fn(p)
+	}
+	e.poolsMu.Lock()
+	pools := e.pools
+	e.poolsMu.Unlock()
+	for _, p := range pools {
+		if err := fn(p); err != nil {
+			return errors.WithMessage(err, p.ID)
+		}
+	}
+	return nil
+}
+
+func (p *instancePool) status() PoolStatus {
+	paused, stopped, multiplier := p.control.state()
+	var runErr string
+	if err := p.runErr.Load(); err != nil {
+		runErr = err.Error()
+	}
+	return PoolStatus{
+		ID:              p.ID,
+		State:           PoolState(p.state.Load()),
+		Paused:          paused,
+		Stopped:         stopped,
+		RPSMultiplier:   multiplier,
+		ActiveInstances: p.poolMetrics.ActiveInstances.Get(),
+		Shots:           p.poolMetrics.Shots.Get(),
+		OutOfAmmo:       p.outOfAmmo.Load(),
+		Error:           runErr,
+	}
+}
+
+// poolControl changes pool shooting on the fly. All pool schedules are wrapped into
+// controlledSchedule, that maps schedule time to real time, so rate can be changed and paused.
+type poolControl struct {
+	gate pauseGate
+
+	mu            sync.Mutex
+	closedLoop    bool
+	paused        bool
+	stopped       bool
+	rpsMultiplier float64
+	stop          context.CancelFunc
+	// newRPSSchedule creates schedule for new instances in rps-per-instance mode.
+	newRPSSchedule func() (core.Schedule, error)
+	rpsSchedules   []*controlledSchedule
+	startup        *controlledSchedule
+}
+
+func newPoolControl(conf InstancePoolConfig) *poolControl {
+	return &poolControl{
+		closedLoop:     conf.ClosedLoop != nil,
+		rpsMultiplier:  1,
+		newRPSSchedule: conf.NewRPSSchedule,
+	}
+}
+
+// controlRPS wraps RPS schedule, that is shared by pool instances.
+func (c *poolControl) controlRPS(s core.Schedule) *controlledSchedule {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cs := newControlledSchedule(s, c.rpsMultiplier, c.paused)
+	c.rpsSchedules = append(c.rpsSchedules, cs)
+	return cs
+}
+
+// newInstanceRPSSchedule creates RPS schedule of single instance in rps-per-instance mode.
+// Schedule is bound with instance id, and so are schedules, that replace it.
+func (c *poolControl) newInstanceRPSSchedule(log *zap.Logger, instanceID int) (core.Schedule, error) {
+	c.mu.Lock()
+	newSchedule := c.newRPSSchedule
+	c.mu.Unlock()
+	s, err := newSchedule()
+	if err != nil {
+		return nil, err
+	}
+	cs := c.controlRPS(s)
+	cs.deps = &core.ScheduleDeps{
+		Log:          log,
+		ScheduleTime: cs.scheduleTime,
+		PerInstance:  true,
+		InstanceID:   instanceID,
+	}
+	cs.bind(s)
+	return cs, nil
+}
+
+func (c *poolControl) controlStartup(s core.Schedule) core.Schedule {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.startup = newControlledSchedule(s, 1, c.paused)
+	return c.startup
+}
+
+func (c *poolControl) setStop(stop context.CancelFunc) {
+	c.mu.Lock()
+	c.stop = stop
+	c.mu.Unlock()
+}
+
+func (c *poolControl) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.paused {
+		return
+	}
+	c.paused = true
+	c.gate.pause()
+	now := time.Now()
+	for _, s := range c.schedules() {
+		s.pause(now)
+	}
+}
+
+func (c *poolControl) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.paused {
+		return
+	}
+	c.paused = false
+	now := time.Now()
+	for _, s := range c.schedules() {
+		s.resume(now)
+	}
+	c.gate.resume()
+}
+
+func (c *poolControl) SetRPSMultiplier(multiplier float64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closedLoop {
+		return errors.New("closed-loop pool has no RPS schedule")
+	}
+	c.rpsMultiplier = multiplier
+	now := time.Now()
+	for _, s := range c.rpsSchedules {
+		s.setRate(now, multiplier)
+	}
+	return nil
+}
+
+func (c *poolControl) SetRPSSchedule(newSchedule func() (core.Schedule, error)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closedLoop {
+		return errors.New("closed-loop pool has no RPS schedule")
+	}
+	var swapped int
+	for _, s := range c.rpsSchedules {
+		sched, err := newSchedule()
+		if err != nil {
+			return err
+		}
+		if s.swap(time.Now(), sched) {
+			swapped++
+		}
+	}
+	if swapped == 0 && len(c.rpsSchedules) > 0 {
+		return errors.New("RPS schedule is already finished")
+	}
+	c.newRPSSchedule = newSchedule
+	return nil
+}
+
+func (c *poolControl) Stop() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stop == nil {
+		return errors.New("pool is not running")
+	}
+	c.stopped = true
+	c.stop()
+	return nil
+}
+
+func (c *poolControl) isStopped() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stopped
+}
+
+func (c *poolControl) state() (paused, stopped bool, rpsMultiplier float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused, c.stopped, c.rpsMultiplier
+}
+
+// awaitResumed blocks while pool is paused. Returns false if ctx is done.
+func (c *poolControl) awaitResumed(ctx context.Context) bool {
+	return c.gate.await(ctx)
+}
+
+func (c *poolControl) schedules() []*controlledSchedule {
+	if c.startup == nil {
+		return c.rpsSchedules
+	}
+	return append(c.rpsSchedules[:len(c.rpsSchedules):len(c.rpsSchedules)], c.startup)
+}
+
+// pauseGate blocks callers of await while paused. Not paused gate await is cheap.
+type pauseGate struct {
+	paused  atomic.Bool
+	mu      sync.Mutex
+	resumed chan struct{}
+}
+
+func (g *pauseGate) pause() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resumed != nil {
+		return
+	}
+	g.resumed = make(chan struct{})
+	g.paused.Store(true)
+}
+
+func (g *pauseGate) resume() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resumed == nil {
+		return
+	}
+	close(g.resumed)
+	g.resumed = nil
+	g.paused.Store(false)
+}
+
+func (g *pauseGate) await(ctx context.Context) bool {
+	if !g.paused.Load() {
+		return true
+	}
+	g.mu.Lock()
+	resumed := g.resumed
+	g.mu.Unlock()
+	if resumed == nil {
+		return true
+	}
+	select {
+	case <-resumed:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// controlledSchedule runs wrapped schedule on virtual clock, that goes rate times faster
+// than real one, and stops while schedule is paused.
+// Virtual time of real moment t is virtualAnchor + (t - realAnchor) * rate.
+type controlledSchedule struct {
+	startOnce sync.Once
+	// clock is replaced under write lock, but can be read without lock, so wrapped schedule
+	// can call scheduleTime from its methods.
+	clock atomic.Pointer[scheduleClock]
+
+	// deps are passed to wrapped schedule and schedules, that replace it, if not nil.
+	deps *core.ScheduleDeps
+
+	mu       sync.RWMutex
+	schedule core.Schedule
+}
+
+var _ core.InstanceStopSchedule = (*controlledSchedule)(nil)
+
+// maxRetryInterval is max interval of deferred schedule retry, so pause, resume and rate change
+// during wait are taken into account. Virtual clock stands still on pause, so real retry moment
+// is unknown until resume.
+const maxRetryInterval = 100 * time.Millisecond
+
+type scheduleClock struct {
+	rate          float64
+	paused        bool
+	realAnchor    time.Time
+	virtualAnchor time.Time
+}
+
+func (c *scheduleClock) realTime(virtual time.Time) time.Time {
+	return c.realAnchor.Add(time.Duration(float64(virtual.Sub(c.virtualAnchor)) / c.rate))
+}
+
+func (c *scheduleClock) virtualTime(real time.Time) time.Time {
+	if c.paused {
+		return c.virtualAnchor
+	}
+	return c.virtualAnchor.Add(time.Duration(float64(real.Sub(c.realAnchor)) * c.rate))
+}
+
+// reanchored returns copy of clock anchored at now, that should be changed before clock params change.
+func (c scheduleClock) reanchored(now time.Time) *scheduleClock {
+	c.virtualAnchor = c.virtualTime(now)
+	c.realAnchor = now
+	return &c
+}
+
+func newControlledSchedule(s core.Schedule, rate float64, paused bool) *controlledSchedule {
+	cs := &controlledSchedule{schedule: s}
+	cs.clock.Store(&scheduleClock{rate: rate, paused: paused})
+	return cs
+}
+
+func (s *controlledSchedule) bind(schedule core.Schedule) {
+	if bound, ok := schedule.(core.BoundSchedule); ok && s.deps != nil {
+		bound.Bind(*s.deps)
+	}
+}
+
+func (s *controlledSchedule) Start(startAt time.Time) {
+	s.startOnce.Do(func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		clock := *s.clock.Load()
+		clock.realAnchor = startAt
+		clock.virtualAnchor = startAt
+		s.clock.Store(&clock)
+		s.schedule.Start(startAt)
+	})
+}
+
+// Next waits retry moment of wrapped core.DeferredSchedule without lock, so it doesn't block
+// control of schedule.
+func (s *controlledSchedule) Next() (ts time.Time, ok bool) {
+	s.Start(time.Now())
+	for {
+		ts, ok, wait := s.tryNext()
+		if wait <= 0 {
+			return ts, ok
+		}
+		time.Sleep(wait)
+	}
+}
+
+// tryNext returns token in real time, or positive duration to wait before retry.
+func (s *controlledSchedule) tryNext() (ts time.Time, ok bool, wait time.Duration) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	clock := s.clock.Load()
+	deferred, isDeferred := s.schedule.(core.DeferredSchedule)
+	if !isDeferred {
+		ts, ok = s.schedule.Next()
+		return clock.realTime(ts), ok, 0
+	}
+	ts, ok, retryAt := deferred.TryNext()
+	if retryAt.IsZero() {
+		return clock.realTime(ts), ok, 0
+	}
+	if clock.paused {
+		return time.Time{}, false, maxRetryInterval
+	}
+	wait = time.Until(clock.realTime(retryAt))
+	switch {
+	case wait <= 0:
+		// Retry moment has just come. Float rounding may map it slightly to the past.
+		wait = time.Millisecond
+	case wait > maxRetryInterval:
+		wait = maxRetryInterval
+	}
+	return time.Time{}, false, wait
+}
+
+// scheduleTime maps real moment to wrapped schedule timeline. See core.ScheduleDeps.
+func (s *controlledSchedule) scheduleTime(real time.Time) time.Time {
+	return s.clock.Load().virtualTime(real)
+}
+
+// IsStop forwards core.InstanceStopSchedule of wrapped schedule, so startup schedule can stop
+// instances through control. Returns false, if wrapped schedule can't stop instances.
+func (s *controlledSchedule) IsStop() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	stopSchedule, ok := s.schedule.(core.InstanceStopSchedule)
+	return ok && stopSchedule.IsStop()
+}
+
+func (s *controlledSchedule) Left() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.schedule.Left()
+}
+
+func (s *controlledSchedule) pause(now time.Time) {
+	s.Start(now)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clock := s.clock.Load().reanchored(now)
+	clock.paused = true
+	s.clock.Store(clock)
+}
+
+func (s *controlledSchedule) resume(now time.Time) {
+	s.Start(now)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clock := s.clock.Load().reanchored(now)
+	clock.paused = false
+	s.clock.Store(clock)
+}
+
+func (s *controlledSchedule) setRate(now time.Time, rate float64) {
+	s.Start(now)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clock := s.clock.Load().reanchored(now)
+	clock.rate = rate
+	s.clock.Store(clock)
+}
+
+// swap replaces remaining part of schedule with passed one, started at virtual now.
+// Returns false, if schedule is already finished.
+func (s *controlledSchedule) swap(now time.Time, schedule core.Schedule) bool {
+	s.Start(now)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.schedule.Left() == 0 {
+		return false
+	}
+	clock := s.clock.Load().reanchored(now)
+	s.clock.Store(clock)
+	s.bind(schedule)
+	schedule.Start(clock.virtualAnchor)
+	s.schedule = schedule
+	return true
+}
@@ -0,0 +1,191 @@
+package engine
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"github.com/yandex/pandora/core"
+	"github.com/yandex/pandora/core/schedule"
+	"github.com/yandex/pandora/lib/ginkgoutil"
+)
+
+var _ = Describe("controlled schedule", func() {
+	var (
+		start  time.Time
+		testee *controlledSchedule
+	)
+	BeforeEach(func() {
+		start = time.Now()
+		testee = newControlledSchedule(schedule.NewConst(10, 10*time.Second), 1, false)
+		testee.Start(start)
+	})
+	next := func() time.Duration {
+		ts, ok := testee.Next()
+		Expect(ok).To(BeTrue())
+		return ts.Sub(start)
+	}
+	ms := func(n int) time.Duration {
+		return time.Duration(n) * time.Millisecond
+	}
+
+	It("passes schedule as is by default", func() {
+		Expect(next()).To(Equal(ms(0)))
+		Expect(next()).To(Equal(ms(100)))
+		Expect(testee.Left()).To(Equal(98))
+	})
+
+	It("changes rate", func() {
+		next()
+		next()
+		testee.setRate(start.Add(ms(200)), 2)
+		Expect(next()).To(Equal(ms(200)))
+		Expect(next()).To(Equal(ms(250)))
+		testee.setRate(start.Add(ms(300)), 0.5)
+		Expect(next()).To(Equal(ms(300)))
+		Expect(next()).To(Equal(ms(500)))
+	})
+
+	It("pause shifts remaining schedule", func() {
+		next()
+		next()
+		testee.pause(start.Add(ms(200)))
+		testee.resume(start.Add(ms(1200)))
+		Expect(next()).To(Equal(ms(1200)))
+		Expect(next()).To(Equal(ms(1300)))
+	})
+
+	It("swaps remaining schedule", func() {
+		next()
+		next()
+		Expect(testee.swap(start.Add(ms(500)), schedule.NewOnce(2))).To(BeTrue())
+		Expect(testee.Left()).To(Equal(2))
+		Expect(next()).To(Equal(ms(500)))
+		Expect(next()).To(Equal(ms(500)))
+		_, ok := testee.Next()
+		Expect(ok).To(BeFalse())
+		Expect(testee.swap(start.Add(ms(600)), schedule.NewOnce(1))).To(BeFalse())
+	})
+
+	It("maps real time to schedule time", func() {
+		Expect(testee.scheduleTime(start.Add(ms(100)))).To(Equal(start.Add(ms(100))))
+		testee.setRate(start.Add(ms(200)), 2)
+		Expect(testee.scheduleTime(start.Add(ms(300)))).To(Equal(start.Add(ms(400))))
+		testee.pause(start.Add(ms(300)))
+		Expect(testee.scheduleTime(start.Add(ms(1000)))).To(Equal(start.Add(ms(400))))
+		testee.resume(start.Add(ms(1000)))
+		Expect(testee.scheduleTime(start.Add(ms(1100)))).To(Equal(start.Add(ms(600))))
+	})
+
+	It("waits deferred schedule without lock", func() {
+		conf := schedule.DefaultAdaptiveConfig()
+		conf.From = 10
+		conf.StepDuration = 100 * time.Millisecond
+		conf.Grace = 200 * time.Millisecond
+		adaptive := schedule.NewAdaptiveConf(conf)
+		testee = newControlledSchedule(adaptive, 1, false)
+		adaptive.(core.BoundSchedule).Bind(core.ScheduleDeps{Log: ginkgoutil.NewLogger(), ScheduleTime: testee.scheduleTime})
+		start = time.Now()
+		testee.Start(start)
+		for i := 0; i < 3; i++ {
+			next()
+		}
+		finished := make(chan time.Time)
+		go func() {
+			defer GinkgoRecover()
+			_, ok := testee.Next()
+			Expect(ok).To(BeFalse())
+			finished <- time.Now()
+		}()
+		time.Sleep(50 * time.Millisecond)
+		controlStart := time.Now()
+		testee.pause(controlStart)
+		testee.resume(controlStart)
+		testee.setRate(time.Now(), 2)
+		Expect(time.Since(controlStart)).To(BeNumerically("<", 20*time.Millisecond))
+		// Rest of grace is 250ms of schedule time, that is 125ms of real time on double rate.
+		Eventually(finished).Should(Receive(BeTemporally("~", controlStart.Add(125*time.Millisecond), 60*time.Millisecond)))
+	})
+
+	It("forwards instance stops", func() {
+		Expect(testee.IsStop()).To(BeFalse())
+		Expect(testee.swap(start, newTestStopSchedule(false, true))).To(BeTrue())
+		next()
+		Expect(testee.IsStop()).To(BeFalse())
+		next()
+		Expect(testee.IsStop()).To(BeTrue())
+	})
+})
+
+var _ = Describe("engine control", func() {
+	var (
+		confs  []InstancePoolConfig
+		engine *Engine
+		runErr chan error
+	)
+	BeforeEach(func() {
+		confs = make([]InstancePoolConfig, 2)
+		for i := range confs {
+			confs[i], _ = newTestPoolConf()
+			confs[i].ID = []string{"first", "second"}[i]
+		}
+		confs[0].NewRPSSchedule = func() (core.Schedule, error) {
+			return schedule.NewConst(100, time.Hour), nil
+		}
+	})
+	JustBeforeEach(func() {
+		engine = New(ginkgoutil.NewLogger(), newTestMetrics(), Config{Pools: confs})
+		errs := make(chan error, 1)
+		runErr = errs
+		go func() {
+			errs <- engine.Run(context.Background())
+		}()
+		Eventually(engine.PoolsStatus).Should(HaveLen(2))
+	})
+	AfterEach(func() {
+		for _, status := range engine.PoolsStatus() {
+			_ = engine.StopPool(status.ID)
+		}
+		engine.Wait()
+	})
+	shots := func() int64 {
+		return engine.PoolsStatus()[0].Shots
+	}
+
+	It("unknown pool", func() {
+		err := engine.Pause("unknown")
+		Expect(errors.Cause(err)).To(Equal(ErrPoolNotFound))
+		Expect(engine.StopPool("first")).To(Succeed())
+	})
+
+	It("stopped pool is finished successfully", func() {
+		Eventually(shots).Should(BeNumerically(">", 0))
+		Expect(engine.StopPool("first")).To(Succeed())
+		Eventually(runErr).Should(Receive(BeNil()))
+		Expect(engine.PoolsStatus()[0].Stopped).To(BeTrue())
+	})
+
+	It("pause and resume", func() {
+		Eventually(shots).Should(BeNumerically(">", 0))
+		Expect(engine.Pause("")).To(Succeed())
+		Expect(engine.PoolsStatus()[0].Paused).To(BeTrue())
+		paused := shots()
+		Consistently(shots, 0.1).Should(BeNumerically("<=", paused+1))
+		Expect(engine.Resume("first")).To(Succeed())
+		Eventually(shots).Should(BeNumerically(">", paused+1))
+		Expect(engine.StopPool("first")).To(Succeed())
+		Eventually(runErr).Should(Receive(BeNil()))
+	})
+
+	It("changes RPS schedule", func() {
+		Expect(engine.SetRPSMultiplier("first", 0)).NotTo(Succeed())
+		Expect(engine.SetRPSMultiplier("", 2)).To(Succeed())
+		Expect(engine.PoolsStatus()[0].RPSMultiplier).To(Equal(2.0))
+		Expect(engine.SetRPSSchedule("first", func() (core.Schedule, error) {
+			return schedule.NewOnce(1), nil
+		})).To(Succeed())
+		Eventually(runErr).Should(Receive(BeNil()))
+	})
+})
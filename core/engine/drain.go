@@ -0,0 +1,44 @@
+package engine
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// Drain stops shooting gracefully. Pools stop instances start and ammo acquiring, but shoots in flight
+// are finished. When all instances are finished, pool results are flushed, as on normal finish,
+// and Run returns. Pools, that are not started yet, are not started.
+// Drain doesn't limit its duration: cancel Run context, if drain takes too long.
+func (e *Engine) Drain() {
+	e.drainOnce.Do(func() {
+		e.log.Info("Draining. Awaiting shoots in flight.",
+			zap.Int64("in_flight", e.metrics.Request.Get()-e.metrics.Response.Get()))
+		close(e.drain)
+	})
+}
+
+// withDrain returns child of ctx, that is also canceled on drain.
+// Returned cancel func SHOULD be called, to release resources.
+func withDrain(ctx context.Context, drain <-chan struct{}) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	if drain != nil {
+		go func() {
+			select {
+			case <-drain:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+	return ctx, cancel
+}
+
+func isDrained(drain <-chan struct{}) bool {
+	select {
+	case <-drain:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/yandex/pandora/core"
+	"github.com/yandex/pandora/core/aggregator"
+	"github.com/yandex/pandora/core/schedule"
+	"github.com/yandex/pandora/lib/ginkgoutil"
+)
+
+// testDrainGun shoots for shootTime, and reports sample, if shoot has not been canceled.
+type testDrainGun struct {
+	shootTime  time.Duration
+	aggregator core.Aggregator
+	deps       core.GunDeps
+}
+
+func (g *testDrainGun) Bind(a core.Aggregator, deps core.GunDeps) error {
+	g.aggregator, g.deps = a, deps
+	return nil
+}
+
+func (g *testDrainGun) Shoot(ammo core.Ammo) {
+	select {
+	case <-time.After(g.shootTime):
+		g.aggregator.Report(ammo)
+	case <-g.deps.Ctx.Done():
+	}
+}
+
+// testFlushAggregator records, that its Run has been finished.
+type testFlushAggregator struct {
+	*aggregator.Test
+	flushed atomic.Bool
+}
+
+func (a *testFlushAggregator) Run(ctx context.Context, deps core.AggregatorDeps) error {
+	err := a.Test.Run(ctx, deps)
+	a.flushed.Store(true)
+	return err
+}
+
+var _ = Describe("drain", func() {
+	const shootTime = 200 * time.Millisecond
+	var (
+		metrics Metrics
+		agg     *testFlushAggregator
+		conf    InstancePoolConfig
+		engine  *Engine
+	)
+	BeforeEach(func() {
+		metrics = newTestMetrics()
+		agg = &testFlushAggregator{Test: aggregator.NewTest()}
+		conf, _ = newTestPoolConf()
+		conf.Aggregator = agg
+		conf.NewGun = func() (core.Gun, error) {
+			return &testDrainGun{shootTime: shootTime}, nil
+		}
+		conf.NewRPSSchedule = func() (core.Schedule, error) {
+			return schedule.NewConst(20, time.Hour), nil
+		}
+		conf.StartupSchedule = schedule.NewOnce(5)
+	})
+	run := func(drainAfter time.Duration) (time.Duration, error) {
+		engine = New(ginkgoutil.NewLogger(), metrics, Config{Pools: []InstancePoolConfig{conf}})
+		time.AfterFunc(drainAfter, engine.Drain)
+		start := time.Now()
+		err := engine.Run(context.Background())
+		return time.Since(start), err
+	}
+
+	It("shoots in flight are finished", func() {
+		duration, err := run(shootTime / 2)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(duration).To(BeNumerically("<", 3*shootTime))
+		Expect(metrics.Request.Get()).To(BeNumerically(">", 0))
+		Expect(metrics.Response.Get()).To(Equal(metrics.Request.Get()))
+		Expect(agg.GetSamples()).To(HaveLen(int(metrics.Request.Get())))
+		Expect(agg.flushed.Load()).To(BeTrue())
+		Expect(engine.PoolsStatus()[0].State).To(Equal(PoolDrained))
+	})
+
+	It("not started pool is not started", func() {
+		conf.StartDelay = time.Hour
+		duration, err := run(shootTime / 2)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(duration).To(BeNumerically("<", shootTime))
+		Expect(metrics.Request.Get()).To(BeZero())
+	})
+})
@@ -0,0 +1,173 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/yandex/pandora/core"
+	"github.com/yandex/pandora/core/dryrun"
+	"github.com/yandex/pandora/lib/errutil"
+	"go.uber.org/zap"
+)
+
+type DryRunConfig struct {
+	// MaxShots limits number of planned and dry shots of every pool,
+	// so infinite schedules and providers don't hang dry-run.
+	MaxShots int
+}
+
+func DefaultDryRunConfig() DryRunConfig {
+	return DryRunConfig{MaxShots: 1000 * 1000}
+}
+
+// PoolDryRunReport is result of pool dry-run.
+type PoolDryRunReport struct {
+	ID string
+	// Planned is number of planned shots in every second since engine start. Nil for closed-loop pools.
+	Planned []int
+	// Shots is number of acquired ammo.
+	Shots int
+	// Tags is number of shots by sample tag. Empty, if gun doesn't implement dryrun.Gun.
+	Tags map[string]int
+	// Errors is number of failed dry shots by error text.
+	Errors map[string]int
+	// GunDryShoot is false, if gun doesn't implement dryrun.Gun, so ammo is only acquired.
+	GunDryShoot bool
+	// OutOfAmmo is true, if provider has finished before schedule.
+	OutOfAmmo bool
+	// Truncated is true, if schedule or ammo has been limited by DryRunConfig.MaxShots.
+	Truncated bool
+}
+
+// PlannedShots returns total number of planned shots.
+func (r *PoolDryRunReport) PlannedShots() int {
+	var shots int
+	for _, n := range r.Planned {
+		shots += n
+	}
+	return shots
+}
+
+// DryRun runs providers of all pools, and passes every ammo, that would be shot according to
+// pool schedule, to gun DryShoot. Schedules are iterated without waiting, nothing is sent and
+// aggregators are not run.
+func (e *Engine) DryRun(ctx context.Context, conf DryRunConfig) ([]PoolDryRunReport, error) {
+	var reports []PoolDryRunReport
+	for i, poolConf := range e.config.Pools {
+		poolConf.ID = poolID(poolConf, i)
+		pool := newPool(e.log, e.metrics, nil, poolConf)
+		report, err := pool.dryRun(ctx, conf)
+		if err != nil {
+			return reports, errors.WithMessage(err, fmt.Sprintf("%q pool dry-run failed", pool.ID))
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+func (p *instancePool) dryRun(ctx context.Context, conf DryRunConfig) (PoolDryRunReport, error) {
+	report := PoolDryRunReport{
+		ID:     p.ID,
+		Tags:   map[string]int{},
+		Errors: map[string]int{},
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	shots := conf.MaxShots
+	if p.ClosedLoop == nil {
+		timeline, err := PlanPool(p.InstancePoolConfig, conf.MaxShots)
+		if err != nil {
+			return report, err
+		}
+		report.Planned, report.Truncated = timeline.Shots, timeline.Truncated
+		shots = report.PlannedShots()
+	}
+
+	gun, err := p.NewGun()
+	if err != nil {
+		return report, errors.WithMessage(err, "can't initiate a gun")
+	}
+	dryGun, ok := gun.(dryrun.Gun)
+	if ok {
+		report.GunDryShoot = true
+		// Instance lifecycle hooks are not called, because they may send requests.
+		gunDeps := core.GunDeps{Ctx: ctx, Log: p.log.With(zap.Int("instance", 0)), PoolID: p.ID, State: core.NewInstanceState()}
+		err = gun.Bind(dryRunAggregator{}, gunDeps)
+		if err != nil {
+			return report, errors.WithMessage(err, "gun bind failed")
+		}
+	}
+
+	providerErr := make(chan error, 1)
+	go func() {
+		providerErr <- p.Provider.Run(ctx, core.ProviderDeps{Log: p.log, PoolID: p.ID})
+	}()
+	shootDone := make(chan struct{})
+	go func() {
+		defer close(shootDone)
+		for report.Shots < shots {
+			ammo, ok := p.Provider.Acquire()
+			if !ok {
+				report.OutOfAmmo = true
+				return
+			}
+			report.Shots++
+			if dryGun != nil {
+				tags, err := dryGun.DryShoot(ammo)
+				if err != nil {
+					report.Errors[err.Error()]++
+				}
+				for _, tag := range tags {
+					report.Tags[tag]++
+				}
+			}
+			p.Provider.Release(ammo)
+		}
+		if p.ClosedLoop != nil {
+			report.Truncated = true
+		}
+	}()
+
+	// Report is not returned on fail, because it may be still modified by shooting goroutine,
+	// blocked on Acquire.
+	failed := PoolDryRunReport{ID: p.ID}
+	select {
+	case <-shootDone:
+	case err := <-providerErr:
+		if err != nil && !errutil.IsCtxError(ctx, err) {
+			return failed, errors.WithMessage(err, "provider failed")
+		}
+		select {
+		case <-shootDone:
+		case <-ctx.Done():
+			return failed, ctx.Err()
+		}
+	case <-ctx.Done():
+		return failed, ctx.Err()
+	}
+	if closer, ok := gun.(io.Closer); ok {
+		err := closer.Close()
+		if err != nil {
+			p.log.Warn("Gun close fail", zap.Error(err))
+		}
+	}
+	return report, nil
+}
+
+// dryRunAggregator is bound to guns in dry-run mode. Guns shouldn't report samples in DryShoot,
+// but if they do, samples are just returned.
+type dryRunAggregator struct{}
+
+func (dryRunAggregator) Run(ctx context.Context, _ core.AggregatorDeps) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (dryRunAggregator) Report(s core.Sample) {
+	if borrowed, ok := s.(core.BorrowedSample); ok {
+		borrowed.Return()
+	}
+}
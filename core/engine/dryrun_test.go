@@ -0,0 +1,136 @@
+package engine
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
+	"github.com/yandex/pandora/core"
+	"github.com/yandex/pandora/core/aggregator"
+	coremock "github.com/yandex/pandora/core/mocks"
+	"github.com/yandex/pandora/core/provider"
+	"github.com/yandex/pandora/core/schedule"
+	"github.com/yandex/pandora/lib/ginkgoutil"
+)
+
+type testDryGun struct {
+	bound bool
+}
+
+func (g *testDryGun) Bind(core.Aggregator, core.GunDeps) error {
+	g.bound = true
+	return nil
+}
+
+func (g *testDryGun) Shoot(core.Ammo) {
+	panic("shoot in dry-run")
+}
+
+func (g *testDryGun) DryShoot(ammo core.Ammo) ([]string, error) {
+	if !g.bound {
+		panic("not bound")
+	}
+	n := ammo.(int)
+	if n%5 == 4 {
+		return nil, errors.New("invalid ammo " + strconv.Itoa(n%2))
+	}
+	return []string{"tag" + strconv.Itoa(n%2)}, nil
+}
+
+var _ = Describe("dry run", func() {
+	var (
+		conf    InstancePoolConfig
+		dryConf DryRunConfig
+	)
+	BeforeEach(func() {
+		conf = InstancePoolConfig{
+			Provider:   provider.NewNum(-1),
+			Aggregator: aggregator.NewTest(),
+			NewGun: func() (core.Gun, error) {
+				return &testDryGun{}, nil
+			},
+			NewRPSSchedule: func() (core.Schedule, error) {
+				return schedule.NewConst(5, 2*time.Second), nil
+			},
+			StartupSchedule: schedule.NewOnce(1),
+		}
+		dryConf = DefaultDryRunConfig()
+	})
+
+	dryRun := func() ([]PoolDryRunReport, error) {
+		e := New(ginkgoutil.NewLogger(), newTestMetrics(), Config{Pools: []InstancePoolConfig{conf}})
+		return e.DryRun(context.Background(), dryConf)
+	}
+
+	It("shoots planned ammo", func() {
+		reports, err := dryRun()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reports).To(Equal([]PoolDryRunReport{{
+			ID:          "pool_0",
+			Planned:     []int{5, 5},
+			Shots:       10,
+			Tags:        map[string]int{"tag0": 4, "tag1": 4},
+			Errors:      map[string]int{"invalid ammo 0": 1, "invalid ammo 1": 1},
+			GunDryShoot: true,
+		}}))
+	})
+
+	It("out of ammo", func() {
+		conf.Provider = provider.NewNum(3)
+		reports, err := dryRun()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reports[0].Shots).To(Equal(3))
+		Expect(reports[0].OutOfAmmo).To(BeTrue())
+		Expect(reports[0].PlannedShots()).To(Equal(10))
+	})
+
+	It("per instance schedule", func() {
+		conf.RPSPerInstance = true
+		conf.StartupSchedule = schedule.NewConst(1, 2*time.Second)
+		conf.NewRPSSchedule = func() (core.Schedule, error) {
+			return schedule.NewOnce(3), nil
+		}
+		reports, err := dryRun()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reports[0].Planned).To(Equal([]int{3, 3}))
+		Expect(reports[0].Shots).To(Equal(6))
+	})
+
+	It("schedule truncated", func() {
+		dryConf.MaxShots = 7
+		reports, err := dryRun()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reports[0].Truncated).To(BeTrue())
+		Expect(reports[0].Planned).To(Equal([]int{5, 2}))
+		Expect(reports[0].Shots).To(Equal(7))
+	})
+
+	It("closed-loop pool with gun without dry shoot", func() {
+		gun := &coremock.Gun{}
+		conf.NewGun = func() (core.Gun, error) { return gun, nil }
+		conf.NewRPSSchedule = nil
+		conf.ClosedLoop = &ClosedLoopConfig{}
+		dryConf.MaxShots = 7
+		reports, err := dryRun()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reports[0].Planned).To(BeNil())
+		Expect(reports[0].Shots).To(Equal(7))
+		Expect(reports[0].Truncated).To(BeTrue())
+		Expect(reports[0].GunDryShoot).To(BeFalse())
+		Expect(reports[0].Tags).To(BeEmpty())
+		ginkgoutil.AssertExpectations(gun)
+	})
+
+	It("provider failed", func() {
+		failed := &coremock.Provider{}
+		failed.On("Run", mock.Anything, mock.Anything).Return(errors.New("provider fail"))
+		failed.On("Acquire").Run(func(mock.Arguments) { select {} }).Return(nil, false)
+		conf.Provider = failed
+		_, err := dryRun()
+		Expect(err).To(MatchError(ContainSubstring("provider fail")))
+	})
+})
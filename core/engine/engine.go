@@ -9,18 +9,25 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/yandex/pandora/core"
+	"github.com/yandex/pandora/core/autostop"
+	"github.com/yandex/pandora/core/config"
 	"github.com/yandex/pandora/core/coreutil"
 	"github.com/yandex/pandora/core/warmup"
 	"github.com/yandex/pandora/lib/errutil"
 	"github.com/yandex/pandora/lib/monitoring"
+	"go.uber.org/atomic"
 	"go.uber.org/zap"
 )
 
 type Config struct {
 	Pools []InstancePoolConfig `config:"pools" validate:"required,dive"`
+	// Autostop criteria are checked every second against samples of all pools.
+	// Run fails with *autostop.Error, when any of them is triggered.
+	Autostop []autostop.Criterion `config:"autostop"`
 }
 
 type InstancePoolConfig struct {
@@ -29,9 +36,66 @@ type InstancePoolConfig struct {
 	Aggregator      core.Aggregator               `config:"result" validate:"required"`
 	NewGun          func() (core.Gun, error)      `config:"gun" validate:"required"`
 	RPSPerInstance  bool                          `config:"rps-per-instance"`
-	NewRPSSchedule  func() (core.Schedule, error) `config:"rps" validate:"required"`
+	NewRPSSchedule  func() (core.Schedule, error) `config:"rps"` // Required, if pool is not closed-loop.
 	StartupSchedule core.Schedule                 `config:"startup" validate:"required"`
 	DiscardOverflow bool                          `config:"discard_overflow"`
+	ClosedLoop      *ClosedLoopConfig             `config:"closed-loop"`
+	// StartDelay delays pool start since engine start.
+	StartDelay time.Duration `config:"start-delay" validate:"min-time=0s"`
+	// StartAfter are IDs of pools, gun warm-up of which should be finished before pool start.
+	StartAfter []string `config:"start-after"`
+	// StartBarrier is barrier name. Pools with the same barrier start at the same time,
+	// when all of them are ready to start.
+	StartBarrier string `config:"start-barrier"`
+}
+
+// ClosedLoopConfig turns pool into closed workload model: there is no RPS limit, and every
+// instance makes next shoot right after previous one is finished and think time is passed.
+// Load is defined by number of active instances, that is controlled by startup schedule.
+type ClosedLoopConfig struct {
+	ThinkTime time.Duration `config:"think-time" validate:"min-time=0s"`
+	// Duration limits pool shooting time. Zero means shooting until ammo is finished.
+	Duration time.Duration `config:"duration" validate:"min-time=0s"`
+}
+
+var _ = config.RegisterCustom(validateInstancePoolConfig, InstancePoolConfig{})
+
+func validateInstancePoolConfig(h config.ValidateHandle) {
+	conf := h.Value().(InstancePoolConfig)
+	switch {
+	case conf.ClosedLoop == nil && conf.NewRPSSchedule == nil:
+		h.ReportError("rps", "required for not closed-loop pool")
+	case conf.ClosedLoop != nil && conf.NewRPSSchedule != nil:
+		h.ReportError("rps", "closed-loop pool has no RPS schedule")
+	}
+}
+
+var _ = config.RegisterCustom(validateConfig, Config{})
+
+func validateConfig(h config.ValidateHandle) {
+	conf := h.Value().(Config)
+	ids := map[string]int{}
+	for i, pool := range conf.Pools {
+		ids[poolID(pool, i)]++
+	}
+	for i, pool := range conf.Pools {
+		for _, id := range pool.StartAfter {
+			switch {
+			case id == poolID(pool, i):
+				h.ReportError("pools", fmt.Sprintf("pool %q can't start after itself", id))
+			case ids[id] == 0:
+				h.ReportError("pools", fmt.Sprintf("pool %q starts after unknown pool %q", poolID(pool, i), id))
+			}
+		}
+	}
+}
+
+// poolID returns pool ID, or default ID of i-th pool, if it is not set.
+func poolID(conf InstancePoolConfig, i int) string {
+	if conf.ID == "" {
+		return fmt.Sprintf("pool_%v", i)
+	}
+	return conf.ID
 }
 
 // TODO(skipor): use something github.com/rcrowley/go-metrics based.
@@ -45,7 +109,7 @@ type Metrics struct {
 }
 
 func New(log *zap.Logger, m Metrics, conf Config) *Engine {
-	return &Engine{log: log, config: conf, metrics: m}
+	return &Engine{log: log, config: conf, metrics: m, drain: make(chan struct{})}
 }
 
 type Engine struct {
@@ -53,6 +117,21 @@ type Engine struct {
 	config  Config
 	metrics Metrics
 	wait    sync.WaitGroup
+
+	drain     chan struct{} // Closed on Drain.
+	drainOnce sync.Once
+
+	poolsMu sync.Mutex
+	pools   []*instancePool // Running pools. Used by control methods.
+
+	// sampleObservers are notified about every sample reported by all pools.
+	sampleObservers []coreutil.SampleObserver
+}
+
+// AddSampleObserver adds observer, that is notified about every sample reported by all pools.
+// MUST be called before Run.
+func (e *Engine) AddSampleObserver(o coreutil.SampleObserver) {
+	e.sampleObservers = append(e.sampleObservers, o)
 }
 
 // Run runs all instance pools. Run blocks until fail happen, or all pools
@@ -66,15 +145,47 @@ func (e *Engine) Run(ctx context.Context) error {
 		cancel()
 	}()
 
-	runRes := make(chan poolRunResult, 1)
+	sampleObservers := append([]coreutil.SampleObserver(nil), e.sampleObservers...)
+	var autostopErr <-chan error // Nil, if there is no autostop criteria.
+	if len(e.config.Autostop) > 0 {
+		as := autostop.New(e.log, e.config.Autostop)
+		as.SetActive(e.isShooting)
+		sampleObservers = append(sampleObservers, as)
+		errs := make(chan error, 1)
+		go func() {
+			errs <- as.Run(ctx)
+		}()
+		autostopErr = errs
+	}
+
+	pools := make([]InstancePoolConfig, len(e.config.Pools))
 	for i, conf := range e.config.Pools {
-		if conf.ID == "" {
-			conf.ID = fmt.Sprintf("pool_%v", i)
-		}
+		conf.ID = poolID(conf, i)
+		pools[i] = conf
+	}
+	gate := newStartGate(time.Now(), pools)
+	runRes := make(chan poolRunResult, 1)
+	for _, conf := range pools {
 		e.wait.Add(1)
 		pool := newPool(e.log, e.metrics, e.wait.Done, conf)
+		pool.startGate = gate
+		pool.drain = e.drain
+		pool.sampleObservers = append(pool.sampleObservers, sampleObservers...)
+		poolCtx, poolCancel := context.WithCancel(ctx)
+		pool.control.setStop(poolCancel)
+		e.poolsMu.Lock()
+		e.pools = append(e.pools, pool)
+		e.poolsMu.Unlock()
+		e.wait.Add(1)
 		go func() {
-			err := pool.Run(ctx)
+			defer poolCancel()
+			err := pool.Run(poolCtx)
+			if err != nil && pool.control.isStopped() {
+				pool.log.Info("Pool has been stopped", zap.NamedError("reason", err))
+				err = nil
+			}
+			pool.finish(poolCtx, err)
+			e.wait.Done()
 			select {
 			case runRes <- poolRunResult{ID: pool.ID, Err: err}:
 			case <-ctx.Done():
@@ -97,6 +208,11 @@ func (e *Engine) Run(ctx context.Context) error {
 				}
 				return errors.WithMessage(res.Err, fmt.Sprintf("%q pool run failed", res.ID))
 			}
+		case err := <-autostopErr:
+			if errutil.IsCtxError(ctx, err) {
+				e.log.Info("Engine run canceled")
+			}
+			return err
 		case <-ctx.Done():
 			e.log.Info("Engine run canceled")
 			return ctx.Err()
@@ -105,6 +221,23 @@ func (e *Engine) Run(ctx context.Context) error {
 	return nil
 }
 
+// isShooting returns true, if some pool is running and not paused. Pools awaiting warm-up
+// or start conditions are not running.
+func (e *Engine) isShooting() bool {
+	e.poolsMu.Lock()
+	pools := e.pools
+	e.poolsMu.Unlock()
+	for _, p := range pools {
+		if PoolState(p.state.Load()) != PoolRunning {
+			continue
+		}
+		if paused, _, _ := p.control.state(); !paused {
+			return true
+		}
+	}
+	return false
+}
+
 // Wait blocks until all run engine tasks are finished.
 // Useful only in case of fail, because successful run awaits all started tasks.
 func (e *Engine) Wait() {
@@ -113,15 +246,65 @@ func (e *Engine) Wait() {
 
 func newPool(log *zap.Logger, m Metrics, onWaitDone func(), conf InstancePoolConfig) *instancePool {
 	log = log.With(zap.String("pool", conf.ID))
-	return &instancePool{log, m, onWaitDone, conf, nil}
+	return &instancePool{
+		log:                log,
+		metrics:            m,
+		poolMetrics:        &poolMetrics{},
+		control:            newPoolControl(conf),
+		onWaitDone:         onWaitDone,
+		InstancePoolConfig: conf,
+		state:              atomic.NewString(string(PoolPending)),
+		outOfAmmo:          atomic.NewBool(false),
+		runErr:             atomic.NewError(nil),
+	}
 }
 
 type instancePool struct {
-	log        *zap.Logger
-	metrics    Metrics
-	onWaitDone func()
+	log         *zap.Logger
+	metrics     Metrics
+	poolMetrics *poolMetrics
+	control     *poolControl
+	onWaitDone  func()
 	InstancePoolConfig
 	gunWarmUpResult interface{}
+	// startGate holds pool start after gun warm-up. Nil, if pool is run out of engine.
+	startGate *startGate
+	// Pool slots in startGate, that are released on arrival or on leaveStart.
+	arrivedWarmedUp bool
+	arrivedBarrier  bool
+	// drain is closed on engine drain. Nil, if pool is run out of engine.
+	drain <-chan struct{}
+	// sampleObservers are notified about every sample reported by pool instances.
+	sampleObservers []coreutil.SampleObserver
+
+	state     *atomic.String // PoolState.
+	outOfAmmo *atomic.Bool
+	runErr    *atomic.Error
+}
+
+// finish sets pool final state by its run result.
+func (p *instancePool) finish(ctx context.Context, err error) {
+	var state PoolState
+	switch {
+	case err == nil && p.control.isStopped():
+		state = PoolStopped
+	case err == nil && isDrained(p.drain):
+		state = PoolDrained
+	case err == nil:
+		state = PoolFinished
+	case errutil.IsCtxError(ctx, err):
+		state = PoolCanceled
+	default:
+		state = PoolFailed
+		p.runErr.Store(err)
+	}
+	p.state.Store(string(state))
+}
+
+// poolMetrics are counters of single pool, in addition to engine wide Metrics.
+type poolMetrics struct {
+	Shots           monitoring.Counter
+	ActiveInstances monitoring.Counter
 }
 
 // Run start instance pool. Run blocks until fail happen, or all instances finish.
@@ -141,12 +324,28 @@ func (p *instancePool) Run(ctx context.Context) error {
 		p.log.Info("Pool run finished")
 		cancel()
 	}()
+	defer p.leaveStart()
 
 	if err := p.warmUpGun(ctx); err != nil {
 		p.onWaitDone()
 		return err
 	}
+	startCtx, startCancel := withDrain(ctx, p.drain)
+	err := p.awaitStart(startCtx)
+	startCancel()
+	if err == nil && isDrained(p.drain) {
+		err = startCtx.Err()
+	}
+	if err != nil {
+		p.onWaitDone()
+		if ctx.Err() == nil && isDrained(p.drain) {
+			p.log.Info("Pool drained before start")
+			return nil
+		}
+		return err
+	}
 
+	p.state.Store(string(PoolRunning))
 	rh, err := p.runAsync(ctx)
 	if err != nil {
 		return err
@@ -202,11 +401,28 @@ func (p *instancePool) runAsync(runCtx context.Context) (*poolAsyncRunHandle, er
 	// Canceled in case all instances finish, fail or run runCancel.
 	runCtx, runCancel := context.WithCancel(runCtx)
 	_ = runCancel
-	// Canceled also on out of ammo, and finish of shared RPS schedule.
-	instanceStartCtx, instanceStartCancel := context.WithCancel(runCtx)
-	newInstanceSchedule, err := p.buildNewInstanceSchedule(instanceStartCtx, instanceStartCancel)
-	if err != nil {
-		return nil, err
+	// Canceled also on out of ammo, finish of shared RPS schedule, closed-loop duration or drain.
+	instanceStartCtx, instanceStartCancel := p.newInstanceStartContext(runCtx)
+	if p.drain != nil {
+		go func() {
+			select {
+			case <-p.drain:
+				instanceStartCancel()
+			case <-instanceStartCtx.Done():
+			}
+		}()
+	}
+	var newInstanceSchedule func(instanceID int) (core.Schedule, error)
+	if p.ClosedLoop != nil {
+		finish, _ := instanceStartCtx.Deadline()
+		newInstanceSchedule = p.buildClosedLoopSchedule(finish)
+		go p.reportClosedLoop(runCtx)
+	} else {
+		var err error
+		newInstanceSchedule, err = p.buildNewInstanceSchedule(instanceStartCtx, instanceStartCancel)
+		if err != nil {
+			return nil, err
+		}
 	}
 	// Seems good enough. Even if some run will block on result send, it's not real problem.
 	const runResultBufSize = 64
@@ -241,6 +457,13 @@ func (p *instancePool) runAsync(runCtx context.Context) (*poolAsyncRunHandle, er
 	}, nil
 }
 
+func (p *instancePool) newInstanceStartContext(runCtx context.Context) (context.Context, context.CancelFunc) {
+	if p.ClosedLoop != nil && p.ClosedLoop.Duration > 0 {
+		return context.WithTimeout(runCtx, p.ClosedLoop.Duration)
+	}
+	return context.WithCancel(runCtx)
+}
+
 func (p *instancePool) awaitRunAsync(runHandle *poolAsyncRunHandle) <-chan error {
 	ah, awaitErr := p.newAwaitRunHandle(runHandle)
 	go func() {
@@ -260,6 +483,7 @@ type runAwaitHandle struct {
 	log *zap.Logger
 	poolAsyncRunHandle
 	awaitErr         chan<- error
+	outOfAmmo        *atomic.Bool
 	toWait           int
 	startedInstances int
 	awaitedInstances int
@@ -271,6 +495,7 @@ func (p *instancePool) newAwaitRunHandle(runHandle *poolAsyncRunHandle) (*runAwa
 	awaitHandle := &runAwaitHandle{
 		log:                p.log,
 		poolAsyncRunHandle: *runHandle,
+		outOfAmmo:          p.outOfAmmo,
 		awaitErr:           awaitErr,
 		toWait:             resultsToWait,
 		startedInstances:   -1, // Undefined until start finish.
@@ -287,14 +512,14 @@ func (ah *runAwaitHandle) awaitRun() {
 			ah.toWait--
 			ah.log.Debug("AmmoQueue awaited", zap.Error(err))
 			if !errutil.IsCtxError(ah.runCtx, err) {
-				ah.onErrAwaited(errors.WithMessage(err, "provider failed"))
+				ah.onErrAwaited(errors.WithMessage(&ProviderError{err}, "provider failed"))
 			}
 		case err := <-ah.aggregatorErr:
 			ah.aggregatorErr = nil
 			ah.toWait--
 			ah.log.Debug("Aggregator awaited", zap.Error(err))
 			if !errutil.IsCtxError(ah.runCtx, err) {
-				ah.onErrAwaited(errors.WithMessage(err, "aggregator failed"))
+				ah.onErrAwaited(errors.WithMessage(&AggregatorError{err}, "aggregator failed"))
 			}
 		case res := <-ah.startRes:
 			ah.startRes = nil
@@ -312,6 +537,7 @@ func (ah *runAwaitHandle) awaitRun() {
 			}
 
 			if res.Err == outOfAmmoErr {
+				ah.outOfAmmo.Store(true)
 				if !ah.isStartFinished() {
 					ah.log.Debug("Canceling instance start because out of ammo")
 					ah.instanceStartCancel()
@@ -359,7 +585,7 @@ func (ah *runAwaitHandle) isStartFinished() bool {
 
 func (p *instancePool) startInstances(
 	startCtx, runCtx context.Context,
-	newInstanceSchedule func() (core.Schedule, error),
+	newInstanceSchedule func(instanceID int) (core.Schedule, error),
 	runRes chan<- instanceRunResult) (started int, err error) {
 	deps := instanceDeps{
 		newSchedule: newInstanceSchedule,
@@ -367,52 +593,124 @@ func (p *instancePool) startInstances(
 		instanceSharedDeps: instanceSharedDeps{
 			provider:        p.Provider,
 			metrics:         p.metrics,
+			poolMetrics:     p.poolMetrics,
 			gunWarmUpResult: p.gunWarmUpResult,
-			aggregator:      p.Aggregator,
+			aggregator:      coreutil.NewObservedAggregator(p.Aggregator, p.sampleObservers...),
 			discardOverflow: p.DiscardOverflow,
+			control:         p.control,
+			drain:           p.drain,
 		},
 	}
-
-	waiter := coreutil.NewWaiter(p.StartupSchedule, startCtx)
-
-	// If create all instances asynchronously, and creation will fail, too many errors appears in log.
-	ok := waiter.Wait()
-	if !ok {
-		err = startCtx.Err()
-		return
+	// Ammo order can be kept only with shared RPS schedule.
+	if !p.RPSPerInstance && p.ClosedLoop == nil && isAmmoOrdered(p.Provider) {
+		deps.ammoOrder = &sync.Mutex{}
 	}
-	firstInstance, err := newInstance(runCtx, p.log, p.ID, 0, deps)
-	if err != nil {
-		return
-	}
-	started++
-	go func() {
-		runRes <- instanceRunResult{0, func() error {
-			defer firstInstance.Close()
-			return firstInstance.Run(runCtx)
-		}()}
-	}()
 
-	for ; waiter.Wait(); started++ {
+	// Stops are asked from schedule that waiter actually uses, because control wraps startup schedule.
+	startup := p.control.controlStartup(p.StartupSchedule)
+	stopSchedule, _ := startup.(core.InstanceStopSchedule)
+	waiter := coreutil.NewWaiter(startup, startCtx)
+	var stops instanceStops
+	for waiter.Wait() && p.control.awaitResumed(startCtx) {
+		if stopSchedule != nil && stopSchedule.IsStop() {
+			if !stops.stopLast() {
+				p.log.Debug("No active instance to stop")
+			}
+			continue
+		}
 		id := started
-		go func() {
-			runRes <- instanceRunResult{id, runNewInstance(runCtx, p.log, p.ID, id, deps)}
-		}()
+		instanceDeps := deps
+		instanceDeps.stop = stops.add()
+		if id == 0 {
+			// If create all instances asynchronously, and creation will fail, too many errors appears in log.
+			var firstInstance *instance
+			firstInstance, err = newInstance(runCtx, p.log, p.ID, id, instanceDeps)
+			if err != nil {
+				return
+			}
+			go func() {
+				err := func() error {
+					defer firstInstance.Close()
+					return firstInstance.Run(runCtx)
+				}()
+				stops.finish(id)
+				runRes <- instanceRunResult{id, err}
+			}()
+		} else {
+			go func() {
+				err := runNewInstance(runCtx, p.log, p.ID, id, instanceDeps)
+				stops.finish(id)
+				runRes <- instanceRunResult{id, err}
+			}()
+		}
+		started++
 	}
 	err = startCtx.Err()
 	return
 }
 
+// instanceStops stops active instances by startup schedule. The latest started instance is stopped first.
+type instanceStops struct {
+	mu sync.Mutex
+	// stops are stop channels of instances by id. Nil for stopped and finished instances.
+	stops []chan struct{}
+	// last is max id of instance, that may be active.
+	last int
+}
+
+func (s *instanceStops) add() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stop := make(chan struct{})
+	s.stops = append(s.stops, stop)
+	s.last = len(s.stops) - 1
+	return stop
+}
+
+func (s *instanceStops) finish(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stops[id] = nil
+}
+
+// stopLast stops the latest started active instance. Returns false, if there is no active instances.
+func (s *instanceStops) stopLast() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.stops) == 0 {
+		return false // No instance has been started yet.
+	}
+	for ; s.last >= 0; s.last-- {
+		if stop := s.stops[s.last]; stop != nil {
+			close(stop)
+			s.stops[s.last] = nil
+			return true
+		}
+	}
+	return false
+}
+
 func (p *instancePool) buildNewInstanceSchedule(startCtx context.Context, cancelStart context.CancelFunc) (
-	func() (core.Schedule, error), error,
+	func(instanceID int) (core.Schedule, error), error,
 ) {
 	if p.RPSPerInstance {
-		return p.NewRPSSchedule, nil
+		return func(instanceID int) (core.Schedule, error) {
+			return p.control.newInstanceRPSSchedule(p.log, instanceID)
+		}, nil
 	}
 	sharedRPSSchedule, err := p.NewRPSSchedule()
 	if err != nil {
 		return nil, err
 	}
+	controlled := p.control.controlRPS(sharedRPSSchedule)
+	if bound, ok := sharedRPSSchedule.(core.BoundSchedule); ok {
+		bound.Bind(core.ScheduleDeps{Log: p.log, ScheduleTime: controlled.scheduleTime})
+	}
+	if observer, ok := sharedRPSSchedule.(coreutil.SampleObserver); ok {
+		// Schedule rate depends on shooting results.
+		p.sampleObservers = append(p.sampleObservers, observer)
+	}
+	sharedRPSSchedule = controlled
 	sharedRPSSchedule = coreutil.NewCallbackOnFinishSchedule(sharedRPSSchedule, func() {
 		select {
 		case <-startCtx.Done():
@@ -423,7 +721,7 @@ func (p *instancePool) buildNewInstanceSchedule(startCtx context.Context, cancel
 			cancelStart()
 		}
 	})
-	return func() (core.Schedule, error) {
+	return func(int) (core.Schedule, error) {
 		return sharedRPSSchedule, err
 	}, nil
 }
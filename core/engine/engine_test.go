@@ -11,6 +11,7 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/yandex/pandora/core"
 	"github.com/yandex/pandora/core/aggregator"
+	"github.com/yandex/pandora/core/autostop"
 	"github.com/yandex/pandora/core/config"
 	coremock "github.com/yandex/pandora/core/mocks"
 	"github.com/yandex/pandora/core/provider"
@@ -36,6 +37,28 @@ var _ = Describe("config validation", func() {
 		Expect(err).To(HaveOccurred())
 	})
 
+	It("rps required for not closed-loop pool", func() {
+		conf, _ := newTestPoolConf()
+		conf.NewRPSSchedule = nil
+		err := config.Validate(Config{Pools: []InstancePoolConfig{conf}})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("closed-loop pool without rps", func() {
+		conf, _ := newTestPoolConf()
+		conf.NewRPSSchedule = nil
+		conf.ClosedLoop = &ClosedLoopConfig{ThinkTime: time.Millisecond}
+		err := config.Validate(Config{Pools: []InstancePoolConfig{conf}})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("closed-loop pool with rps", func() {
+		conf, _ := newTestPoolConf()
+		conf.ClosedLoop = &ClosedLoopConfig{}
+		err := config.Validate(Config{Pools: []InstancePoolConfig{conf}})
+		Expect(err).To(HaveOccurred())
+	})
+
 })
 
 func newTestPoolConf() (InstancePoolConfig, *coremock.Gun) {
@@ -246,6 +269,118 @@ var _ = Describe("multiple instance", func() {
 
 })
 
+var _ = Describe("closed-loop pool", func() {
+	It("shoots until out of ammo", func() {
+		conf, gun := newTestPoolConf()
+		conf.Provider = provider.NewNum(10)
+		conf.NewRPSSchedule = nil
+		conf.ClosedLoop = &ClosedLoopConfig{}
+		conf.StartupSchedule = schedule.NewOnce(2)
+		pool := newPool(ginkgoutil.NewLogger(), newTestMetrics(), nil, conf)
+
+		err := pool.Run(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		gun.AssertNumberOfCalls(GinkgoT(), "Shoot", 10)
+		Expect(pool.poolMetrics.Shots.Get()).To(BeEquivalentTo(10))
+		Expect(pool.poolMetrics.ActiveInstances.Get()).To(BeEquivalentTo(0))
+	}, 1)
+
+	It("finishes after duration", func() {
+		conf, _ := newTestPoolConf()
+		conf.NewRPSSchedule = nil
+		conf.ClosedLoop = &ClosedLoopConfig{
+			ThinkTime: 10 * time.Millisecond,
+			Duration:  100 * time.Millisecond,
+		}
+		conf.StartupSchedule = schedule.NewComposite(
+			schedule.NewOnce(1),
+			schedule.NewConst(10, time.Second),
+		)
+		pool := newPool(ginkgoutil.NewLogger(), newTestMetrics(), nil, conf)
+
+		start := time.Now()
+		err := pool.Run(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+		Expect(pool.metrics.InstanceStart.Get()).To(BeNumerically("<=", 3))
+		Expect(pool.poolMetrics.Shots.Get()).To(BeNumerically(">", 0))
+	}, 2)
+
+	It("stops instances by startup schedule", func() {
+		conf, _ := newTestPoolConf()
+		conf.NewRPSSchedule = nil
+		conf.ClosedLoop = &ClosedLoopConfig{
+			ThinkTime: 10 * time.Millisecond,
+			Duration:  time.Second,
+		}
+		conf.StartupSchedule = schedule.NewInstanceProfile([]schedule.InstanceProfileStep{
+			{To: 3, Duration: 0},
+			{To: 3, Duration: 100 * time.Millisecond},
+			{To: 1, Duration: 0},
+		})
+		pool := newPool(ginkgoutil.NewLogger(), newTestMetrics(), nil, conf)
+
+		errs := make(chan error, 1)
+		go func() { errs <- pool.Run(context.Background()) }()
+		Eventually(pool.metrics.InstanceFinish.Get, 0.5).Should(BeEquivalentTo(2))
+		Expect(pool.poolMetrics.ActiveInstances.Get()).To(BeEquivalentTo(1))
+		Expect(pool.metrics.InstanceStart.Get()).To(BeEquivalentTo(3))
+
+		Expect(<-errs).NotTo(HaveOccurred())
+		Expect(pool.metrics.InstanceFinish.Get()).To(BeEquivalentTo(3))
+		Expect(pool.poolMetrics.ActiveInstances.Get()).To(BeEquivalentTo(0))
+	}, 3)
+
+	It("checks the first startup token for stop", func() {
+		conf, _ := newTestPoolConf()
+		conf.NewRPSSchedule = nil
+		conf.ClosedLoop = &ClosedLoopConfig{
+			ThinkTime: 10 * time.Millisecond,
+			Duration:  100 * time.Millisecond,
+		}
+		conf.StartupSchedule = newTestStopSchedule(true, false, false)
+		pool := newPool(ginkgoutil.NewLogger(), newTestMetrics(), nil, conf)
+
+		Expect(pool.Run(context.Background())).NotTo(HaveOccurred())
+		Expect(pool.metrics.InstanceStart.Get()).To(BeEquivalentTo(2))
+		Expect(pool.metrics.InstanceFinish.Get()).To(BeEquivalentTo(2))
+	}, 3)
+
+	It("schedule waits think time between shoots", func() {
+		const thinkTime = time.Second
+		sched := newClosedLoopSchedule(thinkTime, time.Time{})
+		Expect(sched.Left()).To(Equal(-1))
+		x1, ok := sched.Next()
+		Expect(ok).To(BeTrue())
+		Expect(x1).To(BeTemporally("~", time.Now(), 100*time.Millisecond))
+		x2, ok := sched.Next()
+		Expect(ok).To(BeTrue())
+		Expect(x2).To(BeTemporally("~", x1.Add(thinkTime), 100*time.Millisecond))
+	})
+
+	It("schedule emits first token immediately after start", func() {
+		const thinkTime = time.Second
+		sched := newClosedLoopSchedule(thinkTime, time.Time{})
+		sched.Start(time.Now())
+		x1, ok := sched.Next()
+		Expect(ok).To(BeTrue())
+		Expect(x1).To(BeTemporally("~", time.Now(), 100*time.Millisecond))
+		x2, ok := sched.Next()
+		Expect(ok).To(BeTrue())
+		Expect(x2).To(BeTemporally("~", x1.Add(thinkTime), 100*time.Millisecond))
+	})
+
+	It("schedule finishes", func() {
+		finish := time.Now().Add(time.Second)
+		sched := newClosedLoopSchedule(2*time.Second, finish)
+		_, ok := sched.Next()
+		Expect(ok).To(BeTrue())
+		x, ok := sched.Next()
+		Expect(ok).To(BeFalse())
+		Expect(x).To(Equal(finish))
+	})
+})
+
 // TODO instance start canceled after out of ammo
 // TODO instance start cancdled after RPS finish
 
@@ -266,7 +401,7 @@ var _ = Describe("engine", func() {
 
 	JustBeforeEach(func() {
 		metrics := newTestMetrics()
-		engine = New(ginkgoutil.NewLogger(), metrics, Config{confs})
+		engine = New(ginkgoutil.NewLogger(), metrics, Config{Pools: confs})
 	})
 
 	Context("shoot ok", func() {
@@ -274,6 +409,10 @@ var _ = Describe("engine", func() {
 			err := engine.Run(ctx)
 			Expect(err).To(BeNil())
 			ginkgoutil.AssertExpectations(gun1, gun2)
+			for _, status := range engine.PoolsStatus() {
+				Expect(status.State).To(Equal(PoolFinished))
+				Expect(status.Error).To(BeEmpty())
+			}
 		})
 	})
 
@@ -330,11 +469,107 @@ var _ = Describe("engine", func() {
 			err := engine.Run(ctx)
 			Expect(err).ToNot(BeNil())
 			Expect(err.Error()).To(ContainSubstring(failErr.Error()))
+			var aggregatorErr *AggregatorError
+			Expect(errors.As(err, &aggregatorErr)).To(BeTrue())
+			Expect(aggregatorErr.Err).To(Equal(failErr))
 			engine.Wait()
+			statuses := engine.PoolsStatus()
+			Expect(statuses[0].State).To(Equal(PoolFailed))
+			Expect(statuses[0].Error).To(ContainSubstring(failErr.Error()))
+			Expect(statuses[1].State).To(BeElementOf(PoolCanceled, PoolFinished))
 		}, 1)
 	})
 })
 
+var _ = Describe("engine autostop", func() {
+	It("cancels run", func() {
+		conf, _ := newTestPoolConf()
+		conf.NewRPSSchedule = func() (core.Schedule, error) {
+			return schedule.NewConst(10, time.Hour), nil
+		}
+		criterion := autostop.NewNoResponse(autostop.NoResponseConfig{Duration: time.Second})
+		engine := New(ginkgoutil.NewLogger(), newTestMetrics(), Config{
+			Pools:    []InstancePoolConfig{conf},
+			Autostop: []autostop.Criterion{criterion},
+		})
+		err := engine.Run(context.Background())
+		var stopErr *autostop.Error
+		Expect(errors.As(err, &stopErr)).To(BeTrue())
+		Expect(err).To(MatchError(ContainSubstring("no responses for 1s")))
+		engine.Wait()
+	}, 3)
+
+	It("no response is not triggered before delayed start", func() {
+		conf, _ := newTestPoolConf()
+		conf.StartDelay = 2500 * time.Millisecond
+		conf.NewRPSSchedule = func() (core.Schedule, error) {
+			return schedule.NewConst(10, 500*time.Millisecond), nil
+		}
+		criterion := autostop.NewNoResponse(autostop.NoResponseConfig{Duration: time.Second})
+		engine := New(ginkgoutil.NewLogger(), newTestMetrics(), Config{
+			Pools:    []InstancePoolConfig{conf},
+			Autostop: []autostop.Criterion{criterion},
+		})
+		Expect(engine.Run(context.Background())).To(Succeed())
+		engine.Wait()
+	}, 5)
+})
+
+var _ = Describe("engine sample observers", func() {
+	It("observe samples of all pools", func() {
+		newConf := func() InstancePoolConfig {
+			conf, _ := newTestPoolConf()
+			gun := &coremock.Gun{}
+			var aggr core.Aggregator
+			gun.On("Bind", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+				aggr = args.Get(0).(core.Aggregator)
+			})
+			gun.On("Shoot", mock.Anything).Run(func(args mock.Arguments) {
+				aggr.Report(args.Get(0))
+			})
+			conf.NewGun = func() (core.Gun, error) { return gun, nil }
+			return conf
+		}
+		engine := New(ginkgoutil.NewLogger(), newTestMetrics(), Config{
+			Pools: []InstancePoolConfig{newConf(), newConf()},
+		})
+		observer := &testSampleObserver{}
+		engine.AddSampleObserver(observer)
+		Expect(engine.Run(context.Background())).To(Succeed())
+		Expect(observer.samples.Load()).To(BeEquivalentTo(2))
+	})
+})
+
+type testSampleObserver struct {
+	samples atomic.Int64
+}
+
+func (o *testSampleObserver) ObserveSample(core.Sample) { o.samples.Inc() }
+
+// testStopSchedule is startup schedule, that gives token for every passed flag at once.
+// Token stops instance, if its flag is true.
+type testStopSchedule struct {
+	core.Schedule
+	stops []bool
+	stop  bool
+}
+
+var _ core.InstanceStopSchedule = (*testStopSchedule)(nil)
+
+func newTestStopSchedule(stops ...bool) *testStopSchedule {
+	return &testStopSchedule{Schedule: schedule.NewOnce(int64(len(stops))), stops: stops}
+}
+
+func (s *testStopSchedule) Next() (time.Time, bool) {
+	ts, ok := s.Schedule.Next()
+	if ok {
+		s.stop, s.stops = s.stops[0], s.stops[1:]
+	}
+	return ts, ok
+}
+
+func (s *testStopSchedule) IsStop() bool { return s.stop }
+
 var _ = Describe("build instance schedule", func() {
 	It("per instance schedule ", func() {
 		conf, _ := newTestPoolConf()
@@ -344,7 +579,40 @@ var _ = Describe("build instance schedule", func() {
 			Fail("should not be called")
 		})
 		Expect(err).NotTo(HaveOccurred())
-		ginkgoutil.ExpectFuncsEqual(newInstanceSchedule, conf.NewRPSSchedule)
+		first, err := newInstanceSchedule(0)
+		Expect(err).NotTo(HaveOccurred())
+		second, err := newInstanceSchedule(1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(first).NotTo(BeIdenticalTo(second))
+		Expect(first).To(BeAssignableToTypeOf(&controlledSchedule{}))
+	})
+
+	It("per instance random schedules with fixed seed differ", func() {
+		conf, _ := newTestPoolConf()
+		conf.RPSPerInstance = true
+		conf.NewRPSSchedule = func() (core.Schedule, error) {
+			return schedule.NewPoisson(100, time.Second, 1), nil
+		}
+		pool := newPool(ginkgoutil.NewLogger(), newTestMetrics(), nil, conf)
+		newInstanceSchedule, err := pool.buildNewInstanceSchedule(context.Background(), func() {
+			Fail("should not be called")
+		})
+		Expect(err).NotTo(HaveOccurred())
+		start := time.Now()
+		tokens := func(id int) []time.Time {
+			s, err := newInstanceSchedule(id)
+			Expect(err).NotTo(HaveOccurred())
+			s.Start(start)
+			var tokens []time.Time
+			for ts, ok := s.Next(); ok; ts, ok = s.Next() {
+				tokens = append(tokens, ts)
+			}
+			return tokens
+		}
+		first := tokens(0)
+		Expect(first).NotTo(BeEmpty())
+		Expect(tokens(1)).NotTo(Equal(first))
+		Expect(tokens(0)).To(Equal(first), "same instance id")
 	})
 
 	It("shared schedule create failed", func() {
@@ -374,10 +642,10 @@ var _ = Describe("build instance schedule", func() {
 		newInstanceSchedule, err := pool.buildNewInstanceSchedule(context.Background(), cancel)
 		Expect(err).NotTo(HaveOccurred())
 
-		schedule, err := newInstanceSchedule()
+		schedule, err := newInstanceSchedule(0)
 		Expect(err).NotTo(HaveOccurred())
 
-		Expect(newInstanceSchedule()).To(Equal(schedule))
+		Expect(newInstanceSchedule(1)).To(Equal(schedule))
 
 		Expect(ctx.Done()).NotTo(BeClosed())
 		_, ok := schedule.Next()
@@ -388,4 +656,15 @@ var _ = Describe("build instance schedule", func() {
 		Expect(ctx.Done()).To(BeClosed())
 	})
 
+	It("shared schedule observes samples", func() {
+		conf, _ := newTestPoolConf()
+		conf.NewRPSSchedule = func() (core.Schedule, error) {
+			return schedule.NewAdaptive(schedule.AdaptiveConfig{From: 1, StepDuration: time.Second}), nil
+		}
+		pool := newPool(ginkgoutil.NewLogger(), newTestMetrics(), nil, conf)
+		_, err := pool.buildNewInstanceSchedule(context.Background(), func() {})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pool.sampleObservers).To(HaveLen(1))
+	})
+
 })
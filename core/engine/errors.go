@@ -0,0 +1,18 @@
+package engine
+
+// ProviderError is pool run error, caused by ammo provider fail.
+type ProviderError struct {
+	Err error
+}
+
+func (e *ProviderError) Error() string { return e.Err.Error() }
+func (e *ProviderError) Unwrap() error { return e.Err }
+
+// AggregatorError is pool run error, caused by aggregator fail. For example, some samples
+// were dropped, or result write failed.
+type AggregatorError struct {
+	Err error
+}
+
+func (e *AggregatorError) Error() string { return e.Err.Error() }
+func (e *AggregatorError) Unwrap() error { return e.Err }
@@ -9,11 +9,13 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"sync"
 
 	"github.com/pkg/errors"
 	"github.com/yandex/pandora/core"
 	"github.com/yandex/pandora/core/aggregator/netsample"
 	"github.com/yandex/pandora/core/coreutil"
+	"github.com/yandex/pandora/core/lifecycle"
 	"github.com/yandex/pandora/core/warmup"
 	"github.com/yandex/pandora/lib/tag"
 	"go.uber.org/zap"
@@ -24,16 +26,22 @@ type instance struct {
 	id       int
 	gun      core.Gun
 	schedule core.Schedule
+	gunDeps  core.GunDeps
+	// Optional. Closed on stop by startup schedule: like on drain, shoot in flight is finished.
+	stop <-chan struct{}
 	instanceSharedDeps
 }
 
 func newInstance(ctx context.Context, log *zap.Logger, poolID string, id int, deps instanceDeps) (*instance, error) {
 	log = log.With(zap.Int("instance", id))
-	gunDeps := core.GunDeps{Ctx: ctx, Log: log, PoolID: poolID, InstanceID: id}
-	sched, err := deps.newSchedule()
+	gunDeps := core.GunDeps{Ctx: ctx, Log: log, PoolID: poolID, InstanceID: id, State: core.NewInstanceState()}
+	sched, err := deps.newSchedule(id)
 	if err != nil {
 		return nil, err
 	}
+	if deps.ammoOrder != nil {
+		sched = &orderedSchedule{Schedule: sched}
+	}
 	gun, err := deps.newGun()
 	if err != nil {
 		return nil, err
@@ -47,22 +55,29 @@ func newInstance(ctx context.Context, log *zap.Logger, poolID string, id int, de
 	if err != nil {
 		return nil, err
 	}
-	inst := &instance{log, id, gun, sched, deps.instanceSharedDeps}
+	inst := &instance{log, id, gun, sched, gunDeps, deps.stop, deps.instanceSharedDeps}
 	return inst, nil
 }
 
 type instanceDeps struct {
-	newSchedule func() (core.Schedule, error)
+	newSchedule func(instanceID int) (core.Schedule, error)
 	newGun      func() (core.Gun, error)
 	instanceSharedDeps
+	stop <-chan struct{}
 }
 
 type instanceSharedDeps struct {
 	provider        core.Provider
 	metrics         Metrics
+	poolMetrics     *poolMetrics
 	gunWarmUpResult interface{}
 	aggregator      core.Aggregator
 	discardOverflow bool
+	control         *poolControl // Optional. Pauses shooting, if set.
+	// Optional. Closed on drain: ammo is not acquired after that, but shoot in flight is finished.
+	drain <-chan struct{}
+	// Optional. Set, if ammo SHOULD be shot in acquire order. Locked on ammo acquire and schedule token take.
+	ammoOrder *sync.Mutex
 }
 
 // Run blocks until ammo finish, error or context cancel.
@@ -76,16 +91,38 @@ func (i *instance) Run(ctx context.Context) (recoverErr error) {
 
 		i.log.Debug("Instance finished")
 		i.metrics.InstanceFinish.Add(1)
+		i.poolMetrics.ActiveInstances.Add(-1)
 	}()
 	i.log.Debug("Instance started")
 	i.metrics.InstanceStart.Add(1)
+	i.poolMetrics.ActiveInstances.Add(1)
 
-	waiter := coreutil.NewWaiter(i.schedule, ctx)
+	if starter, ok := i.gun.(lifecycle.InstanceStarter); ok {
+		err := starter.OnInstanceStart(i.lifecycleDeps())
+		if err != nil {
+			return errors.WithMessage(err, "gun instance start failed")
+		}
+	}
+	if stopper, ok := i.gun.(lifecycle.InstanceStopper); ok {
+		defer func() {
+			err := stopper.OnInstanceStop(i.lifecycleDeps())
+			if err != nil {
+				i.log.Warn("Gun instance stop fail", zap.Error(err))
+			}
+		}()
+	}
+
+	// Gun gets run ctx, so shoots in flight are not canceled on drain or stop.
+	waitCtx, waitCancel := withDrain(ctx, i.drain)
+	defer waitCancel()
+	waitCtx, stopCancel := withDrain(waitCtx, i.stop)
+	defer stopCancel()
+	waiter := coreutil.NewWaiter(i.schedule, waitCtx)
 	// Checking, that schedule is not finished, required, to not consume extra ammo,
 	// on finish in case of per instance schedule.
 	for !waiter.IsFinished() {
 		err := func() error {
-			ammo, ok := i.provider.Acquire()
+			ammo, ok := i.acquire()
 			if !ok {
 				i.log.Debug("Out of ammo")
 				return outOfAmmoErr
@@ -94,16 +131,20 @@ func (i *instance) Run(ctx context.Context) (recoverErr error) {
 			if tag.Debug {
 				i.log.Debug("Ammo acquired", zap.Any("ammo", ammo))
 			}
-			if !waiter.Wait() {
+			if !waiter.Wait() || !i.awaitResumed(waitCtx) {
 				return nil
 			}
 			if !i.discardOverflow || !waiter.IsSlowDown() {
+				if ammo, ok := ammo.(core.ScheduledAmmo); ok {
+					ammo.SetScheduledTime(waiter.Scheduled())
+				}
 				i.metrics.Request.Add(1)
 				if tag.Debug {
 					i.log.Debug("Shooting", zap.Any("ammo", ammo))
 				}
 				i.gun.Shoot(ammo)
 				i.metrics.Response.Add(1)
+				i.poolMetrics.Shots.Add(1)
 			} else {
 				i.aggregator.Report(netsample.DiscardedShootSample())
 			}
@@ -116,6 +157,29 @@ func (i *instance) Run(ctx context.Context) (recoverErr error) {
 	return ctx.Err()
 }
 
+// acquire acquires ammo. If ammo is ordered, schedule token for ammo is taken at the same time.
+func (i *instance) acquire() (core.Ammo, bool) {
+	sched, ok := i.schedule.(*orderedSchedule)
+	if !ok {
+		return i.provider.Acquire()
+	}
+	i.ammoOrder.Lock()
+	defer i.ammoOrder.Unlock()
+	ammo, ok := i.provider.Acquire()
+	if ok {
+		sched.fetch()
+	}
+	return ammo, ok
+}
+
+func (i *instance) lifecycleDeps() lifecycle.InstanceDeps {
+	return lifecycle.InstanceDeps{GunDeps: i.gunDeps, Provider: i.provider, Aggregator: i.aggregator}
+}
+
+func (i *instance) awaitResumed(ctx context.Context) bool {
+	return i.control == nil || i.control.awaitResumed(ctx)
+}
+
 func (i *instance) Close() error {
 	gunCloser, ok := i.gun.(io.Closer)
 	if !ok {
@@ -9,6 +9,7 @@ import (
 	. "github.com/onsi/gomega"
 	"github.com/stretchr/testify/mock"
 	"github.com/yandex/pandora/core"
+	"github.com/yandex/pandora/core/lifecycle"
 	coremock "github.com/yandex/pandora/core/mocks"
 	"github.com/yandex/pandora/core/schedule"
 	"github.com/yandex/pandora/lib/ginkgoutil"
@@ -28,7 +29,7 @@ var _ = Describe("Instance", func() {
 		ins          *instance
 		insCreateErr error
 
-		newSchedule func() (core.Schedule, error)
+		newSchedule func(int) (core.Schedule, error)
 		newGun      func() (core.Gun, error)
 	)
 
@@ -41,7 +42,7 @@ var _ = Describe("Instance", func() {
 		newScheduleErr = nil
 		ctx = context.Background()
 		metrics = newTestMetrics()
-		newSchedule = func() (core.Schedule, error) { return sched, newScheduleErr }
+		newSchedule = func(int) (core.Schedule, error) { return sched, newScheduleErr }
 		newGun = func() (core.Gun, error) { return gun, newGunErr }
 	})
 
@@ -53,10 +54,15 @@ var _ = Describe("Instance", func() {
 			instanceSharedDeps{
 				provider,
 				metrics,
+				&poolMetrics{},
 				nil,
 				aggregator,
 				false,
+				nil,
+				nil,
+				nil,
 			},
+			nil,
 		}
 		ins, insCreateErr = newInstance(ctx, ginkgoutil.NewLogger(), "pool_0", 0, deps)
 	})
@@ -113,6 +119,85 @@ var _ = Describe("Instance", func() {
 		})
 	})
 
+	Context("gun implements lifecycle hooks", func() {
+		var hooksGun *mockLifecycleGun
+		BeforeEach(func() {
+			sched = schedule.NewOnce(1)
+			gun.On("Bind", aggregator, mock.Anything).Return(nil).Once()
+			hooksGun = &mockLifecycleGun{Gun: gun}
+			newGun = func() (core.Gun, error) {
+				return hooksGun, nil
+			}
+		})
+		JustBeforeEach(func() {
+			Expect(insCreateErr).NotTo(HaveOccurred())
+		})
+
+		It("hooks called around shoots and share state", func() {
+			provider.On("Acquire").Return(1, true).Once()
+			provider.On("Release", 1).Once()
+			gun.On("Shoot", 1).Once()
+			var started bool
+			hooksGun.onStart = func(deps lifecycle.InstanceDeps) error {
+				Expect(deps.Provider).To(Equal(provider))
+				Expect(deps.Aggregator).To(Equal(aggregator))
+				Expect(deps.PoolID).To(Equal("pool_0"))
+				started = true
+				testSessionKey.Set(deps.State, "session")
+				return nil
+			}
+			var stopped bool
+			hooksGun.onStop = func(deps lifecycle.InstanceDeps) error {
+				Expect(started).To(BeTrue())
+				session, ok := testSessionKey.Get(deps.State)
+				Expect(ok).To(BeTrue())
+				Expect(session).To(Equal("session"))
+				stopped = true
+				return errors.New("stop failed")
+			}
+			err := ins.Run(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stopped).To(BeTrue())
+			ginkgoutil.AssertExpectations(gun, provider)
+		})
+
+		It("start failed", func() {
+			startErr := errors.New("start failed")
+			hooksGun.onStart = func(lifecycle.InstanceDeps) error {
+				return startErr
+			}
+			var stopped bool
+			hooksGun.onStop = func(lifecycle.InstanceDeps) error {
+				stopped = true
+				return nil
+			}
+			err := ins.Run(ctx)
+			Expect(err).To(MatchError(ContainSubstring(startErr.Error())))
+			Expect(stopped).To(BeFalse())
+			ginkgoutil.AssertNotCalled(gun, "Shoot")
+			ginkgoutil.AssertNotCalled(provider, "Acquire")
+		})
+	})
+
+	Context("ammo implements core.ScheduledAmmo", func() {
+		BeforeEach(func() {
+			sched = schedule.NewOnce(1)
+			gun.On("Bind", aggregator, mock.Anything).Return(nil).Once()
+		})
+		It("scheduled time set before shoot", func() {
+			ammo := &testScheduledAmmo{}
+			provider.On("Acquire").Return(ammo, true).Once()
+			provider.On("Release", ammo).Once()
+			gun.On("Shoot", ammo).Run(func(mock.Arguments) {
+				Expect(ammo.ScheduledTime()).To(BeTemporally("~", time.Now(), time.Second))
+			}).Once()
+			Expect(insCreateErr).NotTo(HaveOccurred())
+			err := ins.Run(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			ginkgoutil.AssertExpectations(gun, provider)
+		})
+	})
+
 	Context("context canceled after run", func() {
 		BeforeEach(func() {
 			var cancel context.CancelFunc
@@ -185,3 +270,26 @@ func (_m mockGunCloser) Close() error {
 	}
 	return r0
 }
+
+var testSessionKey = core.NewStateKey[string]("session")
+
+type testScheduledAmmo struct {
+	scheduled time.Time
+}
+
+func (a *testScheduledAmmo) ScheduledTime() time.Time     { return a.scheduled }
+func (a *testScheduledAmmo) SetScheduledTime(t time.Time) { a.scheduled = t }
+
+type mockLifecycleGun struct {
+	*coremock.Gun
+	onStart func(deps lifecycle.InstanceDeps) error
+	onStop  func(deps lifecycle.InstanceDeps) error
+}
+
+func (g *mockLifecycleGun) OnInstanceStart(deps lifecycle.InstanceDeps) error {
+	return g.onStart(deps)
+}
+
+func (g *mockLifecycleGun) OnInstanceStop(deps lifecycle.InstanceDeps) error {
+	return g.onStop(deps)
+}
@@ -0,0 +1,36 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/yandex/pandora/core"
+)
+
+// orderedSchedule is Instance schedule, that takes shared schedule token right after ammo
+// Acquire, so all Instances of pool take ammo and schedule tokens in the same order.
+// See core.OrderedProvider.
+type orderedSchedule struct {
+	core.Schedule
+	next    time.Time
+	ok      bool
+	fetched bool
+}
+
+// fetch takes token from shared schedule, that will be returned on next Next call.
+func (s *orderedSchedule) fetch() {
+	s.next, s.ok = s.Schedule.Next()
+	s.fetched = true
+}
+
+func (s *orderedSchedule) Next() (time.Time, bool) {
+	if !s.fetched {
+		return s.Schedule.Next()
+	}
+	s.fetched = false
+	return s.next, s.ok
+}
+
+func isAmmoOrdered(provider core.Provider) bool {
+	ordered, ok := provider.(core.OrderedProvider)
+	return ok && ordered.AmmoOrdered()
+}
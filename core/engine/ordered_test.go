@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/yandex/pandora/core"
+	"github.com/yandex/pandora/core/aggregator"
+	"github.com/yandex/pandora/core/aggregator/netsample"
+	"github.com/yandex/pandora/core/provider"
+	"github.com/yandex/pandora/core/schedule"
+	"github.com/yandex/pandora/lib/ginkgoutil"
+)
+
+// testOrderedProvider sleeps after ammo acquire, so Instances race for schedule tokens.
+// Ammo is testNumAmmo.
+type testOrderedProvider struct {
+	core.Provider
+}
+
+type testNumAmmo struct {
+	testScheduledAmmo
+	n int
+}
+
+func (p testOrderedProvider) AmmoOrdered() bool { return true }
+
+func (p testOrderedProvider) Acquire() (core.Ammo, bool) {
+	ammo, ok := p.Provider.Acquire()
+	time.Sleep(time.Duration(rand.Intn(100)) * time.Microsecond)
+	if !ok {
+		return nil, false
+	}
+	return &testNumAmmo{n: ammo.(int)}, true
+}
+
+// testTagGun reports sample tagged with ammo number on every shoot.
+type testTagGun struct {
+	aggregator core.Aggregator
+}
+
+func (g *testTagGun) Bind(a core.Aggregator, _ core.GunDeps) error {
+	g.aggregator = a
+	return nil
+}
+
+func (g *testTagGun) Shoot(ammo core.Ammo) {
+	numAmmo := ammo.(*testNumAmmo)
+	sample := netsample.Acquire(strconv.Itoa(numAmmo.n))
+	sample.SetScheduledTime(numAmmo.ScheduledTime())
+	g.aggregator.Report(sample)
+}
+
+var _ = Describe("ordered ammo", func() {
+	It("ammo is shot at schedule token with the same number", func() {
+		const shots = 200
+		agg := aggregator.NewTest()
+		conf, _ := newTestPoolConf()
+		conf.Provider = testOrderedProvider{provider.NewNum(shots)}
+		conf.Aggregator = agg
+		conf.NewGun = func() (core.Gun, error) { return &testTagGun{}, nil }
+		conf.NewRPSSchedule = func() (core.Schedule, error) {
+			return schedule.NewConst(1000, shots*time.Millisecond), nil
+		}
+		conf.StartupSchedule = schedule.NewOnce(10)
+		pool := newPool(ginkgoutil.NewLogger(), newTestMetrics(), nil, conf)
+
+		Expect(pool.Run(context.Background())).To(Succeed())
+		samples := agg.GetSamples()
+		Expect(samples).To(HaveLen(shots))
+		scheduled := map[int]time.Time{}
+		for _, s := range samples {
+			sample := s.(*netsample.Sample)
+			n, err := strconv.Atoi(sample.Tags())
+			Expect(err).NotTo(HaveOccurred())
+			scheduled[n] = sample.ScheduledTime()
+		}
+		for n := 0; n < shots; n++ {
+			Expect(scheduled[n].Sub(scheduled[0])).To(Equal(time.Duration(n)*time.Millisecond), "ammo %v", n)
+		}
+	}, 2)
+})
@@ -0,0 +1,151 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// startGate holds pools start, until their start conditions are met: start delay since engine start,
+// warm-up of pools they start after, and release of their start barrier.
+type startGate struct {
+	engineStart time.Time
+	// warmedUp barriers are released, when gun warm-up of all pools with ID is finished.
+	warmedUp map[string]*startBarrier
+	barriers map[string]*startBarrier
+}
+
+func newStartGate(engineStart time.Time, pools []InstancePoolConfig) *startGate {
+	g := &startGate{
+		engineStart: engineStart,
+		warmedUp:    map[string]*startBarrier{},
+		barriers:    map[string]*startBarrier{},
+	}
+	add := func(barriers map[string]*startBarrier, name string) {
+		barrier, ok := barriers[name]
+		if !ok {
+			barrier = &startBarrier{released: make(chan struct{})}
+			barriers[name] = barrier
+		}
+		barrier.pools++
+	}
+	for _, pool := range pools {
+		add(g.warmedUp, pool.ID)
+		if pool.StartBarrier != "" {
+			add(g.barriers, pool.StartBarrier)
+		}
+	}
+	return g
+}
+
+// startBarrier is released, when all its pools have arrived or left.
+type startBarrier struct {
+	mu       sync.Mutex
+	pools    int
+	arrived  int
+	left     int
+	released chan struct{}
+	// err is set before release, if some pool has left with error.
+	err error
+}
+
+func (b *startBarrier) arrive() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.arrived++
+	b.releaseIfAll()
+}
+
+// leave is called instead of arrive by pool, that has finished before arrival.
+// Awaiting pools get err after release, if it is not nil.
+func (b *startBarrier) leave(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.left++
+	if b.err == nil {
+		b.err = err
+	}
+	b.releaseIfAll()
+}
+
+func (b *startBarrier) releaseIfAll() {
+	if b.arrived+b.left == b.pools {
+		close(b.released)
+	}
+}
+
+func (b *startBarrier) await(ctx context.Context) error {
+	select {
+	case <-b.released:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
+// awaitStart marks pool gun as warmed up, and blocks until pool start conditions are met.
+func (p *instancePool) awaitStart(ctx context.Context) error {
+	g := p.startGate
+	if g == nil {
+		return nil
+	}
+	g.warmedUp[p.ID].arrive()
+	p.arrivedWarmedUp = true
+	for _, id := range p.StartAfter {
+		after, ok := g.warmedUp[id]
+		if !ok {
+			return errors.Errorf("start after unknown pool %q", id)
+		}
+		select {
+		case <-after.released:
+		default:
+			p.log.Info("Awaiting pool warm-up", zap.String("start_after", id))
+		}
+		if err := after.await(ctx); err != nil {
+			return err
+		}
+	}
+	if wait := time.Until(g.engineStart.Add(p.StartDelay)); wait > 0 {
+		p.log.Info("Awaiting pool start delay", zap.Duration("wait", wait))
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if p.StartBarrier != "" {
+		barrier := g.barriers[p.StartBarrier]
+		barrier.arrive()
+		p.arrivedBarrier = true
+		p.log.Info("Awaiting pool start barrier", zap.String("barrier", p.StartBarrier))
+		if err := barrier.await(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// leaveStart releases start gate slots of pool, that has finished before arrival, so pools that start
+// after it or share its barrier are not blocked forever. Pools that start after it fail, because its
+// gun has not been warmed up, and barrier is released, when all other its pools have arrived.
+func (p *instancePool) leaveStart() {
+	g := p.startGate
+	if g == nil {
+		return
+	}
+	if !p.arrivedWarmedUp {
+		g.warmedUp[p.ID].leave(errors.Errorf("pool %q has finished before gun warm-up", p.ID))
+		p.arrivedWarmedUp = true
+	}
+	if p.StartBarrier != "" && !p.arrivedBarrier {
+		g.barriers[p.StartBarrier].leave(nil)
+		p.arrivedBarrier = true
+	}
+}
@@ -0,0 +1,162 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"github.com/yandex/pandora/core"
+	"github.com/yandex/pandora/core/config"
+	"github.com/yandex/pandora/core/warmup"
+	"github.com/yandex/pandora/lib/ginkgoutil"
+)
+
+// testStartGun records time of the first shot, and sleeps on warm-up.
+type testStartGun struct {
+	warmUp  time.Duration
+	mu      *sync.Mutex
+	firstAt *time.Time
+}
+
+func (g *testStartGun) Bind(core.Aggregator, core.GunDeps) error { return nil }
+
+func (g *testStartGun) Shoot(core.Ammo) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.firstAt.IsZero() {
+		*g.firstAt = time.Now()
+	}
+}
+
+func (g *testStartGun) WarmUp(*warmup.Options) (interface{}, error) {
+	time.Sleep(g.warmUp)
+	return nil, nil
+}
+
+func (g *testStartGun) AcceptWarmUpResult(interface{}) error { return nil }
+
+var _ = Describe("pool start", func() {
+	newConf := func(id string, warmUp time.Duration) (InstancePoolConfig, func() time.Time) {
+		conf, _ := newTestPoolConf()
+		conf.ID = id
+		var (
+			mu      sync.Mutex
+			firstAt time.Time
+		)
+		conf.NewGun = func() (core.Gun, error) {
+			return &testStartGun{warmUp: warmUp, mu: &mu, firstAt: &firstAt}, nil
+		}
+		return conf, func() time.Time {
+			mu.Lock()
+			defer mu.Unlock()
+			return firstAt
+		}
+	}
+	run := func(pools ...InstancePoolConfig) (start time.Time) {
+		engine := New(ginkgoutil.NewLogger(), newTestMetrics(), Config{Pools: pools})
+		start = time.Now()
+		Expect(engine.Run(context.Background())).To(Succeed())
+		return start
+	}
+	const warmUp = 100 * time.Millisecond
+
+	It("start delay", func() {
+		conf, firstAt := newConf("a", 0)
+		conf.StartDelay = warmUp
+		start := run(conf)
+		Expect(firstAt().Sub(start)).To(BeNumerically(">=", warmUp))
+	})
+
+	It("start after pool warm-up", func() {
+		a, aFirstAt := newConf("a", warmUp)
+		b, bFirstAt := newConf("b", 0)
+		b.StartAfter = []string{"a"}
+		c, cFirstAt := newConf("c", 0)
+		start := run(a, b, c)
+		Expect(aFirstAt().Sub(start)).To(BeNumerically(">=", warmUp))
+		Expect(bFirstAt().Sub(start)).To(BeNumerically(">=", warmUp))
+		Expect(cFirstAt().Sub(start)).To(BeNumerically("<", warmUp))
+	})
+
+	It("start barrier", func() {
+		a, aFirstAt := newConf("a", warmUp)
+		a.StartBarrier = "barrier"
+		b, bFirstAt := newConf("b", 0)
+		b.StartBarrier = "barrier"
+		start := run(a, b)
+		Expect(bFirstAt().Sub(start)).To(BeNumerically(">=", warmUp))
+		Expect(bFirstAt().Sub(aFirstAt())).To(BeNumerically("~", 0, warmUp/2))
+	})
+
+	It("start after unknown pool fails", func() {
+		conf, _ := newConf("a", 0)
+		conf.StartAfter = []string{"b"}
+		engine := New(ginkgoutil.NewLogger(), newTestMetrics(), Config{Pools: []InstancePoolConfig{conf}})
+		err := engine.Run(context.Background())
+		Expect(err).To(MatchError(ContainSubstring(`start after unknown pool "b"`)))
+		engine.Wait()
+	})
+
+	It("pool finished before arrival releases its slots", func() {
+		a, _ := newConf("a", 0)
+		a.StartBarrier = "barrier"
+		a.NewGun = func() (core.Gun, error) { return nil, errors.New("gun failed") }
+		b, bFirstAt := newConf("b", 0)
+		b.StartBarrier = "barrier"
+		c, cFirstAt := newConf("c", 0)
+		c.StartAfter = []string{"a"}
+		gate := newStartGate(time.Now(), []InstancePoolConfig{a, b, c})
+		runPool := func(conf InstancePoolConfig) <-chan error {
+			pool := newPool(ginkgoutil.NewLogger(), newTestMetrics(), func() {}, conf)
+			pool.startGate = gate
+			errs := make(chan error, 1)
+			go func() { errs <- pool.Run(context.Background()) }()
+			return errs
+		}
+		bErr := runPool(b)
+		cErr := runPool(c)
+		Consistently(bErr, warmUp).ShouldNot(Receive())
+
+		Expect(<-runPool(a)).To(MatchError(ContainSubstring("gun failed")))
+		Eventually(bErr).Should(Receive(BeNil()))
+		Expect(bFirstAt().IsZero()).To(BeFalse())
+		Eventually(cErr).Should(Receive(MatchError(ContainSubstring(`pool "a" has finished before gun warm-up`))))
+		Expect(cFirstAt().IsZero()).To(BeTrue())
+	}, 2)
+
+	It("canceled while awaiting", func() {
+		conf, firstAt := newConf("a", 0)
+		conf.StartDelay = time.Hour
+		engine := New(ginkgoutil.NewLogger(), newTestMetrics(), Config{Pools: []InstancePoolConfig{conf}})
+		ctx, cancel := context.WithTimeout(context.Background(), warmUp)
+		defer cancel()
+		Expect(engine.Run(ctx)).To(Equal(context.DeadlineExceeded))
+		engine.Wait()
+		Expect(firstAt().IsZero()).To(BeTrue())
+	})
+
+	Context("config validation", func() {
+		validate := func(pools ...InstancePoolConfig) error {
+			return config.Validate(Config{Pools: pools})
+		}
+		It("default pool id", func() {
+			a, _ := newConf("", 0)
+			b, _ := newConf("b", 0)
+			b.StartAfter = []string{"pool_0"}
+			Expect(validate(a, b)).To(Succeed())
+		})
+		It("unknown pool", func() {
+			a, _ := newConf("a", 0)
+			a.StartAfter = []string{"b"}
+			Expect(validate(a)).To(MatchError(ContainSubstring(`starts after unknown pool "b"`)))
+		})
+		It("itself", func() {
+			a, _ := newConf("a", 0)
+			a.StartAfter = []string{"a"}
+			Expect(validate(a)).To(MatchError(ContainSubstring(`can't start after itself`)))
+		})
+	})
+})
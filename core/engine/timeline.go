@@ -0,0 +1,151 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/yandex/pandora/core"
+)
+
+// PoolTimeline is load of pool, planned by its schedules.
+type PoolTimeline struct {
+	ID string
+	// Shots is number of planned shots in every second since engine start. Nil for closed-loop pools.
+	Shots []int
+	// Instances is number of active instances at the end of every second since engine start.
+	// Instances stopped by core.InstanceStopSchedule are not active.
+	Instances []int
+	// Duration is time from engine start to the last planned shot or instance start.
+	Duration time.Duration
+	// Truncated is true, if schedules have been stopped after max shots or instances.
+	Truncated bool
+}
+
+// TotalShots returns total number of planned shots.
+func (t *PoolTimeline) TotalShots() int {
+	var shots int
+	for _, n := range t.Shots {
+		shots += n
+	}
+	return shots
+}
+
+// PlanPool walks pool startup and RPS schedules in simulated time, without waiting, and
+// returns planned load timeline. Only schedules, RPSPerInstance and StartDelay of passed config are used:
+// pool is planned to start right after start delay.
+// No more than maxShots tokens are taken from RPS schedules, and no more than maxShots
+// instances are started, so infinite schedules are truncated.
+// Schedules that depend on real time or feedback, like 'unlimited' or 'adaptive', can't be planned precisely.
+func PlanPool(conf InstancePoolConfig, maxShots int) (PoolTimeline, error) {
+	t := PoolTimeline{ID: conf.ID}
+	if conf.StartupSchedule == nil {
+		return t, errors.New("no startup schedule")
+	}
+	if conf.ClosedLoop == nil && conf.NewRPSSchedule == nil {
+		return t, errors.New("no rps schedule")
+	}
+	engineStart := time.Now()
+	start := engineStart.Add(conf.StartDelay)
+	second := func(ts time.Time) int {
+		if ts.Before(start) {
+			ts = start
+		}
+		if d := ts.Sub(engineStart); d > t.Duration {
+			t.Duration = d
+		}
+		return int(ts.Sub(engineStart) / time.Second)
+	}
+	var (
+		instanceStarts []time.Time
+		instanceStops  []time.Time // Zero, if instance is not stopped.
+		active         []int       // Indexes of active instances in start order.
+	)
+	addInstances := func(ts time.Time, n int) {
+		i := second(ts)
+		for len(t.Instances) <= i {
+			t.Instances = append(t.Instances, 0)
+		}
+		t.Instances[i] += n
+	}
+	startup := conf.StartupSchedule
+	stopSchedule, _ := startup.(core.InstanceStopSchedule)
+	startup.Start(start)
+	for {
+		ts, ok := startup.Next()
+		if !ok {
+			break
+		}
+		if stopSchedule != nil && stopSchedule.IsStop() {
+			// The latest started instance is stopped first, like in pool run.
+			if len(active) > 0 {
+				instanceStops[active[len(active)-1]] = ts
+				active = active[:len(active)-1]
+				addInstances(ts, -1)
+			}
+			continue
+		}
+		if len(instanceStarts) >= maxShots {
+			t.Truncated = true
+			break
+		}
+		active = append(active, len(instanceStarts))
+		instanceStarts = append(instanceStarts, ts)
+		instanceStops = append(instanceStops, time.Time{})
+		addInstances(ts, 1)
+	}
+
+	if conf.ClosedLoop == nil {
+		t.Shots = []int{}
+		shots := 0
+		addSchedule := func(s core.Schedule, scheduleStart, scheduleStop time.Time) {
+			s.Start(scheduleStart)
+			for ; shots < maxShots; shots++ {
+				ts, ok := s.Next()
+				if !ok {
+					return
+				}
+				if !scheduleStop.IsZero() && !ts.Before(scheduleStop) {
+					return
+				}
+				i := second(ts)
+				for len(t.Shots) <= i {
+					t.Shots = append(t.Shots, 0)
+				}
+				t.Shots[i]++
+			}
+			if _, ok := s.Next(); ok {
+				t.Truncated = true
+			}
+		}
+		if conf.RPSPerInstance {
+			for i, instanceStart := range instanceStarts {
+				s, err := conf.NewRPSSchedule()
+				if err != nil {
+					return t, err
+				}
+				addSchedule(s, instanceStart, instanceStops[i])
+				if t.Truncated {
+					break
+				}
+			}
+		} else {
+			s, err := conf.NewRPSSchedule()
+			if err != nil {
+				return t, err
+			}
+			addSchedule(s, start, time.Time{})
+		}
+	}
+
+	// Make instances cumulative, and align series.
+	for len(t.Instances) < len(t.Shots) {
+		t.Instances = append(t.Instances, 0)
+	}
+	for len(t.Shots) < len(t.Instances) && t.Shots != nil {
+		t.Shots = append(t.Shots, 0)
+	}
+	for i := 1; i < len(t.Instances); i++ {
+		t.Instances[i] += t.Instances[i-1]
+	}
+	return t, nil
+}
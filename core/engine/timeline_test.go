@@ -0,0 +1,112 @@
+package engine
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/yandex/pandora/core"
+	"github.com/yandex/pandora/core/schedule"
+)
+
+var _ = Describe("plan pool", func() {
+	var (
+		conf     InstancePoolConfig
+		maxShots int
+	)
+	BeforeEach(func() {
+		conf = InstancePoolConfig{
+			ID:              "pool",
+			StartupSchedule: schedule.NewOnce(2),
+			NewRPSSchedule: func() (core.Schedule, error) {
+				return schedule.NewConst(2, 3*time.Second), nil
+			},
+		}
+		maxShots = 1000
+	})
+
+	It("shared schedule", func() {
+		t, err := PlanPool(conf, maxShots)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(t.ID).To(Equal("pool"))
+		Expect(t.Shots).To(Equal([]int{2, 2, 2}))
+		Expect(t.Instances).To(Equal([]int{2, 2, 2}))
+		Expect(t.TotalShots()).To(Equal(6))
+		Expect(t.Duration).To(BeNumerically("~", 3*time.Second, time.Second))
+		Expect(t.Truncated).To(BeFalse())
+	})
+
+	It("per instance schedule", func() {
+		conf.RPSPerInstance = true
+		conf.StartupSchedule = schedule.NewConst(1, 3*time.Second)
+		conf.NewRPSSchedule = func() (core.Schedule, error) {
+			return schedule.NewOnce(2), nil
+		}
+		t, err := PlanPool(conf, maxShots)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(t.Shots).To(Equal([]int{2, 2, 2}))
+		Expect(t.Instances).To(Equal([]int{1, 2, 3}))
+		Expect(t.Duration).To(Equal(2 * time.Second))
+	})
+
+	It("start delay", func() {
+		conf.StartDelay = 2 * time.Second
+		t, err := PlanPool(conf, maxShots)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(t.Shots).To(Equal([]int{0, 0, 2, 2, 2}))
+		Expect(t.Instances).To(Equal([]int{0, 0, 2, 2, 2}))
+	})
+
+	It("truncated", func() {
+		maxShots = 4
+		t, err := PlanPool(conf, maxShots)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(t.Shots).To(Equal([]int{2, 2}))
+		Expect(t.Truncated).To(BeTrue())
+	})
+
+	It("closed-loop", func() {
+		conf.NewRPSSchedule = nil
+		conf.ClosedLoop = &ClosedLoopConfig{}
+		conf.StartupSchedule = schedule.NewInstanceStep(1, 3, 1, time.Second)
+		t, err := PlanPool(conf, maxShots)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(t.Shots).To(BeNil())
+		Expect(t.Instances).To(Equal([]int{1, 2, 3}))
+	})
+
+	It("instance profile", func() {
+		conf.RPSPerInstance = true
+		conf.StartupSchedule = schedule.NewInstanceProfile([]schedule.InstanceProfileStep{
+			{To: 3, Duration: 0},
+			{To: 3, Duration: time.Second},
+			{To: 1, Duration: time.Second},
+		})
+		conf.NewRPSSchedule = func() (core.Schedule, error) {
+			return schedule.NewConst(1, 3*time.Second), nil
+		}
+		t, err := PlanPool(conf, maxShots)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(t.Instances).To(Equal([]int{3, 1, 1}))
+		// Instance 2 is stopped at 1s, and instance 1 at 1.5s.
+		Expect(t.Shots).To(Equal([]int{3, 2, 1}))
+	})
+
+	It("ignores stop without active instances", func() {
+		conf.RPSPerInstance = true
+		conf.StartupSchedule = newTestStopSchedule(true, false, false)
+		conf.NewRPSSchedule = func() (core.Schedule, error) {
+			return schedule.NewOnce(1), nil
+		}
+		t, err := PlanPool(conf, maxShots)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(t.Instances).To(Equal([]int{2}))
+		Expect(t.Shots).To(Equal([]int{2}))
+	})
+
+	It("no schedules", func() {
+		conf.NewRPSSchedule = nil
+		_, err := PlanPool(conf, maxShots)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -12,6 +12,7 @@ import (
 	"github.com/yandex/pandora/core"
 	"github.com/yandex/pandora/core/aggregator"
 	"github.com/yandex/pandora/core/aggregator/netsample"
+	"github.com/yandex/pandora/core/autostop"
 	"github.com/yandex/pandora/core/config"
 	"github.com/yandex/pandora/core/datasink"
 	"github.com/yandex/pandora/core/datasource"
@@ -31,6 +32,7 @@ const (
 )
 
 // getter for fs to avoid afero dependency in custom guns
+// NOTE: returned fs is not sandboxed on 'pandora validate', as fs passed to Import is. See cli.PluginFs.
 func GetFs() afero.Fs {
 	return afero.NewOsFs()
 }
@@ -62,7 +64,9 @@ func Import(fs afero.Fs) {
 		stdinSourceKey = "stdin"
 	)
 	register.DataSource(stdinSourceKey, datasource.NewStdin)
-	AddSinkConfigHook(func(str string) (ok bool, pluginType string, _ map[string]interface{}) {
+	// "stdin" is source string, so it is hooked for sources. Sink "stdin" is a file, as any other
+	// not hooked sink string.
+	AddSourceConfigHook(func(str string) (ok bool, pluginType string, _ map[string]interface{}) {
 		if str != stdinSourceKey {
 			return
 		}
@@ -82,10 +86,19 @@ func Import(fs afero.Fs) {
 		a, err := netsample.NewPhout(fs, conf)
 		return netsample.WrapAggregator(a), err
 	}, netsample.DefaultPhoutConfig)
+	register.Aggregator("summary", func(conf netsample.SummaryConfig) core.Aggregator {
+		return netsample.WrapAggregator(netsample.NewSummary(conf))
+	}, netsample.DefaultSummaryConfig)
+	register.Aggregator("buckets", netsample.NewBucketsAggregator, netsample.DefaultBucketsConfig)
+	register.Aggregator("push", func(conf netsample.PushConfig) (core.Aggregator, error) {
+		a, err := netsample.NewPush(conf)
+		return netsample.WrapAggregator(a), err
+	}, netsample.DefaultPushConfig)
 	register.Aggregator("jsonlines", aggregator.NewJSONLinesAggregator, aggregator.DefaultJSONLinesAggregatorConfig)
 	register.Aggregator("json", aggregator.NewJSONLinesAggregator, aggregator.DefaultJSONLinesAggregatorConfig) // TODO(skipor): should be done via alias, but we don't have them yet
 	register.Aggregator("log", aggregator.NewLog)
 	register.Aggregator("discard", aggregator.NewDiscard)
+	register.Aggregator("multi", aggregator.NewMulti)
 
 	register.Limiter("line", schedule.NewLineConf)
 	register.Limiter("const", schedule.NewConstConf)
@@ -93,10 +106,29 @@ func Import(fs afero.Fs) {
 	register.Limiter("unlimited", schedule.NewUnlimitedConf)
 	register.Limiter("step", schedule.NewStepConf)
 	register.Limiter("instance_step", schedule.NewInstanceStepConf)
+	register.Limiter("instance_profile", schedule.NewInstanceProfileConf)
+	register.Limiter("adaptive", schedule.NewAdaptiveConf, schedule.DefaultAdaptiveConfig)
+	register.Limiter("sine", schedule.NewSineConf)
+	register.Limiter("exp", schedule.NewExpConf)
+	register.Limiter("expression", schedule.NewExpressionConf)
+	register.Limiter("poisson", schedule.NewPoissonConf)
+	register.Limiter("normal", schedule.NewNormalConf)
+	register.Limiter("on_off", schedule.NewOnOffConf)
+	register.Limiter("mmpp", schedule.NewMMPPConf)
+	register.Limiter("replay", schedule.NewReplayConf, schedule.DefaultReplayConfig)
 	register.Limiter(compositeScheduleKey, schedule.NewCompositeConf)
 
+	autostop.Register("http", autostop.NewHTTPCodes)
+	autostop.Register("net", autostop.NewNetCodes)
+	autostop.Register("quantile", autostop.NewQuantile, autostop.DefaultQuantileConfig)
+	autostop.Register("total-errors", autostop.NewTotalErrors)
+	autostop.Register("no-response", autostop.NewNoResponse)
+
 	config.AddTypeHook(sinkStringHook)
+	config.AddTypeHook(sourceStringHook)
 	config.AddTypeHook(scheduleSliceToCompositeConfigHook)
+	config.AddTypeHook(scheduleStringToCompositeConfigHook)
+	config.AddTypeHook(autostop.NumberToThresholdHook)
 
 	confutil.RegisterTagResolver("", confutil.EnvTagResolver)
 	confutil.RegisterTagResolver("ENV", confutil.EnvTagResolver)
@@ -9,8 +9,10 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/yandex/pandora/core"
+	"github.com/yandex/pandora/core/autostop"
 	"github.com/yandex/pandora/core/config"
 	"github.com/yandex/pandora/core/coretest"
 	"github.com/yandex/pandora/core/plugin"
@@ -56,6 +58,156 @@ var _ = Describe("plugin decode", func() {
 			coretest.ExpectScheduleNexts(sched, 0, 0, time.Second)
 		})
 	})
+
+	Context("schedule string", func() {
+		It("plugin", func() {
+			var conf struct {
+				Schedule core.Schedule
+			}
+			err := config.DecodeAndValidate(map[string]interface{}{"schedule": "once(2) const(1, 1s)"}, &conf)
+			Expect(err).NotTo(HaveOccurred())
+			coretest.ExpectScheduleNexts(conf.Schedule, 0, 0, 0, time.Second)
+		})
+
+		It("plugin factory", func() {
+			var conf struct {
+				Schedule func() (core.Schedule, error)
+			}
+			err := config.DecodeAndValidate(map[string]interface{}{"schedule": "line(0, 2, 2) instance_step(1, 2, 1, 1s)"}, &conf)
+			Expect(err).NotTo(HaveOccurred())
+			sched, err := conf.Schedule()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sched.Left()).To(Equal(2 + 2))
+		})
+
+		It("invalid", func() {
+			var conf struct {
+				Schedule core.Schedule
+			}
+			err := config.DecodeAndValidate(map[string]interface{}{"schedule": "const(1, 1s) step(1, 2, 1m)"}, &conf)
+			Expect(err).To(MatchError(ContainSubstring(`schedule segment 2 "step(1, 2, 1m)": step expects 4 arguments`)))
+		})
+	})
+
+	It("mmpp schedule", func() {
+		input := map[string]interface{}{
+			"schedule": map[string]interface{}{
+				"type":     "mmpp",
+				"duration": "1s",
+				"seed":     1,
+				"states": []map[string]interface{}{
+					{"ops": 0, "duration": "1s"},
+					{"ops": 100, "duration": "100ms"},
+				},
+			},
+		}
+		var conf struct {
+			Schedule core.Schedule
+		}
+		err := config.DecodeAndValidate(input, &conf)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conf.Schedule.Left()).To(Equal(-1))
+
+		input["schedule"].(map[string]interface{})["states"] = []map[string]interface{}{{"ops": 1, "duration": "0s"}}
+		err = config.DecodeAndValidate(input, &conf)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("instance profile schedule", func() {
+		input := map[string]interface{}{
+			"startup": map[string]interface{}{
+				"type": "instance_profile",
+				"steps": []map[string]interface{}{
+					{"to": 2, "duration": "0s"},
+					{"to": 1, "duration": "1s"},
+				},
+			},
+		}
+		var conf struct {
+			Startup core.Schedule
+		}
+		err := config.DecodeAndValidate(input, &conf)
+		Expect(err).NotTo(HaveOccurred())
+		_, ok := conf.Startup.(core.InstanceStopSchedule)
+		Expect(ok).To(BeTrue())
+		Expect(conf.Startup.Left()).To(Equal(3))
+	})
+
+	It("autostop criteria", func() {
+		input := map[string]interface{}{
+			"autostop": []map[string]interface{}{
+				{"type": "http", "codes": "5xx", "threshold": "10%", "duration": "1s"},
+				{"type": "net", "threshold": 5, "duration": "1s"},
+				{"type": "quantile", "latency": "1s", "duration": "2s"},
+				{"type": "total-errors", "threshold": 1.5},
+				{"type": "no-response", "duration": "1s"},
+			},
+		}
+		var conf struct {
+			Autostop []autostop.Criterion
+		}
+		err := config.DecodeAndValidate(input, &conf)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conf.Autostop).To(HaveLen(5))
+		second := &autostop.Second{Count: 1, Failed: 1, ProtoCodes: map[int]int64{503: 1}}
+		Expect(conf.Autostop[0].Check(second)).To(MatchError(ContainSubstring("5xx exceeded 10%")))
+	})
+
+	It("multi aggregator", func() {
+		input := map[string]interface{}{
+			"aggregator": map[string]interface{}{
+				"type": "multi",
+				"aggregators": []map[string]interface{}{
+					{"type": "discard"},
+					{"type": "jsonlines", "sink": "stdout"},
+				},
+			},
+		}
+		var conf struct {
+			Aggregator core.Aggregator
+		}
+		err := config.DecodeAndValidate(input, &conf)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conf.Aggregator).NotTo(BeNil())
+
+		input["aggregator"] = map[string]interface{}{"type": "multi"}
+		err = config.DecodeAndValidate(input, &conf)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("push aggregator", func() {
+		input := map[string]interface{}{
+			"aggregator": map[string]interface{}{
+				"type":     "push",
+				"protocol": "graphite",
+				"address":  "tcp://localhost:2003",
+				"tags":     map[string]interface{}{"env": "test"},
+			},
+		}
+		var conf struct {
+			Aggregator core.Aggregator
+		}
+		err := config.DecodeAndValidate(input, &conf)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conf.Aggregator).NotTo(BeNil())
+	})
+
+	It("buckets aggregator", func() {
+		input := map[string]interface{}{
+			"aggregator": map[string]interface{}{
+				"type":   "buckets",
+				"sink":   "stdout",
+				"format": "csv",
+			},
+		}
+		var conf struct {
+			Aggregator core.Aggregator
+		}
+		err := config.DecodeAndValidate(input, &conf)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(conf.Aggregator).NotTo(BeNil())
+	})
 })
 
 func TestSink(t *testing.T) {
@@ -98,6 +250,32 @@ func TestSink(t *testing.T) {
 	}
 }
 
+// TestSinkAndSourceHooksSeparated checks that sink and source string hooks don't resolve each
+// other special strings: "stdin" sink, and "stdout" source are files.
+func TestSinkAndSourceHooksSeparated(t *testing.T) {
+	defer resetGlobals()
+	fs := afero.NewMemMapFs()
+	Import(fs)
+
+	var conf struct {
+		Stdout func() core.DataSink
+		Sink   core.DataSink
+		Stdin  func() core.DataSource
+		Source core.DataSource
+	}
+	err := config.Decode(testConfig(
+		"stdout", "stdout",
+		"sink", "stdin",
+		"stdin", "stdin",
+		"source", "stdout",
+	), &conf)
+	require.NoError(t, err)
+	coretest.AssertSinkEqualStdStream(t, &os.Stdout, conf.Stdout)
+	coretest.AssertSinkEqualFile(t, fs, "stdin", conf.Sink)
+	coretest.AssertSourceEqualStdStream(t, &os.Stdin, conf.Stdin)
+	coretest.AssertSourceEqualFile(t, fs, "stdout", conf.Source)
+}
+
 func TestProviderJSONLine(t *testing.T) {
 	testutil.ReplaceGlobalLogger()
 	defer resetGlobals()
@@ -126,7 +304,71 @@ func TestProviderJSONLine(t *testing.T) {
 	testutil.AssertFileEqual(t, fs, filename, "[0,1,2]\n")
 }
 
-// TODO(skipor): test datasources
+func TestSource(t *testing.T) {
+	defer resetGlobals()
+	fs := afero.NewMemMapFs()
+	const filename = "/xxx"
+	Import(fs)
+
+	tests := []struct {
+		name  string
+		input map[string]interface{}
+	}{
+		{"hooked", testConfig(
+			"stdin", "stdin",
+			"file", filename,
+		)},
+		{"explicit", testConfig(
+			"stdin", testConfig("type", "stdin"),
+			"file", testConfig(
+				"type", "file",
+				"path", filename,
+			),
+		)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var conf struct {
+				Stdin func() core.DataSource
+				File  core.DataSource
+			}
+			err := config.Decode(test.input, &conf)
+			require.NoError(t, err)
+			coretest.AssertSourceEqualStdStream(t, &os.Stdin, conf.Stdin)
+			coretest.AssertSourceEqualFile(t, fs, filename, conf.File)
+		})
+	}
+}
+
+func TestParseScheduleDSL(t *testing.T) {
+	segments, err := parseScheduleDSL(" line(1,100,5m)  const( 100 , 90 )step(100,200,10,1m30s) ")
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]interface{}{
+		{"type": "line", "from": 1.0, "to": 100.0, "duration": 5 * time.Minute},
+		{"type": "const", "ops": 100.0, "duration": 90 * time.Second},
+		{"type": "step", "from": 100.0, "to": 200.0, "step": int64(10), "duration": 90 * time.Second},
+	}, segments)
+
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{"", "schedule string is empty"},
+		{"line(1,2,3s) const(1,2s", `schedule segment 2 "const(1,2s": expected name(arguments)`},
+		{"ramp(1,2,3s)", `schedule segment 1 "ramp(1,2,3s)": unknown schedule "ramp"`},
+		{"once()", `schedule segment 1 "once()": times argument "" is not an integer`},
+		{"const(x,1s)", `schedule segment 1 "const(x,1s)": ops argument "x" is not a number`},
+		{"step(1,2,0.5,1s)", `schedule segment 1 "step(1,2,0.5,1s)": step argument "0.5" is not an integer`},
+		{"const(1,1y)", `schedule segment 1 "const(1,1y)": duration argument "1y" is not a duration`},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			_, err := parseScheduleDSL(test.input)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.err)
+		})
+	}
+}
 
 func testConfig(keyValuePairs ...interface{}) map[string]interface{} {
 	if len(keyValuePairs)%2 != 0 {
@@ -0,0 +1,153 @@
+package coreimport
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/yandex/pandora/core/plugin/pluginconfig"
+	"github.com/yandex/pandora/lib/tag"
+	"go.uber.org/zap"
+)
+
+// scheduleStringToCompositeConfigHook helps to decode schedule DSL string as composite core.Schedule plugin.
+// DSL string is sequence of Yandex.Tank like segments, for example: "line(1,100,5m) const(100,10m)".
+// Segments are line(from,to,duration), const(ops,duration), step(from,to,step,duration),
+// once(times) and instance_step(from,to,step,stepduration). Duration is Go duration or number of seconds.
+func scheduleStringToCompositeConfigHook(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+	if f.Kind() != reflect.String {
+		return data, nil
+	}
+	if t.Kind() != reflect.Interface && t.Kind() != reflect.Func {
+		return data, nil
+	}
+	if !isPluginOrFactory(scheduleType, t) {
+		return data, nil
+	}
+	if tag.Debug {
+		zap.L().Debug("Schedule string hook triggered")
+	}
+	nested, err := parseScheduleDSL(data.(string))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		pluginconfig.PluginNameKey: compositeScheduleKey,
+		"nested":                   nested,
+	}, nil
+}
+
+type scheduleDSLParamKind int
+
+const (
+	scheduleDSLNumber scheduleDSLParamKind = iota
+	scheduleDSLInteger
+	scheduleDSLDuration
+)
+
+func (k scheduleDSLParamKind) String() string {
+	switch k {
+	case scheduleDSLInteger:
+		return "an integer"
+	case scheduleDSLDuration:
+		return "a duration"
+	default:
+		return "a number"
+	}
+}
+
+type scheduleDSLParam struct {
+	key  string
+	kind scheduleDSLParamKind
+}
+
+var scheduleDSLSegments = map[string][]scheduleDSLParam{
+	"line": {
+		{"from", scheduleDSLNumber}, {"to", scheduleDSLNumber}, {"duration", scheduleDSLDuration},
+	},
+	"const": {
+		{"ops", scheduleDSLNumber}, {"duration", scheduleDSLDuration},
+	},
+	"step": {
+		{"from", scheduleDSLNumber}, {"to", scheduleDSLNumber}, {"step", scheduleDSLInteger}, {"duration", scheduleDSLDuration},
+	},
+	"once": {
+		{"times", scheduleDSLInteger},
+	},
+	"instance_step": {
+		{"from", scheduleDSLInteger}, {"to", scheduleDSLInteger}, {"step", scheduleDSLInteger}, {"stepduration", scheduleDSLDuration},
+	},
+}
+
+// parseScheduleDSL returns configs of schedule DSL segments.
+func parseScheduleDSL(str string) ([]map[string]interface{}, error) {
+	var segments []map[string]interface{}
+	rest := strings.TrimSpace(str)
+	for rest != "" {
+		segment := rest
+		if end := strings.IndexByte(rest, ')'); end >= 0 {
+			segment = rest[:end+1]
+		}
+		rest = strings.TrimSpace(rest[len(segment):])
+		conf, err := parseScheduleDSLSegment(segment)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "schedule segment %d %q", len(segments)+1, segment)
+		}
+		segments = append(segments, conf)
+	}
+	if len(segments) == 0 {
+		return nil, errors.New("schedule string is empty")
+	}
+	return segments, nil
+}
+
+func parseScheduleDSLSegment(segment string) (map[string]interface{}, error) {
+	open := strings.IndexByte(segment, '(')
+	if open < 0 || !strings.HasSuffix(segment, ")") {
+		return nil, errors.New("expected name(arguments)")
+	}
+	name := strings.TrimSpace(segment[:open])
+	params, ok := scheduleDSLSegments[name]
+	if !ok {
+		return nil, errors.Errorf("unknown schedule %q: expected line, const, step, once or instance_step", name)
+	}
+	args := strings.Split(segment[open+1:len(segment)-1], ",")
+	if len(args) != len(params) {
+		keys := make([]string, len(params))
+		for i, param := range params {
+			keys[i] = param.key
+		}
+		return nil, errors.Errorf("%s expects %d arguments (%s), got %d",
+			name, len(params), strings.Join(keys, ", "), len(args))
+	}
+	conf := map[string]interface{}{pluginconfig.PluginNameKey: name}
+	for i, param := range params {
+		arg := strings.TrimSpace(args[i])
+		value, ok := parseScheduleDSLArg(arg, param.kind)
+		if !ok {
+			return nil, errors.Errorf("%s argument %q is not %s", param.key, arg, param.kind)
+		}
+		conf[param.key] = value
+	}
+	return conf, nil
+}
+
+func parseScheduleDSLArg(arg string, kind scheduleDSLParamKind) (interface{}, bool) {
+	switch kind {
+	case scheduleDSLInteger:
+		value, err := strconv.ParseInt(arg, 10, 64)
+		return value, err == nil
+	case scheduleDSLDuration:
+		// Number without unit is seconds, like in Yandex.Tank.
+		if seconds, err := strconv.ParseFloat(arg, 64); err == nil {
+			return time.Duration(seconds * float64(time.Second)), true
+		}
+		value, err := time.ParseDuration(arg)
+		return value, err == nil
+	default:
+		value, err := strconv.ParseFloat(arg, 64)
+		return value, err == nil
+	}
+}
@@ -0,0 +1,47 @@
+package lifecycle
+
+import "github.com/yandex/pandora/core"
+
+// InstanceDeps are passed to Instance lifecycle hooks.
+type InstanceDeps struct {
+	core.GunDeps
+	// Provider is pool ammo provider. Ammo acquired in hook SHOULD be released.
+	Provider core.Provider
+	// Aggregator is pool aggregator, that Gun is bound to.
+	Aggregator core.Aggregator
+}
+
+// InstanceStarter is optional core.Gun interface. OnInstanceStart is called once, after Gun Bind
+// and before first Shoot. For example, it can log in virtual user, and save session in deps State.
+// Error fails Instance, and Gun is not shoot.
+type InstanceStarter interface {
+	OnInstanceStart(deps InstanceDeps) error
+}
+
+// InstanceStopper is optional core.Gun interface. OnInstanceStop is called once, after last Shoot,
+// if OnInstanceStart has not failed. For example, it can log out virtual user.
+// Deps Ctx MAY be already canceled, if shooting is canceled.
+// Error is logged, and doesn't fail Instance.
+type InstanceStopper interface {
+	OnInstanceStop(deps InstanceDeps) error
+}
+
+// GunHooks implements InstanceStarter and InstanceStopper by calling hooks of Gun, if it implements
+// them. Gun wrappers embed GunHooks, so hooks of wrapped gun are called.
+type GunHooks struct {
+	Gun interface{}
+}
+
+func (h GunHooks) OnInstanceStart(deps InstanceDeps) error {
+	if starter, ok := h.Gun.(InstanceStarter); ok {
+		return starter.OnInstanceStart(deps)
+	}
+	return nil
+}
+
+func (h GunHooks) OnInstanceStop(deps InstanceDeps) error {
+	if stopper, ok := h.Gun.(InstanceStopper); ok {
+		return stopper.OnInstanceStop(deps)
+	}
+	return nil
+}
@@ -50,6 +50,16 @@ func NewFactory(factoryType reflect.Type, name string, fillConfOptional ...func(
 	return defaultRegistry.NewFactory(factoryType, name, fillConfOptional...)
 }
 
+// Plugins is DefaultRegistry().Plugins shortcut.
+func Plugins() []Info {
+	return defaultRegistry.Plugins()
+}
+
+// DefaultConfig is DefaultRegistry().DefaultConfig shortcut.
+func DefaultConfig(pluginType reflect.Type, name string) (conf interface{}, err error) {
+	return defaultRegistry.DefaultConfig(pluginType, name)
+}
+
 // PtrType is helper to extract plugin types.
 // Example: plugin.PtrType((*PluginInterface)(nil)) instead of
 // reflect.TypeOf((*PluginInterface)(nil)).Elem()
@@ -7,6 +7,7 @@ package plugin
 
 import (
 	"reflect"
+	"sort"
 
 	"github.com/pkg/errors"
 )
@@ -127,6 +128,44 @@ func (r *Registry) NewFactory(factoryType reflect.Type, name string, fillConfOpt
 	return registered.constructor.NewFactory(factoryType, getMaybeConfig)
 }
 
+// Info describes registered plugin.
+type Info struct {
+	Type reflect.Type
+	Name string
+}
+
+// Plugins returns all registered plugins sorted by type and name.
+func (r *Registry) Plugins() []Info {
+	var plugins []Info
+	for pluginType, nameReg := range r.typeToNameReg {
+		for name := range nameReg {
+			plugins = append(plugins, Info{pluginType, name})
+		}
+	}
+	sort.Slice(plugins, func(i, j int) bool {
+		ti, tj := plugins[i].Type.String(), plugins[j].Type.String()
+		if ti != tj {
+			return ti < tj
+		}
+		return plugins[i].Name < plugins[j].Name
+	})
+	return plugins
+}
+
+// DefaultConfig returns default config, that registered constructor receives before filling.
+// Returns nil, if constructor accepts no config.
+func (r *Registry) DefaultConfig(pluginType reflect.Type, name string) (conf interface{}, err error) {
+	registered, err := r.get(pluginType, name)
+	if err != nil {
+		return
+	}
+	if !registered.defaultConfig.configRequired() {
+		return nil, nil
+	}
+	maybeConf, _ := registered.defaultConfig.new()
+	return maybeConf[0].Interface(), nil
+}
+
 func newNameRegistryEntry(pluginType reflect.Type, constructor interface{}, defaultConfig interface{}) nameRegistryEntry {
 	implConstructor := newImplConstructor(pluginType, constructor)
 	defaultConfigContainer := newDefaultConfigContainer(reflect.TypeOf(constructor), defaultConfig)
@@ -91,6 +91,37 @@ var _ = Describe("registry", func() {
 		Expect(r.LookupFactory(reflect.TypeOf((*io.Writer)(nil)).Elem())).To(BeFalse())
 	})
 
+	It("plugins", func() {
+		r := NewRegistry()
+		r.Register(ptestType(), "b", ptestNewImpl)
+		r.Register(ptestType(), "a", ptestNewImpl)
+		r.Register(reflect.TypeOf((*io.Reader)(nil)).Elem(), "c", func() io.Reader { return nil })
+		Expect(r.Plugins()).To(Equal([]Info{
+			{reflect.TypeOf((*io.Reader)(nil)).Elem(), "c"},
+			{ptestType(), "a"},
+			{ptestType(), "b"},
+		}))
+	})
+
+	It("default config", func() {
+		r := NewRegistry()
+		r.Register(ptestType(), "default", ptestNewConf, ptestDefaultConf)
+		r.Register(ptestType(), "zero", ptestNewPtrConf)
+		r.Register(ptestType(), "no config", ptestNewImpl)
+
+		conf, err := r.DefaultConfig(ptestType(), "default")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conf).To(Equal(ptestConfig{ptestDefaultValue}))
+		conf, err = r.DefaultConfig(ptestType(), "zero")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conf).To(Equal(&ptestConfig{}))
+		conf, err = r.DefaultConfig(ptestType(), "no config")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conf).To(BeNil())
+		_, err = r.DefaultConfig(ptestType(), "not registered")
+		Expect(err).To(HaveOccurred())
+	})
+
 })
 
 var _ = Describe("new", func() {
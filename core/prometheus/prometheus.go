@@ -0,0 +1,273 @@
+// Package prometheus exposes engine metrics and live stats of reported samples
+// in Prometheus text exposition format.
+package prometheus
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yandex/pandora/core"
+	"github.com/yandex/pandora/core/aggregator/netsample"
+	"github.com/yandex/pandora/core/coreutil"
+	"github.com/yandex/pandora/core/engine"
+	"go.uber.org/atomic"
+)
+
+const (
+	namespace = "pandora"
+	emptyTag  = "__EMPTY__"
+)
+
+// DefaultRTTBuckets are upper bounds of RTT histogram buckets in seconds.
+var DefaultRTTBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Sample is subset of netsample.Sample methods, that is required for collecting stats.
+type Sample interface {
+	Tags() string
+	ProtoCode() int
+	Errno() int
+	RTT() time.Duration
+}
+
+type CollectorConfig struct {
+	// RTTBuckets are upper bounds of RTT histogram buckets in seconds.
+	RTTBuckets []float64 `config:"rtt-buckets" validate:"required,dive,gt=0"`
+	// MaxTags limits number of tags with separate series. Samples of other tags
+	// are accounted with "other" tag. See coreutil.TagLimiter.
+	MaxTags int `config:"max-tags" validate:"min=1"`
+}
+
+func DefaultCollectorConfig() CollectorConfig {
+	return CollectorConfig{
+		RTTBuckets: DefaultRTTBuckets,
+		MaxTags:    100,
+	}
+}
+
+// SampleCollector collects RTT histograms by tag and proto code, and net errors by errno.
+// Should be added to engine as sample observer.
+// Sample observation is lock free, except for tags seen while tag limit is not reached.
+type SampleCollector struct {
+	conf CollectorConfig
+
+	rtt       sync.Map // rttKey -> *rttHistogram
+	netErrors sync.Map // Errno -> *atomic.Int64
+
+	knownTags sync.Map // Tags accepted by limiter.
+	tagsFull  atomic.Bool
+	mu        sync.Mutex // Guards tags.
+	tags      *coreutil.TagLimiter
+}
+
+type rttKey struct {
+	tag  string
+	code int
+}
+
+type rttHistogram struct {
+	// buckets has extra last bucket for values above all bounds.
+	// Cumulative counts and total count are calculated on write, so they are consistent.
+	buckets []atomic.Int64
+	sum     atomic.Float64 // Seconds.
+}
+
+func NewSampleCollector(conf CollectorConfig) *SampleCollector {
+	buckets := append([]float64(nil), conf.RTTBuckets...)
+	sort.Float64s(buckets)
+	conf.RTTBuckets = buckets
+	return &SampleCollector{
+		conf: conf,
+		tags: coreutil.NewTagLimiter(conf.MaxTags),
+	}
+}
+
+func (c *SampleCollector) ObserveSample(sample core.Sample) {
+	s, ok := sample.(Sample)
+	if !ok {
+		return
+	}
+	errno := s.Errno()
+	if errno != 0 {
+		counter, ok := c.netErrors.Load(errno)
+		if !ok {
+			counter, _ = c.netErrors.LoadOrStore(errno, &atomic.Int64{})
+		}
+		counter.(*atomic.Int64).Inc()
+	}
+	if errno == netsample.DiscardedShootCodeError {
+		// Shoot has not been done, so there is no RTT.
+		return
+	}
+	key := rttKey{tag: c.tag(s.Tags()), code: s.ProtoCode()}
+	h, ok := c.rtt.Load(key)
+	if !ok {
+		h, _ = c.rtt.LoadOrStore(key, &rttHistogram{buckets: make([]atomic.Int64, len(c.conf.RTTBuckets)+1)})
+	}
+	rtt := s.RTT().Seconds()
+	h.(*rttHistogram).buckets[sort.SearchFloat64s(c.conf.RTTBuckets, rtt)].Inc()
+	h.(*rttHistogram).sum.Add(rtt)
+}
+
+func (c *SampleCollector) tag(tag string) string {
+	if tag == emptyTag {
+		tag = ""
+	}
+	if _, ok := c.knownTags.Load(tag); ok {
+		return tag
+	}
+	if c.tagsFull.Load() {
+		return coreutil.OtherTag
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	limited := c.tags.Tag(tag)
+	if limited == tag {
+		c.knownTags.Store(tag, struct{}{})
+	} else {
+		c.tagsFull.Store(true)
+	}
+	return limited
+}
+
+func (c *SampleCollector) write(w *writer) {
+	var keys []rttKey
+	c.rtt.Range(func(k, _ interface{}) bool {
+		keys = append(keys, k.(rttKey))
+		return true
+	})
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].tag != keys[j].tag {
+			return keys[i].tag < keys[j].tag
+		}
+		return keys[i].code < keys[j].code
+	})
+	w.header("sample_rtt_seconds", "histogram", "Round trip time of shoots by sample tag and proto code.")
+	for _, k := range keys {
+		h, _ := c.rtt.Load(k)
+		buckets := h.(*rttHistogram).buckets
+		labels := []string{"tag", k.tag, "code", strconv.Itoa(k.code)}
+		var cumulative int64
+		for i, bound := range c.conf.RTTBuckets {
+			cumulative += buckets[i].Load()
+			w.sample("sample_rtt_seconds_bucket", cumulative, append(labels, "le", formatFloat(bound))...)
+		}
+		cumulative += buckets[len(c.conf.RTTBuckets)].Load()
+		w.sample("sample_rtt_seconds_bucket", cumulative, append(labels, "le", "+Inf")...)
+		w.sample("sample_rtt_seconds_sum", h.(*rttHistogram).sum.Load(), labels...)
+		w.sample("sample_rtt_seconds_count", cumulative, labels...)
+	}
+
+	var errnos []int
+	c.netErrors.Range(func(k, _ interface{}) bool {
+		errnos = append(errnos, k.(int))
+		return true
+	})
+	sort.Ints(errnos)
+	w.header("sample_net_errors_total", "counter", "Samples with net errors by errno.")
+	for _, errno := range errnos {
+		counter, _ := c.netErrors.Load(errno)
+		w.sample("sample_net_errors_total", counter.(*atomic.Int64).Load(), "errno", strconv.Itoa(errno))
+	}
+}
+
+// NewHandler returns handler, that writes engine metrics, pools status and collected samples
+// stats in Prometheus text format. Samples collector is optional.
+func NewHandler(m engine.Metrics, pools func() []engine.PoolStatus, samples *SampleCollector) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w := &writer{w: rw}
+		writeEngineMetrics(w, m)
+		writePools(w, pools())
+		if samples != nil {
+			samples.write(w)
+		}
+	})
+}
+
+func writeEngineMetrics(w *writer, m engine.Metrics) {
+	requests, responses := m.Request.Get(), m.Response.Get()
+	started, finished := m.InstanceStart.Get(), m.InstanceFinish.Get()
+	w.header("engine_requests_total", "counter", "Shoots started by all pools.")
+	w.sample("engine_requests_total", requests)
+	w.header("engine_responses_total", "counter", "Shoots finished by all pools.")
+	w.sample("engine_responses_total", responses)
+	w.header("engine_active_requests", "gauge", "Shoots in progress.")
+	w.sample("engine_active_requests", requests-responses)
+	w.header("engine_instances_started_total", "counter", "Instances started by all pools.")
+	w.sample("engine_instances_started_total", started)
+	w.header("engine_instances_finished_total", "counter", "Instances finished by all pools.")
+	w.sample("engine_instances_finished_total", finished)
+}
+
+func writePools(w *writer, pools []engine.PoolStatus) {
+	w.header("pool_active_instances", "gauge", "Running instances of pool.")
+	for _, p := range pools {
+		w.sample("pool_active_instances", p.ActiveInstances, "pool", p.ID)
+	}
+	w.header("pool_shots_total", "counter", "Shoots done by pool.")
+	for _, p := range pools {
+		w.sample("pool_shots_total", p.Shots, "pool", p.ID)
+	}
+	w.header("pool_paused", "gauge", "1 if pool is paused by control API.")
+	for _, p := range pools {
+		var paused int64
+		if p.Paused {
+			paused = 1
+		}
+		w.sample("pool_paused", paused, "pool", p.ID)
+	}
+}
+
+// writer writes metrics in Prometheus text format. Write errors are ignored, because
+// there is nothing to do with them, when response is already started.
+type writer struct {
+	w io.Writer
+}
+
+func (w *writer) header(name, typ, help string) {
+	fmt.Fprintf(w.w, "# HELP %s_%s %s\n# TYPE %s_%s %s\n", namespace, name, help, namespace, name, typ)
+}
+
+// sample writes metric sample. Value should be int64 or float64. Labels are name, value pairs.
+func (w *writer) sample(name string, value interface{}, labels ...string) {
+	var b strings.Builder
+	b.WriteString(namespace)
+	b.WriteByte('_')
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i := 0; i < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(labels[i])
+			b.WriteString(`="`)
+			b.WriteString(labelEscaper.Replace(labels[i+1]))
+			b.WriteByte('"')
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	switch v := value.(type) {
+	case int64:
+		b.WriteString(strconv.FormatInt(v, 10))
+	case float64:
+		b.WriteString(formatFloat(v))
+	default:
+		panic(fmt.Sprintf("unexpected metric value type %T", value))
+	}
+	b.WriteByte('\n')
+	_, _ = io.WriteString(w.w, b.String())
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
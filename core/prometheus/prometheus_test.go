@@ -0,0 +1,139 @@
+package prometheus
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yandex/pandora/core/aggregator/netsample"
+	"github.com/yandex/pandora/core/config"
+	"github.com/yandex/pandora/core/engine"
+	"github.com/yandex/pandora/lib/monitoring"
+)
+
+type testSample struct {
+	tag       string
+	protoCode int
+	errno     int
+	rtt       time.Duration
+}
+
+func (s testSample) Tags() string       { return s.tag }
+func (s testSample) ProtoCode() int     { return s.protoCode }
+func (s testSample) Errno() int         { return s.errno }
+func (s testSample) RTT() time.Duration { return s.rtt }
+
+func TestHandler(t *testing.T) {
+	m := engine.Metrics{
+		Request:        &monitoring.Counter{},
+		Response:       &monitoring.Counter{},
+		InstanceStart:  &monitoring.Counter{},
+		InstanceFinish: &monitoring.Counter{},
+	}
+	m.Request.Add(10)
+	m.Response.Add(8)
+	pools := func() []engine.PoolStatus {
+		return []engine.PoolStatus{{ID: "a", ActiveInstances: 3, Shots: 8, Paused: true}}
+	}
+	conf := DefaultCollectorConfig()
+	conf.RTTBuckets = []float64{0.1, 0.01}
+	conf.MaxTags = 1
+	samples := NewSampleCollector(conf)
+	samples.ObserveSample(testSample{tag: "__EMPTY__", protoCode: 200, rtt: 5 * time.Millisecond})
+	samples.ObserveSample(testSample{protoCode: 200, rtt: 50 * time.Millisecond})
+	samples.ObserveSample(testSample{tag: `x"y`, protoCode: 999, errno: 110, rtt: time.Second})
+	samples.ObserveSample(testSample{errno: netsample.DiscardedShootCodeError})
+	samples.ObserveSample("not a sample")
+
+	rec := httptest.NewRecorder()
+	NewHandler(m, pools, samples).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	out := rec.Body.String()
+	expected := []string{
+		"# TYPE pandora_engine_requests_total counter\npandora_engine_requests_total 10\n",
+		"pandora_engine_active_requests 2\n",
+		`pandora_pool_active_instances{pool="a"} 3` + "\n",
+		`pandora_pool_paused{pool="a"} 1` + "\n",
+		"# TYPE pandora_sample_rtt_seconds histogram\n",
+		`pandora_sample_rtt_seconds_bucket{tag="",code="200",le="0.01"} 1` + "\n" +
+			`pandora_sample_rtt_seconds_bucket{tag="",code="200",le="0.1"} 2` + "\n" +
+			`pandora_sample_rtt_seconds_bucket{tag="",code="200",le="+Inf"} 2` + "\n" +
+			`pandora_sample_rtt_seconds_sum{tag="",code="200"} 0.055` + "\n" +
+			`pandora_sample_rtt_seconds_count{tag="",code="200"} 2` + "\n",
+		`pandora_sample_rtt_seconds_bucket{tag="other",code="999",le="+Inf"} 1` + "\n",
+		`pandora_sample_net_errors_total{errno="110"} 1` + "\n" +
+			`pandora_sample_net_errors_total{errno="777"} 1` + "\n",
+	}
+	for _, e := range expected {
+		assert.Contains(t, out, e)
+	}
+	assert.NotContains(t, out, `code="0"`)
+}
+
+func TestSampleCollector_ConcurrentObserve(t *testing.T) {
+	conf := DefaultCollectorConfig()
+	conf.RTTBuckets = []float64{0.01}
+	conf.MaxTags = 2
+	samples := NewSampleCollector(conf)
+	const goroutines, perGoroutine = 8, 1000
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perGoroutine; j++ {
+				samples.ObserveSample(testSample{tag: strconv.Itoa(j % 4), protoCode: 200, rtt: time.Millisecond})
+				samples.ObserveSample(testSample{tag: "0", errno: 110, rtt: time.Second})
+			}
+		}()
+	}
+	wg.Wait()
+
+	rec := httptest.NewRecorder()
+	w := &writer{w: rec}
+	samples.write(w)
+	out := rec.Body.String()
+	var total int64
+	for _, tag := range []string{"0", "1", "2", "3", "other"} {
+		if !strings.Contains(out, `tag="`+tag+`",code="200"`) {
+			continue
+		}
+		total += countFor(t, out, tag, 200)
+	}
+	assert.EqualValues(t, goroutines*perGoroutine, total)
+	assert.EqualValues(t, goroutines*perGoroutine, countFor(t, out, "0", 0))
+	assert.Contains(t, out, `pandora_sample_net_errors_total{errno="110"} `+strconv.Itoa(goroutines*perGoroutine)+"\n")
+	assert.Contains(t, out, `tag="other"`)
+}
+
+func countFor(t *testing.T, out, tag string, code int) int64 {
+	prefix := `pandora_sample_rtt_seconds_count{tag="` + tag + `",code="` + strconv.Itoa(code) + `"} `
+	i := strings.Index(out, prefix)
+	require.True(t, i >= 0, prefix)
+	line := out[i+len(prefix):]
+	n, err := strconv.ParseInt(line[:strings.IndexByte(line, '\n')], 10, 64)
+	require.NoError(t, err)
+	return n
+}
+
+func TestEscape(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := &writer{w: rec}
+	w.sample("x", 1.5, "tag", "a\"b\\c\nd")
+	assert.Equal(t, `pandora_x{tag="a\"b\\c\nd"} 1.5`+"\n", rec.Body.String())
+}
+
+func TestCollectorConfigValidation(t *testing.T) {
+	conf := DefaultCollectorConfig()
+	err := config.DecodeAndValidate(map[string]interface{}{"rtt-buckets": []float64{0.1, 1}}, &conf)
+	require.NoError(t, err)
+	err = config.DecodeAndValidate(map[string]interface{}{"rtt-buckets": []float64{0}}, &conf)
+	assert.Error(t, err)
+	conf = DefaultCollectorConfig()
+	err = config.DecodeAndValidate(map[string]interface{}{"max-tags": 0}, &conf)
+	assert.Error(t, err, "all samples would be accounted under other tag")
+}
@@ -0,0 +1,148 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+type CompareConfig struct {
+	// LatencyTolerance is allowed increase of RTT quantile in percents.
+	LatencyTolerance float64
+	// LatencySlack is allowed absolute increase of RTT quantile. It makes comparison of
+	// small latencies less noisy: increase is regression, only if both tolerance and slack are exceeded.
+	LatencySlack time.Duration
+	// RPSTolerance is allowed decrease of RPS in percents.
+	RPSTolerance float64
+	// ErrorsTolerance is allowed increase of errors share in percentage points.
+	ErrorsTolerance float64
+}
+
+func DefaultCompareConfig() CompareConfig {
+	return CompareConfig{
+		LatencyTolerance: 10,
+		LatencySlack:     time.Millisecond,
+		RPSTolerance:     10,
+		ErrorsTolerance:  1,
+	}
+}
+
+// Diff is comparison result of one metric of one tag.
+type Diff struct {
+	Tag    string
+	Metric string
+	// Baseline and Current values are RPS, errors share in percents, or RTT quantile in microseconds.
+	Baseline   float64
+	Current    float64
+	Regression bool
+	// Missing means, that tag is present in baseline, but there is no its samples in current run.
+	Missing bool
+}
+
+// Compare compares total and per tag stats of current run with baseline.
+// Reports should be built with the same quantiles.
+func Compare(baseline, current *Report, conf CompareConfig) []Diff {
+	currentTags := map[string]TagStats{}
+	for _, t := range current.Tags {
+		currentTags[t.Tag] = t
+	}
+	diffs := compareTag(baseline.Total, current.Total, baseline.Quantiles, conf)
+	for _, base := range baseline.Tags {
+		cur, ok := currentTags[base.Tag]
+		if !ok {
+			diffs = append(diffs, Diff{Tag: base.Tag, Metric: "count", Baseline: float64(base.Count), Regression: true, Missing: true})
+			continue
+		}
+		diffs = append(diffs, compareTag(base, cur, baseline.Quantiles, conf)...)
+	}
+	return diffs
+}
+
+func compareTag(base, cur TagStats, quantiles []float64, conf CompareConfig) []Diff {
+	diffs := []Diff{{
+		Tag:        base.Tag,
+		Metric:     "rps",
+		Baseline:   base.RPS,
+		Current:    cur.RPS,
+		Regression: cur.RPS < base.RPS*(1-conf.RPSTolerance/100),
+	}}
+	baseErrors, curErrors := errorsShare(base), errorsShare(cur)
+	diffs = append(diffs, Diff{
+		Tag:        base.Tag,
+		Metric:     "errors",
+		Baseline:   baseErrors,
+		Current:    curErrors,
+		Regression: curErrors-baseErrors > conf.ErrorsTolerance,
+	})
+	for i, q := range quantiles {
+		baseRTT, curRTT := float64(base.Quantiles[i]), float64(cur.Quantiles[i])
+		increase := curRTT - baseRTT
+		diffs = append(diffs, Diff{
+			Tag:      base.Tag,
+			Metric:   "q" + formatQuantile(q),
+			Baseline: baseRTT,
+			Current:  curRTT,
+			Regression: increase > baseRTT*conf.LatencyTolerance/100 &&
+				increase > float64(conf.LatencySlack.Microseconds()),
+		})
+	}
+	return diffs
+}
+
+func errorsShare(s TagStats) float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return float64(s.Errors) * 100 / float64(s.Count)
+}
+
+// HasRegression returns true, if any of diffs is regression.
+func HasRegression(diffs []Diff) bool {
+	for _, d := range diffs {
+		if d.Regression {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteDiffTable writes diffs as human readable table.
+func WriteDiffTable(w io.Writer, diffs []Diff) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "tag\tmetric\tbaseline\tcurrent\tchange\tstatus")
+	for _, d := range diffs {
+		status := "ok"
+		if d.Regression {
+			status = "REGRESSION"
+		}
+		if d.Missing {
+			fmt.Fprintf(tw, "%s\t%s\t%.0f\t-\t-\tMISSING\n", d.Tag, d.Metric, d.Baseline)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", d.Tag, d.Metric,
+			formatDiffValue(d.Metric, d.Baseline), formatDiffValue(d.Metric, d.Current), formatChange(d), status)
+	}
+	return tw.Flush()
+}
+
+func formatDiffValue(metric string, v float64) string {
+	switch metric {
+	case "rps":
+		return strconv.FormatFloat(v, 'f', 1, 64)
+	case "errors":
+		return strconv.FormatFloat(v, 'f', 2, 64) + "%"
+	}
+	return formatMillis(v)
+}
+
+func formatChange(d Diff) string {
+	if d.Metric == "errors" {
+		return fmt.Sprintf("%+.2fpp", d.Current-d.Baseline)
+	}
+	if d.Baseline == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%+.1f%%", (d.Current-d.Baseline)*100/d.Baseline)
+}
@@ -0,0 +1,58 @@
+package report
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func buildTestReport(rtt time.Duration, failed int, tags ...string) *Report {
+	conf := DefaultConfig()
+	conf.Quantiles = []float64{50, 99}
+	b := NewBuilder(conf)
+	for _, tag := range tags {
+		for i := 0; i < 100; i++ {
+			code := 200
+			if i < failed {
+				code = 500
+			}
+			b.Add(newTestSample(tag, time.Duration(i)*10*time.Millisecond, rtt, code, 0))
+		}
+	}
+	return b.Build("")
+}
+
+func TestCompare(t *testing.T) {
+	conf := DefaultCompareConfig()
+	baseline := buildTestReport(10*time.Millisecond, 0, "a", "b")
+
+	diffs := Compare(baseline, buildTestReport(10500*time.Microsecond, 1, "a", "b"), conf)
+	assert.False(t, HasRegression(diffs))
+	require.Len(t, diffs, 3*4)
+	assert.Equal(t, Diff{Tag: "total", Metric: "errors", Baseline: 0, Current: 1}, diffs[1])
+
+	diffs = Compare(baseline, buildTestReport(20*time.Millisecond, 0, "a", "b"), conf)
+	assert.True(t, HasRegression(diffs))
+	assert.True(t, diffs[2].Regression, "q50 should regress")
+	assert.False(t, diffs[0].Regression, "rps is the same")
+
+	conf.LatencySlack = 20 * time.Millisecond
+	diffs = Compare(baseline, buildTestReport(20*time.Millisecond, 0, "a", "b"), conf)
+	assert.False(t, HasRegression(diffs), "latency increase is less than slack")
+
+	diffs = Compare(baseline, buildTestReport(10*time.Millisecond, 5, "a", "b"), conf)
+	assert.True(t, diffs[1].Regression, "errors should regress")
+
+	diffs = Compare(baseline, buildTestReport(10*time.Millisecond, 0, "a", "c"), conf)
+	assert.True(t, HasRegression(diffs))
+	missing := diffs[len(diffs)-1]
+	assert.Equal(t, Diff{Tag: "b", Metric: "count", Baseline: 100, Regression: true, Missing: true}, missing)
+
+	var out bytes.Buffer
+	require.NoError(t, WriteDiffTable(&out, diffs))
+	assert.Regexp(t, `b\s+count\s+100\s+-\s+-\s+MISSING`, out.String())
+	assert.Regexp(t, `total\s+q50\s+10\.000ms\s+10\.000ms\s+\+0\.0%\s+ok`, out.String())
+}
@@ -0,0 +1,231 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WriteHTML writes self-contained HTML report: there are no external scripts or styles,
+// charts are inline SVG.
+func WriteHTML(w io.Writer, r *Report) error {
+	return htmlTemplate.Execute(w, htmlView{
+		Report:       r,
+		RPSChart:     rpsChart(r),
+		LatencyChart: latencyChart(r),
+	})
+}
+
+type htmlView struct {
+	*Report
+	RPSChart     template.HTML
+	LatencyChart template.HTML
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"ms":       func(us interface{}) string { return formatMillis(toFloat(us)) },
+	"percent":  func(f float64) string { return strconv.FormatFloat(f, 'f', 2, 64) + "%" },
+	"share":    func(n, total int64) string { return formatShare(n, total) },
+	"float":    func(f float64) string { return strconv.FormatFloat(f, 'f', 1, 64) },
+	"quantile": formatQuantile,
+	"time":     func(t time.Time) string { return t.Format("2006-01-02 15:04:05 MST") },
+	"duration": func(d time.Duration) string { return d.Round(time.Millisecond).String() },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 24px; color: #222; }
+h1 { font-size: 22px; } h2 { font-size: 18px; margin-top: 32px; }
+table { border-collapse: collapse; font-size: 13px; }
+th, td { padding: 4px 10px; border-bottom: 1px solid #ddd; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+th { background: #f4f4f4; }
+.summary td { text-align: left; }
+svg { font-size: 11px; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<table class="summary">
+<tr><td>Start</td><td>{{time .Start}}</td></tr>
+<tr><td>Duration</td><td>{{duration .Duration}}</td></tr>
+<tr><td>Samples</td><td>{{.Total.Count}}</td></tr>
+<tr><td>Errors</td><td>{{.Total.Errors}} ({{share .Total.Errors .Total.Count}})</td></tr>
+<tr><td>Average RPS</td><td>{{float .Total.RPS}}</td></tr>
+</table>
+
+<h2>RPS</h2>
+{{.RPSChart}}
+<h2>Latency</h2>
+{{.LatencyChart}}
+
+<h2>Quantiles</h2>
+<table>
+<tr><th>tag</th><th>count</th><th>rps</th><th>errors</th><th>min</th><th>mean</th>{{range .Quantiles}}<th>q{{quantile .}}</th>{{end}}<th>max</th></tr>
+{{template "tag" .Total}}
+{{range .Tags}}{{template "tag" .}}{{end}}
+</table>
+
+<h2>HTTP codes</h2>
+{{template "codes" .ProtoCodes}}
+{{if .NetCodes}}
+<h2>Net codes</h2>
+{{template "codes" .NetCodes}}
+{{end}}
+</body>
+</html>
+{{define "tag"}}<tr><td>{{if .Tag}}{{.Tag}}{{else}}-{{end}}</td><td>{{.Count}}</td><td>{{float .RPS}}</td><td>{{share .Errors .Count}}</td><td>{{ms .Min}}</td><td>{{ms .Mean}}</td>{{range .Quantiles}}<td>{{ms .}}</td>{{end}}<td>{{ms .Max}}</td></tr>
+{{end}}
+{{define "codes"}}<table>
+<tr><th>code</th><th>count</th><th>share</th></tr>
+{{range .}}<tr><td>{{.Code}}</td><td>{{.Count}}</td><td>{{percent .Share}}</td></tr>
+{{end}}</table>{{end}}
+`))
+
+func toFloat(v interface{}) float64 {
+	switch v := v.(type) {
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	}
+	panic(fmt.Sprintf("unexpected type %T", v))
+}
+
+func formatMillis(us float64) string {
+	return strconv.FormatFloat(us/1000, 'f', 3, 64) + "ms"
+}
+
+func formatShare(n, total int64) string {
+	if total == 0 {
+		return "0.00%"
+	}
+	return strconv.FormatFloat(float64(n)*100/float64(total), 'f', 2, 64) + "%"
+}
+
+type chartSeries struct {
+	Name   string
+	Color  string
+	Dashed bool
+	Values []float64
+}
+
+func rpsChart(r *Report) template.HTML {
+	actual := chartSeries{Name: "responses/s", Color: "#1f77b4"}
+	errs := chartSeries{Name: "errors/s", Color: "#d62728"}
+	for _, p := range r.Timeline {
+		actual.Values = append(actual.Values, p.RPS)
+		errs.Values = append(errs.Values, p.ErrorsPS)
+	}
+	series := []chartSeries{actual, errs}
+	if len(r.Planned) > 0 {
+		series = append(series, chartSeries{Name: "planned", Color: "#7f7f7f", Dashed: true, Values: r.Planned})
+	}
+	return renderChart(r.Step, "", series)
+}
+
+func latencyChart(r *Report) template.HTML {
+	colors := []string{"#2ca02c", "#ff7f0e", "#9467bd"}
+	series := make([]chartSeries, len(TimelineQuantiles))
+	for i, q := range TimelineQuantiles {
+		series[i] = chartSeries{Name: "q" + formatQuantile(q), Color: colors[i%len(colors)]}
+		for _, p := range r.Timeline {
+			series[i].Values = append(series[i].Values, float64(p.Quantiles[i])/1000)
+		}
+	}
+	return renderChart(r.Step, "ms", series)
+}
+
+const (
+	chartWidth   = 960
+	chartHeight  = 280
+	chartLeft    = 60
+	chartRight   = 20
+	chartTop     = 24
+	chartBottom  = 24
+	chartYTicks  = 5
+	chartXLabels = 8
+)
+
+// renderChart renders line chart as SVG. Point i of every series is at i*step time offset.
+func renderChart(step time.Duration, unit string, series []chartSeries) template.HTML {
+	points, maxValue := 0, 0.0
+	for _, s := range series {
+		if len(s.Values) > points {
+			points = len(s.Values)
+		}
+		for _, v := range s.Values {
+			maxValue = math.Max(maxValue, v)
+		}
+	}
+	maxValue = niceCeil(maxValue)
+	plotWidth := float64(chartWidth - chartLeft - chartRight)
+	plotHeight := float64(chartHeight - chartTop - chartBottom)
+	x := func(i int) float64 {
+		if points <= 1 {
+			return chartLeft
+		}
+		return chartLeft + float64(i)*plotWidth/float64(points-1)
+	}
+	y := func(v float64) float64 {
+		return chartTop + plotHeight - v/maxValue*plotHeight
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">`, chartWidth, chartHeight)
+	for i := 0; i <= chartYTicks; i++ {
+		v := maxValue * float64(i) / chartYTicks
+		fmt.Fprintf(&b, `<line x1="%d" x2="%d" y1="%.1f" y2="%.1f" stroke="#e5e5e5"/>`,
+			chartLeft, chartWidth-chartRight, y(v), y(v))
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end">%s%s</text>`,
+			chartLeft-6, y(v)+4, strconv.FormatFloat(v, 'g', 4, 64), unit)
+	}
+	labelEvery := (points + chartXLabels - 1) / chartXLabels
+	if labelEvery == 0 {
+		labelEvery = 1
+	}
+	for i := 0; i < points; i += labelEvery {
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`,
+			x(i), chartHeight-6, time.Duration(i)*step)
+	}
+	legendX := chartLeft
+	for _, s := range series {
+		fmt.Fprintf(&b, `<text x="%d" y="14" fill="%s">&#9632; %s</text>`,
+			legendX, s.Color, template.HTMLEscapeString(s.Name))
+		legendX += 20 + 7*len(s.Name)
+		if len(s.Values) == 0 {
+			continue
+		}
+		dash := ""
+		if s.Dashed {
+			dash = ` stroke-dasharray="6 4"`
+		}
+		fmt.Fprintf(&b, `<polyline fill="none" stroke="%s" stroke-width="1.5"%s points="`, s.Color, dash)
+		for i, v := range s.Values {
+			fmt.Fprintf(&b, "%.1f,%.1f ", x(i), y(v))
+		}
+		b.WriteString(`"/>`)
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// niceCeil returns value greater or equal v, that is 1, 2 or 5 multiplied by power of 10.
+func niceCeil(v float64) float64 {
+	if v <= 0 {
+		return 1
+	}
+	pow := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if m*pow >= v {
+			return m * pow
+		}
+	}
+	return 10 * pow
+}
@@ -0,0 +1,51 @@
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/yandex/pandora/core/aggregator/netsample"
+)
+
+// maxLineSize limits line length of results file. Phout and JSON lines of samples are
+// much shorter, so longer line most likely means, that file has different format.
+const maxLineSize = 1024 * 1024
+
+// Read reads samples written by phout or jsonlines aggregator, and calls fn for each of them.
+// Format is detected by the first non-empty line: JSON line starts with '{', phout line with timestamp.
+func Read(r io.Reader, fn func(s *netsample.Sample)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	var parse func(line []byte) (*netsample.Sample, error)
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		if parse == nil {
+			parse = parsePhout
+			if data[0] == '{' {
+				parse = parseJSON
+			}
+		}
+		s, err := parse(data)
+		if err != nil {
+			return errors.WithMessagef(err, "line %v", line)
+		}
+		fn(s)
+	}
+	return scanner.Err()
+}
+
+func parsePhout(line []byte) (*netsample.Sample, error) {
+	return netsample.ParsePhout(string(line))
+}
+
+func parseJSON(line []byte) (*netsample.Sample, error) {
+	s := &netsample.Sample{}
+	err := json.Unmarshal(line, s)
+	return s, err
+}
@@ -0,0 +1,286 @@
+// Package report builds HTML report from samples written by phout or jsonlines aggregators.
+package report
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/yandex/pandora/core/aggregator/netsample"
+	"github.com/yandex/pandora/core/coreutil"
+	"github.com/yandex/pandora/lib/histogram"
+)
+
+const emptyTag = "__EMPTY__"
+
+// TimelineQuantiles are RTT quantiles in percents, that are shown on latency chart.
+var TimelineQuantiles = []float64{50, 95, 99}
+
+type Config struct {
+	// Quantiles of RTT in percents, that are shown in tables.
+	Quantiles []float64
+	// MaxPoints limits number of timeline points. When test is longer than MaxPoints seconds,
+	// timeline step is increased.
+	MaxPoints int
+	// MaxTags limits number of tags with separate stats. Samples of other tags are accounted
+	// under "other" tag. Should be positive.
+	MaxTags int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Quantiles: []float64{50, 75, 90, 95, 99, 99.9},
+		MaxPoints: 1000,
+		MaxTags:   100,
+	}
+}
+
+// Report is the data shown in HTML report. Durations are in microseconds.
+type Report struct {
+	Title     string
+	Start     time.Time
+	Duration  time.Duration
+	Quantiles []float64
+	Total     TagStats
+	Tags      []TagStats
+	// ProtoCodes and NetCodes are sorted by code.
+	ProtoCodes []CodeCount
+	NetCodes   []CodeCount
+	// Step is time between timeline points.
+	Step     time.Duration
+	Timeline []Point
+	// Planned is planned RPS of every timeline point. Empty, if load profile is not set.
+	Planned []float64
+}
+
+type TagStats struct {
+	Tag    string
+	Count  int64
+	Errors int64
+	RPS    float64
+	Min    int64
+	Mean   float64
+	Max    int64
+	// Quantiles are in the same order as Report.Quantiles.
+	Quantiles []int64
+}
+
+type CodeCount struct {
+	Code  int
+	Count int64
+	// Share is percent of all samples.
+	Share float64
+}
+
+type Point struct {
+	Offset time.Duration
+	RPS    float64
+	// ErrorsPS is number of failed samples per second.
+	ErrorsPS float64
+	// Quantiles are RTT quantiles in the same order as TimelineQuantiles.
+	Quantiles []int64
+}
+
+// Builder accumulates samples and builds Report. Samples are accounted in timeline by start time,
+// relative to the first added sample.
+type Builder struct {
+	conf       Config
+	start      time.Time
+	end        time.Time
+	total      *stats
+	tags       map[string]*stats
+	limiter    *coreutil.TagLimiter
+	protoCodes map[int]int64
+	netCodes   map[int]int64
+	step       time.Duration
+	points     []*stats
+	planned    []float64 // Planned shots per second.
+}
+
+func NewBuilder(conf Config) *Builder {
+	return &Builder{
+		conf:       conf,
+		total:      newStats(),
+		tags:       map[string]*stats{},
+		limiter:    coreutil.NewTagLimiter(conf.MaxTags),
+		protoCodes: map[int]int64{},
+		netCodes:   map[int]int64{},
+		step:       time.Second,
+	}
+}
+
+type stats struct {
+	count  int64
+	errors int64
+	rtt    *histogram.Histogram
+}
+
+func newStats() *stats {
+	return &stats{rtt: histogram.New()}
+}
+
+func (s *stats) add(failed bool, rtt int64) {
+	s.count++
+	if failed {
+		s.errors++
+	}
+	s.rtt.Record(rtt)
+}
+
+func (s *stats) merge(other *stats) {
+	s.count += other.count
+	s.errors += other.errors
+	s.rtt.Merge(other.rtt)
+}
+
+// Add accounts sample. Samples of discarded shoots are ignored.
+func (b *Builder) Add(s *netsample.Sample) {
+	errno, code := s.Errno(), s.ProtoCode()
+	if errno == netsample.DiscardedShootCodeError {
+		return
+	}
+	ts, rtt := s.Timestamp(), s.RTT()
+	if b.total.count == 0 {
+		b.start, b.end = ts, ts
+	}
+	if end := ts.Add(rtt); end.After(b.end) {
+		b.end = end
+	}
+	failed := errno != 0 || code >= 500
+	rttMicros := rtt.Microseconds()
+	b.total.add(failed, rttMicros)
+	b.tagStats(s.Tags()).add(failed, rttMicros)
+	b.protoCodes[code]++
+	if errno != 0 {
+		b.netCodes[errno]++
+	}
+	b.point(ts.Sub(b.start)).add(failed, rttMicros)
+}
+
+func (b *Builder) tagStats(tag string) *stats {
+	if tag == emptyTag {
+		tag = ""
+	}
+	tag = b.limiter.Tag(tag)
+	s, ok := b.tags[tag]
+	if ok {
+		return s
+	}
+	s = newStats()
+	b.tags[tag] = s
+	return s
+}
+
+// point returns timeline point stats for offset from start. Samples, that started before
+// the first added sample, are accounted in the first point.
+func (b *Builder) point(offset time.Duration) *stats {
+	i := 0
+	if offset > 0 {
+		i = int(offset / b.step)
+	}
+	for i >= b.conf.MaxPoints {
+		b.doubleStep()
+		i /= 2
+	}
+	for len(b.points) <= i {
+		b.points = append(b.points, newStats())
+	}
+	return b.points[i]
+}
+
+// doubleStep merges every two adjacent points into one.
+func (b *Builder) doubleStep() {
+	merged := make([]*stats, (len(b.points)+1)/2)
+	for i, p := range b.points {
+		if merged[i/2] == nil {
+			merged[i/2] = p
+			continue
+		}
+		merged[i/2].merge(p)
+	}
+	b.points = merged
+	b.step *= 2
+}
+
+// AddPlanned adds planned shots per second of pool load profile. Planned RPS of all pools are summed.
+func (b *Builder) AddPlanned(perSecond []float64) {
+	for len(b.planned) < len(perSecond) {
+		b.planned = append(b.planned, 0)
+	}
+	for i, v := range perSecond {
+		b.planned[i] += v
+	}
+}
+
+func (b *Builder) Build(title string) *Report {
+	r := &Report{
+		Title:     title,
+		Start:     b.start,
+		Duration:  b.end.Sub(b.start),
+		Quantiles: b.conf.Quantiles,
+		Step:      b.step,
+	}
+	r.Total = b.tagReport("total", b.total, r.Duration)
+	if len(b.tags) > 1 || b.tags[""] == nil {
+		for tag, s := range b.tags {
+			r.Tags = append(r.Tags, b.tagReport(tag, s, r.Duration))
+		}
+		sort.Slice(r.Tags, func(i, j int) bool { return r.Tags[i].Tag < r.Tags[j].Tag })
+	}
+	r.ProtoCodes = codesReport(b.protoCodes, b.total.count)
+	r.NetCodes = codesReport(b.netCodes, b.total.count)
+
+	stepSeconds := b.step.Seconds()
+	for i, p := range b.points {
+		point := Point{
+			Offset:   time.Duration(i) * b.step,
+			RPS:      float64(p.count) / stepSeconds,
+			ErrorsPS: float64(p.errors) / stepSeconds,
+		}
+		for _, q := range TimelineQuantiles {
+			point.Quantiles = append(point.Quantiles, p.rtt.Quantile(q/100))
+		}
+		r.Timeline = append(r.Timeline, point)
+	}
+	if len(b.planned) > 0 {
+		secondsPerStep := int(b.step / time.Second)
+		r.Planned = make([]float64, (len(b.planned)+secondsPerStep-1)/secondsPerStep)
+		for i, v := range b.planned {
+			r.Planned[i/secondsPerStep] += v / float64(secondsPerStep)
+		}
+	}
+	return r
+}
+
+func (b *Builder) tagReport(tag string, s *stats, duration time.Duration) TagStats {
+	r := TagStats{
+		Tag:    tag,
+		Count:  s.count,
+		Errors: s.errors,
+		Min:    s.rtt.Min(),
+		Mean:   s.rtt.Mean(),
+		Max:    s.rtt.Max(),
+	}
+	if seconds := duration.Seconds(); seconds >= 1 {
+		r.RPS = float64(s.count) / seconds
+	} else {
+		r.RPS = float64(s.count)
+	}
+	for _, q := range b.conf.Quantiles {
+		r.Quantiles = append(r.Quantiles, s.rtt.Quantile(q/100))
+	}
+	return r
+}
+
+func codesReport(codes map[int]int64, total int64) []CodeCount {
+	r := make([]CodeCount, 0, len(codes))
+	for code, count := range codes {
+		r = append(r, CodeCount{Code: code, Count: count, Share: float64(count) * 100 / float64(total)})
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].Code < r[j].Code })
+	return r
+}
+
+func formatQuantile(q float64) string {
+	return strconv.FormatFloat(q, 'f', -1, 64)
+}
@@ -0,0 +1,139 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yandex/pandora/core/aggregator/netsample"
+)
+
+var testStart = time.Unix(1484660999, 0)
+
+func newTestSample(tag string, offset, rtt time.Duration, code, errno int) *netsample.Sample {
+	s := netsample.Acquire(tag)
+	// Set fields via JSON, because sample setters are relative to current time.
+	data, _ := json.Marshal(map[string]interface{}{
+		"time":          float64(testStart.Add(offset).UnixNano()) / 1e9,
+		"tag":           tag,
+		"interval_real": rtt.Microseconds(),
+		"proto_code":    code,
+		"net_code":      errno,
+	})
+	if err := json.Unmarshal(data, s); err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func TestRead(t *testing.T) {
+	phout := "\n1484660999.002\ttag1#42\t333333\t0\t0\t0\t0\t0\t0\t0\t0\t200\n" +
+		"1484661000.500\ttag2\t1000\t0\t0\t0\t0\t0\t0\t0\t110\t999\n"
+	var samples []*netsample.Sample
+	err := Read(strings.NewReader(phout), func(s *netsample.Sample) { samples = append(samples, s) })
+	require.NoError(t, err)
+	require.Len(t, samples, 2)
+	assert.Equal(t, "tag1", samples[0].Tags())
+	assert.Equal(t, 333333*time.Microsecond, samples[0].RTT())
+	assert.Equal(t, 110, samples[1].Errno())
+
+	var jsonLines bytes.Buffer
+	for _, s := range samples {
+		data, err := json.Marshal(s)
+		require.NoError(t, err)
+		jsonLines.Write(append(data, '\n'))
+	}
+	var parsed []*netsample.Sample
+	err = Read(&jsonLines, func(s *netsample.Sample) { parsed = append(parsed, s) })
+	require.NoError(t, err)
+	assert.Equal(t, samples, parsed)
+
+	err = Read(strings.NewReader("1484660999.002\ttag1\n"), func(*netsample.Sample) {})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "line 1")
+}
+
+func TestBuild(t *testing.T) {
+	b := NewBuilder(DefaultConfig())
+	for i := 0; i < 20; i++ {
+		b.Add(newTestSample("a", time.Duration(i)*100*time.Millisecond, time.Duration(i+1)*time.Millisecond, 200, 0))
+	}
+	b.Add(newTestSample("b", 1500*time.Millisecond, time.Second, 999, 110))
+	b.Add(newTestSample("b", 500*time.Millisecond, time.Millisecond, 0, netsample.DiscardedShootCodeError))
+	b.AddPlanned([]float64{10, 10})
+	b.AddPlanned([]float64{1})
+	r := b.Build("test")
+
+	assert.Equal(t, "test", r.Title)
+	assert.Equal(t, testStart, r.Start)
+	assert.Equal(t, 2500*time.Millisecond, r.Duration)
+	assert.EqualValues(t, 21, r.Total.Count)
+	assert.EqualValues(t, 1, r.Total.Errors)
+	require.Len(t, r.Tags, 2)
+	assert.Equal(t, "a", r.Tags[0].Tag)
+	assert.EqualValues(t, 1000, r.Tags[0].Min)
+	assert.EqualValues(t, 20000, r.Tags[0].Max)
+	assert.Len(t, r.Tags[0].Quantiles, len(r.Quantiles))
+	assert.Equal(t, []CodeCount{{200, 20, 2000.0 / 21}, {999, 1, 100.0 / 21}}, r.ProtoCodes)
+	assert.Equal(t, []CodeCount{{110, 1, 100.0 / 21}}, r.NetCodes)
+
+	assert.Equal(t, time.Second, r.Step)
+	require.Len(t, r.Timeline, 2)
+	assert.Equal(t, 10.0, r.Timeline[0].RPS)
+	assert.Equal(t, 11.0, r.Timeline[1].RPS)
+	assert.Equal(t, 1.0, r.Timeline[1].ErrorsPS)
+	assert.Len(t, r.Timeline[0].Quantiles, len(TimelineQuantiles))
+	assert.Equal(t, []float64{11, 10}, r.Planned)
+}
+
+func TestBuildUntagged(t *testing.T) {
+	b := NewBuilder(DefaultConfig())
+	b.Add(newTestSample("", 0, time.Millisecond, 200, 0))
+	b.Add(newTestSample("__EMPTY__", 0, time.Millisecond, 200, 0))
+	r := b.Build("")
+	assert.Empty(t, r.Tags)
+	assert.EqualValues(t, 2, r.Total.Count)
+}
+
+func TestBuildStepIncrease(t *testing.T) {
+	conf := DefaultConfig()
+	conf.MaxPoints = 4
+	b := NewBuilder(conf)
+	for i := 0; i < 10; i++ {
+		b.Add(newTestSample("", time.Duration(i)*time.Second, time.Millisecond, 200, 0))
+	}
+	b.AddPlanned([]float64{1, 1, 1, 1, 1, 1, 1, 1, 1, 1})
+	r := b.Build("")
+	assert.Equal(t, 4*time.Second, r.Step)
+	require.Len(t, r.Timeline, 3)
+	assert.Equal(t, []float64{1, 1, 0.5}, []float64{r.Timeline[0].RPS, r.Timeline[1].RPS, r.Timeline[2].RPS})
+	assert.Equal(t, 8*time.Second, r.Timeline[2].Offset)
+	assert.Equal(t, []float64{1, 1, 0.5}, r.Planned)
+}
+
+func TestWriteHTML(t *testing.T) {
+	b := NewBuilder(DefaultConfig())
+	b.Add(newTestSample("<script>", 0, time.Millisecond, 200, 0))
+	b.Add(newTestSample("b", time.Second, 2*time.Millisecond, 503, 0))
+	b.AddPlanned([]float64{1, 1})
+	var out bytes.Buffer
+	require.NoError(t, WriteHTML(&out, b.Build("Load test")))
+	html := out.String()
+	assert.Contains(t, html, "<title>Load test</title>")
+	assert.Equal(t, 2, strings.Count(html, "<svg"))
+	assert.Contains(t, html, `stroke-dasharray="6 4"`)
+	assert.Contains(t, html, "<th>q99.9</th>")
+	assert.Contains(t, html, "<td>503</td><td>1</td><td>50.00%</td>")
+	assert.Contains(t, html, "&lt;script&gt;")
+	assert.NotContains(t, html, "<script>")
+}
+
+func TestNiceCeil(t *testing.T) {
+	for v, expected := range map[float64]float64{0: 1, 0.3: 0.5, 1: 1, 1.1: 2, 3: 5, 7: 10, 120: 200} {
+		assert.InDelta(t, expected, niceCeil(v), 1e-9, "%v", v)
+	}
+}
@@ -0,0 +1,317 @@
+package schedule
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/yandex/pandora/core"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+)
+
+// AdaptiveConfig configures schedule that looks for maximum sustainable RPS.
+// Rate is increased from From to To by Step every StepDuration. After every step reported
+// samples are checked against SLO: share of errors and latency quantile. When SLO is violated,
+// schedule backs off to the last sustainable rate and holds it for Hold, or stops if Hold is zero.
+type AdaptiveConfig struct {
+	From         float64       `validate:"min=0"`
+	To           float64       `validate:"min=0"`
+	Step         float64       `validate:"min=0"`
+	StepDuration time.Duration `validate:"min-time=1ms"`
+	// MaxErrorRate is maximum share of failed samples in [0, 1]. Failed samples are samples
+	// with net error or proto code >= 500. Zero means no limit.
+	MaxErrorRate float64 `validate:"min=0,max=1"`
+	// Quantile is percent of samples, that should have RTT less than MaxLatency.
+	Quantile   float64       `validate:"min=0,max=100"`
+	MaxLatency time.Duration `validate:"min-time=0s"` // Zero means no limit.
+	Hold       time.Duration `validate:"min-time=0s"`
+	// Grace is time after step end, that step samples are awaited for, before SLO check.
+	// Step rate is held during Grace, and samples scheduled in this time are not checked.
+	Grace time.Duration `validate:"min-time=0s"`
+	// MinSamples is minimum number of step samples, that is required to check SLO.
+	// Step with less samples violates SLO.
+	MinSamples int64 `validate:"min=1"`
+}
+
+func DefaultAdaptiveConfig() AdaptiveConfig {
+	return AdaptiveConfig{Quantile: 99, Grace: time.Second, MinSamples: 1}
+}
+
+func NewAdaptiveConf(conf AdaptiveConfig) core.Schedule {
+	return NewAdaptive(conf)
+}
+
+// NewAdaptive returns schedule, that changes rate due to samples passed to ObserveSample.
+// Engine passes samples reported to pool Aggregator to shared RPS schedule, if it has
+// ObserveSample method. That is, adaptive schedule doesn't get feedback in rps-per-instance mode.
+// Samples are attributed to step by their scheduled time. When step tokens are over, TryNext
+// returns SLO check time to retry at, and Next blocks until it.
+// Pool runs schedule on its timeline, that is passed to Bind, so pause and rate change of pool
+// shift step bounds and check time in real time, and samples are attributed in schedule timeline.
+// SLO is not checked, and Next doesn't block, if schedule is not bound to pool, because there is
+// no feedback. So preview plans all steps up to To.
+// Result of search is logged on finish, and available via SustainableRPS.
+func NewAdaptive(conf AdaptiveConfig) core.Schedule {
+	if conf.Step <= 0 {
+		conf.To = conf.From
+	}
+	return &adaptiveSchedule{
+		conf: conf,
+		log:  zap.L(),
+		rate: conf.From,
+	}
+}
+
+type adaptiveSchedule struct {
+	conf AdaptiveConfig
+
+	mu           sync.Mutex
+	log          *zap.Logger
+	bound        bool
+	scheduleTime func(real time.Time) time.Time
+	started      bool
+	finished     bool
+	finish       time.Time
+	stepStart    time.Time
+	stepEnd      time.Time
+	rate         float64
+	stepN        int64 // Tokens in current step, including grace ones.
+	stepI        int64 // Tokens withdrawn in current step.
+	checked      bool  // SLO of current step is checked.
+	holding      bool
+	// sustainable is the last rate, that didn't violate SLO. Negative if no rate was checked.
+	sustainable float64
+
+	// stats of current step. Nil, if step is not checked.
+	stats atomic.Pointer[sloStats]
+}
+
+var (
+	_ core.BoundSchedule    = (*adaptiveSchedule)(nil)
+	_ core.DeferredSchedule = (*adaptiveSchedule)(nil)
+)
+
+// sloSample is subset of netsample.Sample methods, required to check SLO.
+type sloSample interface {
+	ScheduledTime() time.Time
+	RTT() time.Duration
+	Errno() int
+	ProtoCode() int
+}
+
+func (s *adaptiveSchedule) Bind(deps core.ScheduleDeps) {
+	if deps.PerInstance {
+		// No feedback in rps-per-instance mode, so schedule is run as unbound.
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.log = deps.Log
+	s.bound = true
+	s.scheduleTime = deps.ScheduleTime
+}
+
+func (s *adaptiveSchedule) ObserveSample(sample core.Sample) {
+	if sample, ok := sample.(sloSample); ok {
+		if stats := s.stats.Load(); stats != nil {
+			stats.add(sample, s.scheduleTime)
+		}
+	}
+}
+
+// now returns current moment of schedule timeline.
+func (s *adaptiveSchedule) now() time.Time {
+	now := time.Now()
+	if s.scheduleTime == nil {
+		return now
+	}
+	return s.scheduleTime(now)
+}
+
+// SustainableRPS returns the last rate that was checked and didn't violate SLO.
+// Returns negative value if no rate has been checked yet.
+func (s *adaptiveSchedule) SustainableRPS() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.started {
+		return -1
+	}
+	return s.sustainable
+}
+
+func (s *adaptiveSchedule) Start(startAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.start(startAt)
+}
+
+func (s *adaptiveSchedule) start(startAt time.Time) {
+	if s.started {
+		panic("schedule is already started")
+	}
+	s.started = true
+	s.sustainable = -1
+	s.startStep(startAt, s.conf.From, s.conf.StepDuration, s.bound)
+}
+
+func (s *adaptiveSchedule) startStep(startAt time.Time, rate float64, duration time.Duration, checked bool) {
+	s.stepStart = startAt
+	s.stepEnd = startAt.Add(duration)
+	s.rate = rate
+	s.stepN = int64(rate * duration.Seconds())
+	s.stepI = 0
+	s.checked = checked && s.stepN > 0
+	if !s.checked {
+		s.stats.Store(nil)
+		return
+	}
+	s.stepN = int64(rate * (duration + s.conf.Grace).Seconds())
+	s.stats.Store(&sloStats{start: s.stepStart, end: s.stepEnd, maxLatency: s.conf.MaxLatency})
+}
+
+// checkAt returns time of current step SLO check, that is also start of the next step.
+func (s *adaptiveSchedule) checkAt() time.Time {
+	if !s.checked {
+		return s.stepEnd
+	}
+	return s.stepEnd.Add(s.conf.Grace)
+}
+
+// Next blocks until SLO check time, if step tokens are over. Pool doesn't call it, because
+// schedule is DeferredSchedule, so it is called only if schedule is used out of Pool.
+func (s *adaptiveSchedule) Next() (ts time.Time, ok bool) {
+	for {
+		ts, ok, retryAt := s.TryNext()
+		if retryAt.IsZero() {
+			return ts, ok
+		}
+		time.Sleep(retryAt.Sub(s.now()))
+	}
+}
+
+func (s *adaptiveSchedule) TryNext() (ts time.Time, ok bool, retryAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.started {
+		s.start(s.now())
+	}
+	for !s.finished {
+		if s.stepI < s.stepN {
+			ts = s.stepStart.Add(time.Duration(float64(s.stepI) * 1e9 / s.rate))
+			s.stepI++
+			return ts, true, time.Time{}
+		}
+		if checkAt := s.checkAt(); s.checked && s.now().Before(checkAt) {
+			return time.Time{}, false, checkAt
+		}
+		s.nextStep()
+	}
+	return s.finish, false, time.Time{}
+}
+
+func (s *adaptiveSchedule) Left() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.finished {
+		return 0
+	}
+	return -1
+}
+
+// nextStep checks SLO for finished step, and starts the next one, or finishes schedule.
+func (s *adaptiveSchedule) nextStep() {
+	log := s.log.With(zap.Float64("rps", s.rate))
+	end := s.checkAt()
+	if s.holding {
+		s.finishAt(end, log)
+		return
+	}
+	if s.checked {
+		if violation := s.stats.Load().check(s.conf); violation != "" {
+			log.Info("Adaptive schedule SLO violated", zap.String("reason", violation))
+			if s.conf.Hold > 0 && s.sustainable > 0 {
+				log.Info("Adaptive schedule backs off", zap.Float64("sustainable-rps", s.sustainable),
+					zap.Duration("hold", s.conf.Hold))
+				s.holding = true
+				s.startStep(end, s.sustainable, s.conf.Hold, false)
+				return
+			}
+			s.finishAt(end, log)
+			return
+		}
+		s.sustainable = s.rate
+		log.Debug("Adaptive schedule step passed")
+	}
+	if s.rate >= s.conf.To {
+		log.Info("Adaptive schedule reached max rate without SLO violation")
+		s.finishAt(end, log)
+		return
+	}
+	rate := s.rate + s.conf.Step
+	if rate > s.conf.To {
+		rate = s.conf.To
+	}
+	s.startStep(end, rate, s.conf.StepDuration, s.bound)
+}
+
+func (s *adaptiveSchedule) finishAt(finish time.Time, log *zap.Logger) {
+	s.finished = true
+	s.finish = finish
+	s.stats.Store(nil)
+	log.Info("Adaptive schedule finished", zap.Float64("sustainable-rps", s.sustainable))
+}
+
+// sloStats are counters of samples scheduled in [start, end) step interval.
+// Counters are atomic, because samples are added in instance goroutines.
+type sloStats struct {
+	start, end time.Time
+	maxLatency time.Duration
+
+	count  atomic.Int64
+	errors atomic.Int64
+	slow   atomic.Int64 // Samples with RTT greater than maxLatency.
+}
+
+// add adds sample, if it is scheduled in step. Sample scheduled time is mapped to schedule
+// timeline with scheduleTime, if it is not nil.
+func (s *sloStats) add(sample sloSample, scheduleTime func(real time.Time) time.Time) {
+	// Samples without scheduled time are considered as scheduled in the current step.
+	if scheduled := sample.ScheduledTime(); !scheduled.IsZero() {
+		if scheduleTime != nil {
+			scheduled = scheduleTime(scheduled)
+		}
+		if scheduled.Before(s.start) || !scheduled.Before(s.end) {
+			return
+		}
+	}
+	s.count.Inc()
+	if sample.Errno() != 0 || sample.ProtoCode() >= 500 {
+		s.errors.Inc()
+	}
+	if s.maxLatency > 0 && sample.RTT() > s.maxLatency {
+		s.slow.Inc()
+	}
+}
+
+// check returns SLO violation description, or empty string if SLO is not violated.
+func (s *sloStats) check(conf AdaptiveConfig) string {
+	count := s.count.Load()
+	if count < conf.MinSamples {
+		return fmt.Sprintf("%d samples < %d min samples", count, conf.MinSamples)
+	}
+	if conf.MaxErrorRate > 0 {
+		errorRate := float64(s.errors.Load()) / float64(count)
+		if errorRate > conf.MaxErrorRate {
+			return fmt.Sprintf("error rate %.4f > %.4f", errorRate, conf.MaxErrorRate)
+		}
+	}
+	if conf.MaxLatency > 0 {
+		// Quantile of RTT is greater than MaxLatency, if share of slower samples is greater than quantile complement.
+		slowRate := float64(s.slow.Load()) / float64(count)
+		if slowRate > 1-conf.Quantile/100 {
+			return fmt.Sprintf("q%v > %v: %.4f of samples are slower", conf.Quantile, conf.MaxLatency, slowRate)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,209 @@
+package schedule
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/yandex/pandora/core"
+	"github.com/yandex/pandora/lib/ginkgoutil"
+)
+
+type testSLOSample struct {
+	scheduled time.Time
+	rtt       time.Duration
+	errno     int
+	protoCode int
+}
+
+func (s testSLOSample) ScheduledTime() time.Time { return s.scheduled }
+func (s testSLOSample) RTT() time.Duration       { return s.rtt }
+func (s testSLOSample) Errno() int               { return s.errno }
+func (s testSLOSample) ProtoCode() int           { return s.protoCode }
+
+var _ = Describe("adaptive", func() {
+	var (
+		conf   AdaptiveConfig
+		start  time.Time
+		testee *adaptiveSchedule
+	)
+	BeforeEach(func() {
+		conf = DefaultAdaptiveConfig()
+		conf.From = 1
+		conf.To = 3
+		conf.Step = 1
+		conf.StepDuration = 2 * time.Second
+		conf.MaxErrorRate = 0.1
+		conf.MaxLatency = time.Second
+		conf.Grace = 0
+		// Schedule started in the past, so Next doesn't wait for SLO check time.
+		start = time.Now().Add(-time.Hour)
+	})
+	JustBeforeEach(func() {
+		testee = NewAdaptiveConf(conf).(*adaptiveSchedule)
+		testee.Bind(core.ScheduleDeps{Log: ginkgoutil.NewLogger()})
+		testee.Start(start)
+	})
+	next := func() time.Duration {
+		x, ok := testee.Next()
+		Expect(ok).To(BeTrue())
+		return x.Sub(start)
+	}
+	expectFinish := func(at time.Duration) {
+		x, ok := testee.Next()
+		Expect(ok).To(BeFalse())
+		Expect(x.Sub(start)).To(Equal(at))
+		Expect(testee.Left()).To(Equal(0))
+	}
+	// report reports n samples scheduled at passed time since start.
+	report := func(n int, at time.Duration, sample testSLOSample) {
+		sample.scheduled = start.Add(at)
+		for i := 0; i < n; i++ {
+			testee.ObserveSample(sample)
+		}
+	}
+	ok := testSLOSample{rtt: 100 * time.Millisecond, protoCode: 200}
+
+	It("steps up to max without SLO violation", func() {
+		Expect(testee.Left()).To(Equal(-1))
+		Expect(next()).To(Equal(time.Duration(0)))
+		Expect(next()).To(Equal(time.Second))
+		report(2, time.Second, ok)
+		Expect(next()).To(Equal(2 * time.Second))
+		Expect(next()).To(Equal(2500 * time.Millisecond))
+		Expect(next()).To(Equal(3 * time.Second))
+		Expect(next()).To(Equal(3500 * time.Millisecond))
+		report(4, 3*time.Second, ok)
+		for i := 0; i < 6; i++ {
+			next()
+		}
+		report(6, 5*time.Second, ok)
+		expectFinish(6 * time.Second)
+		Expect(testee.SustainableRPS()).To(BeEquivalentTo(3))
+	})
+
+	It("stops on error rate violation", func() {
+		next()
+		next()
+		report(2, 0, ok)
+		next()
+		report(3, 2*time.Second, ok)
+		report(1, 2*time.Second, testSLOSample{errno: 110, protoCode: 999})
+		for i := 0; i < 3; i++ {
+			next()
+		}
+		expectFinish(4 * time.Second)
+		Expect(testee.SustainableRPS()).To(BeEquivalentTo(1))
+	})
+
+	It("stops on latency violation", func() {
+		next()
+		report(1, 0, testSLOSample{rtt: 2 * time.Second, protoCode: 200})
+		next()
+		expectFinish(2 * time.Second)
+		Expect(testee.SustainableRPS()).To(BeEquivalentTo(-1))
+	})
+
+	It("step without samples violates SLO", func() {
+		next()
+		next()
+		expectFinish(2 * time.Second)
+		Expect(testee.SustainableRPS()).To(BeEquivalentTo(-1))
+	})
+
+	It("samples of other steps are not checked", func() {
+		next()
+		next()
+		report(2, 0, ok)
+		for i := 0; i < 4; i++ {
+			next()
+		}
+		// Late samples of the first step, and samples of the second step.
+		report(10, time.Second, testSLOSample{protoCode: 503})
+		report(4, 2*time.Second, ok)
+		Expect(next()).To(Equal(4 * time.Second))
+		Expect(testee.SustainableRPS()).To(BeEquivalentTo(2))
+	})
+
+	It("samples are attributed in schedule timeline", func() {
+		// Pool has been paused for an hour, so schedule time is an hour behind real time.
+		const paused = time.Hour
+		testee = NewAdaptiveConf(conf).(*adaptiveSchedule)
+		testee.Bind(core.ScheduleDeps{
+			Log:          ginkgoutil.NewLogger(),
+			ScheduleTime: func(real time.Time) time.Time { return real.Add(-paused) },
+		})
+		testee.Start(start)
+		next()
+		next()
+		report(2, paused+time.Second, ok)
+		report(1, time.Second, testSLOSample{protoCode: 503}) // Scheduled before start in schedule time.
+		Expect(next()).To(Equal(2 * time.Second))
+		Expect(testee.SustainableRPS()).To(BeEquivalentTo(1))
+	})
+
+	It("not bound schedule is not checked", func() {
+		testee = NewAdaptiveConf(conf).(*adaptiveSchedule)
+		testee.Start(start)
+		for i := 0; i < 2+4+6; i++ {
+			next()
+		}
+		expectFinish(6 * time.Second)
+		Expect(testee.SustainableRPS()).To(BeEquivalentTo(-1))
+	})
+
+	Context("grace", func() {
+		BeforeEach(func() {
+			conf.Grace = time.Second
+		})
+		It("holds step rate until check", func() {
+			Expect(next()).To(Equal(time.Duration(0)))
+			Expect(next()).To(Equal(time.Second))
+			Expect(next()).To(Equal(2 * time.Second)) // Grace token.
+			report(2, time.Second, ok)
+			report(1, 2*time.Second, testSLOSample{protoCode: 503}) // Grace samples are not checked.
+			Expect(next()).To(Equal(3 * time.Second))
+			Expect(testee.SustainableRPS()).To(BeEquivalentTo(1))
+		})
+
+		It("next waits for check time", func() {
+			conf.StepDuration = 50 * time.Millisecond
+			conf.Grace = 50 * time.Millisecond
+			conf.From = 20
+			conf.To = 20
+			start = time.Now()
+			testee = NewAdaptiveConf(conf).(*adaptiveSchedule)
+			testee.Bind(core.ScheduleDeps{Log: ginkgoutil.NewLogger()})
+			testee.Start(start)
+			for i := 0; i < 2; i++ {
+				next()
+			}
+			_, ok, retryAt := testee.TryNext()
+			Expect(ok).To(BeFalse())
+			Expect(retryAt).To(Equal(start.Add(100 * time.Millisecond)))
+			_, ok = testee.Next()
+			Expect(ok).To(BeFalse())
+			Expect(time.Since(start)).To(BeNumerically(">=", 100*time.Millisecond))
+		})
+	})
+
+	Context("hold", func() {
+		BeforeEach(func() {
+			conf.Hold = 3 * time.Second
+		})
+		It("backs off to sustainable rate", func() {
+			next()
+			next()
+			report(2, 0, ok)
+			for i := 0; i < 4; i++ {
+				next()
+			}
+			report(4, 2*time.Second, testSLOSample{protoCode: 503})
+			Expect(next()).To(Equal(4 * time.Second))
+			Expect(next()).To(Equal(5 * time.Second))
+			Expect(next()).To(Equal(6 * time.Second))
+			expectFinish(7 * time.Second)
+			Expect(testee.SustainableRPS()).To(BeEquivalentTo(1))
+		})
+	})
+})
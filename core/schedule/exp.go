@@ -0,0 +1,37 @@
+package schedule
+
+import (
+	"math"
+	"time"
+
+	"github.com/yandex/pandora/core"
+)
+
+// ExpConfig configures schedule, which RPS grows exponentially from From to To during Duration.
+// That is, RPS is multiplied by the same factor every second. Decrease is also supported.
+type ExpConfig struct {
+	From     float64       `validate:"gt=0"`
+	To       float64       `validate:"gt=0"`
+	Duration time.Duration `validate:"min-time=1ms"`
+}
+
+func NewExpConf(conf ExpConfig) core.Schedule {
+	return NewExp(conf.From, conf.To, conf.Duration)
+}
+
+func NewExp(from, to float64, duration time.Duration) core.Schedule {
+	if from == to {
+		return NewConst(from, duration)
+	}
+	xn := duration.Seconds()
+	k := math.Log(to/from) / xn
+	// RPS(x) = from * e^(k*x)
+	// Number of shots from 0 to x = from * (e^(k*x) - 1) / k
+	// Has shoot i. When it should be? x = ln(1 + i*k/from) / k
+	n := int64(from * math.Expm1(k*xn) / k)
+	kDivFrom := k / from
+	billionDivK := 1e9 / k
+	return NewDoAtSchedule(duration, n, func(i int64) time.Duration {
+		return time.Duration(math.Log1p(float64(i)*kDivFrom) * billionDivK)
+	})
+}
@@ -0,0 +1,149 @@
+package schedule
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/pkg/errors"
+	"github.com/yandex/pandora/core"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+// ExpressionConfig configures schedule, which RPS is formula of time.
+// RPS is HCL arithmetic expression of variable t, that is seconds since schedule start, and
+// variable duration, that is schedule duration in seconds. For example: "100 + 50 * sin(2 * pi * t / 60)".
+// Functions sin, cos, exp, log, sqrt, abs, pow, min, max, floor, ceil and constant pi are available.
+// Negative RPS is considered as zero.
+// Number of shots, and so Left, is approximate: RPS is integrated by trapezoid rule over at most
+// 10000 segments, so it may differ from exact integral of expression with sharp changes.
+type ExpressionConfig struct {
+	RPS      string        `validate:"required"`
+	Duration time.Duration `validate:"min-time=1ms"`
+}
+
+func NewExpressionConf(conf ExpressionConfig) (core.Schedule, error) {
+	return NewExpression(conf.RPS, conf.Duration)
+}
+
+// expressionSegments is max number of segments, that expression RPS is approximated by.
+const expressionSegments = 10000
+
+// NewExpression returns schedule, which RPS is expression of time. See ExpressionConfig.
+// Expression is evaluated at evenly spaced points, and RPS changes linearly between them.
+// There are at most expressionSegments+1 points, regardless of duration, so even days long
+// schedule is built in about 0.1s, and shots are not evaluated on the hot path. Evaluation error
+// at any point fails schedule creation.
+func NewExpression(expression string, duration time.Duration) (core.Schedule, error) {
+	rps, err := parseRPSExpression(expression, duration)
+	if err != nil {
+		return nil, err
+	}
+	step := duration / expressionSegments
+	if step < time.Millisecond {
+		step = time.Millisecond
+	}
+	var points []time.Duration
+	for x := time.Duration(0); x < duration; x += step {
+		points = append(points, x)
+	}
+	points = append(points, duration)
+	rates := make([]float64, len(points))
+	for i, x := range points {
+		rates[i], err = rps(x.Seconds())
+		if err != nil {
+			return nil, err
+		}
+	}
+	// shots[i] is number of shots before i'th point.
+	shots := make([]float64, len(points))
+	for i := 1; i < len(points); i++ {
+		shots[i] = shots[i-1] + (rates[i-1]+rates[i])/2*(points[i]-points[i-1]).Seconds()
+	}
+	// Epsilon compensates error of float summation, so whole number of shots is not lost.
+	n := int64(shots[len(shots)-1] + 1e-6)
+	return NewDoAtSchedule(duration, n, func(i int64) time.Duration {
+		shot := float64(i)
+		segment := sort.Search(len(points)-1, func(s int) bool { return shots[s+1] > shot }) // Shot is in segment.
+		if segment == len(points)-1 {
+			return duration // The last shot, that was rounded up by epsilon.
+		}
+		// Segment is line schedule part. See lineDoAt.
+		b := rates[segment]
+		a := (rates[segment+1] - b) / (points[segment+1] - points[segment]).Seconds()
+		left := shot - shots[segment]
+		var x float64
+		if a == 0 {
+			x = left / b
+		} else {
+			x = (math.Sqrt(math.Max(2*a*left+b*b, 0)) - b) / a
+		}
+		return points[segment] + time.Duration(x*1e9)
+	}), nil
+}
+
+func parseRPSExpression(expression string, duration time.Duration) (func(x float64) (float64, error), error) {
+	expr, diags := hclsyntax.ParseExpression([]byte(expression), "rps", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, errors.WithMessage(diags, "rps expression parse failed")
+	}
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"pi":       cty.NumberFloatVal(math.Pi),
+			"duration": cty.NumberFloatVal(duration.Seconds()),
+		},
+		Functions: map[string]function.Function{
+			"sin":   mathFunction(math.Sin),
+			"cos":   mathFunction(math.Cos),
+			"exp":   mathFunction(math.Exp),
+			"log":   mathFunction(math.Log),
+			"sqrt":  mathFunction(math.Sqrt),
+			"abs":   stdlib.AbsoluteFunc,
+			"pow":   stdlib.PowFunc,
+			"min":   stdlib.MinFunc,
+			"max":   stdlib.MaxFunc,
+			"floor": stdlib.FloorFunc,
+			"ceil":  stdlib.CeilFunc,
+		},
+	}
+	return func(x float64) (float64, error) {
+		ctx.Variables["t"] = cty.NumberFloatVal(x)
+		value, diags := expr.Value(ctx)
+		if diags.HasErrors() {
+			return 0, errors.WithMessagef(diags, "rps expression evaluation failed at t=%v", x)
+		}
+		number, err := convert.Convert(value, cty.Number)
+		if err != nil || number.IsNull() || !number.IsKnown() {
+			return 0, errors.Errorf("rps expression value of type %s is not a number at t=%v", value.Type().FriendlyName(), x)
+		}
+		rps, _ := number.AsBigFloat().Float64()
+		if math.IsNaN(rps) || math.IsInf(rps, 0) {
+			return 0, errors.Errorf("rps expression value is %v at t=%v", rps, x)
+		}
+		if rps < 0 {
+			rps = 0
+		}
+		return rps, nil
+	}, nil
+}
+
+// mathFunction returns HCL function of one number argument.
+func mathFunction(f func(float64) float64) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{{Name: "x", Type: cty.Number}},
+		Type:   function.StaticReturnType(cty.Number),
+		Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+			x, _ := args[0].AsBigFloat().Float64()
+			y := f(x)
+			if math.IsNaN(y) || math.IsInf(y, 0) {
+				return cty.NilVal, errors.Errorf("result of %v is not a finite number", x)
+			}
+			return cty.NumberFloatVal(y), nil
+		},
+	})
+}
@@ -0,0 +1,108 @@
+package schedule
+
+import (
+	"sync"
+	"time"
+
+	"github.com/yandex/pandora/core"
+)
+
+// InstanceProfileConfig configures startup schedule, that increases and decreases number of
+// active instances step by step. For example, ramps up 1000 users, holds them, and ramps down to 100.
+type InstanceProfileConfig struct {
+	Steps []InstanceProfileStep `validate:"required,dive"`
+}
+
+// InstanceProfileStep changes number of active instances linearly from To of previous step
+// (zero for the first step) to To during Duration. All changes are made at step start, if Duration
+// is zero, and number of instances is held, if To is the same as in previous step.
+type InstanceProfileStep struct {
+	To       int64         `validate:"min=0"`
+	Duration time.Duration `validate:"min-time=0s"`
+}
+
+func NewInstanceProfileConf(conf InstanceProfileConfig) core.Schedule {
+	return NewInstanceProfile(conf.Steps)
+}
+
+func NewInstanceProfile(steps []InstanceProfileStep) core.InstanceStopSchedule {
+	s := &instanceProfile{steps: steps}
+	var from int64
+	for _, step := range steps {
+		s.duration += step.Duration
+		s.left += abs64(step.To - from)
+		from = step.To
+	}
+	return s
+}
+
+type instanceProfile struct {
+	steps    []InstanceProfileStep
+	duration time.Duration
+
+	mu      sync.Mutex
+	started bool
+	start   time.Time
+	left    int64
+	stop    bool
+	// Current step state.
+	step      int
+	stepStart time.Duration
+	from      int64 // Active instances at step start.
+	changed   int64 // Instances started or stopped in step.
+}
+
+func (s *instanceProfile) Start(startAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.startAt(startAt)
+}
+
+func (s *instanceProfile) startAt(startAt time.Time) {
+	if s.started {
+		return
+	}
+	s.started = true
+	s.start = startAt
+}
+
+func (s *instanceProfile) Next() (ts time.Time, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.startAt(time.Now())
+	for ; s.step < len(s.steps); s.step++ {
+		step := s.steps[s.step]
+		diff := step.To - s.from
+		n := abs64(diff)
+		if s.changed < n {
+			offset := s.stepStart + time.Duration(float64(step.Duration)*float64(s.changed)/float64(n))
+			s.changed++
+			s.left--
+			s.stop = diff < 0
+			return s.start.Add(offset), true
+		}
+		s.stepStart += step.Duration
+		s.from = step.To
+		s.changed = 0
+	}
+	return s.start.Add(s.duration), false
+}
+
+func (s *instanceProfile) IsStop() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stop
+}
+
+func (s *instanceProfile) Left() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int(s.left)
+}
+
+func abs64(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
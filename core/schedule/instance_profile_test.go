@@ -0,0 +1,53 @@
+package schedule
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/yandex/pandora/core/coretest"
+)
+
+var _ = Describe("instance profile", func() {
+	steps := []InstanceProfileStep{
+		{To: 2, Duration: 0},
+		{To: 4, Duration: 2 * time.Second},
+		{To: 4, Duration: time.Second},
+		{To: 1, Duration: 3 * time.Second},
+	}
+
+	It("nexts", func() {
+		testee := NewInstanceProfile(steps)
+		Expect(testee.Left()).To(Equal(7))
+		coretest.ExpectScheduleNexts(testee, 0, 0, 0, time.Second, 3*time.Second, 4*time.Second, 5*time.Second, 6*time.Second)
+	})
+
+	It("stops", func() {
+		testee := NewInstanceProfile(steps)
+		var stops []bool
+		for {
+			_, ok := testee.Next()
+			if !ok {
+				break
+			}
+			stops = append(stops, testee.IsStop())
+		}
+		Expect(stops).To(Equal([]bool{false, false, false, false, true, true, true}))
+	})
+
+	It("part", func() {
+		testee := NewPart(NewInstanceProfile(steps), 1, 2)
+		Expect(testee.Left()).To(BeNumerically("<", 0))
+		stopSchedule := testee.(interface{ IsStop() bool })
+		var stops []bool
+		for {
+			_, ok := testee.Next()
+			if !ok {
+				break
+			}
+			stops = append(stops, stopSchedule.IsStop())
+		}
+		// Starts 1 and 3, and stop 1 of 0, 1 and 2.
+		Expect(stops).To(Equal([]bool{false, false, true}))
+	})
+})
@@ -0,0 +1,143 @@
+package schedule
+
+import (
+	"math"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/yandex/pandora/core"
+	"github.com/yandex/pandora/core/coretest"
+)
+
+func drainDuration(sched core.Schedule) []time.Duration {
+	start := time.Now()
+	sched.Start(start)
+	return coretest.DrainScheduleDuration(sched, start)
+}
+
+func expectNextsNear(actual []time.Duration, expected []time.Duration) {
+	Expect(actual).To(HaveLen(len(expected)))
+	for i := range expected {
+		Expect(actual[i]).To(BeNumerically("~", expected[i], time.Microsecond), "next %v", i)
+	}
+}
+
+var _ = Describe("sine", func() {
+	It("oscillates from min to max", func() {
+		testee := NewSineConf(SineConfig{Min: 10, Max: 30, Period: time.Second, Duration: 2 * time.Second})
+		Expect(testee.Left()).To(Equal(40))
+		nexts := drainDuration(testee)
+		Expect(nexts[len(nexts)-1]).To(Equal(2 * time.Second))
+		Expect(nexts[0]).To(Equal(time.Duration(0)))
+		// Number of shots before x is 20x - 10sin(2πx)/(2π).
+		Expect(nexts[10]).To(BeNumerically("~", 500*time.Millisecond, time.Microsecond))
+		Expect(nexts[20]).To(BeNumerically("~", time.Second, time.Microsecond))
+		var firstQuarter int
+		for _, next := range nexts {
+			if next < 250*time.Millisecond {
+				firstQuarter++
+			}
+		}
+		Expect(firstQuarter).To(Equal(4))
+	})
+
+	It("const, if min equals max", func() {
+		testee := NewSineConf(SineConfig{Min: 2, Max: 2, Period: time.Second, Duration: time.Second})
+		coretest.ExpectScheduleNexts(testee, 0, 500*time.Millisecond, time.Second)
+	})
+})
+
+var _ = Describe("exp", func() {
+	It("doubles every second", func() {
+		testee := NewExpConf(ExpConfig{From: 1, To: 8, Duration: 3 * time.Second})
+		// Number of shots is (8 - 1) / ln(2).
+		Expect(testee.Left()).To(Equal(10))
+		expected := make([]time.Duration, 10)
+		for i := range expected {
+			expected[i] = time.Duration(math.Log2(1+float64(i)*math.Ln2) * 1e9)
+		}
+		expected = append(expected, 3*time.Second)
+		expectNextsNear(drainDuration(testee), expected)
+	})
+
+	It("decreases", func() {
+		testee := NewExpConf(ExpConfig{From: 8, To: 1, Duration: 3 * time.Second})
+		Expect(testee.Left()).To(Equal(10))
+		nexts := drainDuration(testee)
+		Expect(nexts[1] - nexts[0]).To(BeNumerically("<", nexts[10]-nexts[9]))
+	})
+})
+
+var _ = Describe("expression", func() {
+	It("const", func() {
+		testee, err := NewExpressionConf(ExpressionConfig{RPS: "10", Duration: time.Second})
+		Expect(err).NotTo(HaveOccurred())
+		expected := make([]time.Duration, 11)
+		for i := range expected {
+			expected[i] = time.Duration(i) * 100 * time.Millisecond
+		}
+		expectNextsNear(drainDuration(testee), expected)
+	})
+
+	It("same as line", func() {
+		testee, err := NewExpressionConf(ExpressionConfig{RPS: "duration * t", Duration: 2 * time.Second})
+		Expect(err).NotTo(HaveOccurred())
+		expectNextsNear(drainDuration(testee), drainDuration(NewLine(0, 4, 2*time.Second)))
+	})
+
+	It("functions", func() {
+		testee, err := NewExpressionConf(ExpressionConfig{
+			RPS:      "max(0, 10 * sin(2 * pi * t / duration)) + sqrt(pow(abs(-2), 2)) - floor(log(exp(1.5)))",
+			Duration: time.Second,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		// Integral of 10*sin over the first half of period is 10/π, plus 1 for the rest.
+		Expect(testee.Left()).To(Equal(4))
+	})
+
+	It("negative rps is zero", func() {
+		testee, err := NewExpressionConf(ExpressionConfig{RPS: "t < 1 ? -100 : 10", Duration: 2 * time.Second})
+		Expect(err).NotTo(HaveOccurred())
+		nexts := drainDuration(testee)
+		Expect(nexts[0]).To(BeNumerically(">=", 999*time.Millisecond))
+	})
+
+	It("large duration", func() {
+		const duration = 30 * 24 * time.Hour
+		testee, err := NewExpressionConf(ExpressionConfig{RPS: "1000", Duration: duration})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(testee.Left()).To(Equal(1000 * int(duration/time.Second)))
+		start := time.Now()
+		testee.Start(start)
+		for i := 0; i < 3; i++ {
+			next, ok := testee.Next()
+			Expect(ok).To(BeTrue())
+			Expect(next.Sub(start)).To(BeNumerically("~", time.Duration(i)*time.Millisecond, time.Microsecond))
+		}
+
+		line, err := NewExpressionConf(ExpressionConfig{RPS: "t", Duration: duration})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(line.Left()).To(BeNumerically("~", NewLine(0, duration.Seconds(), duration).Left(), 1))
+	})
+
+	It("fails in the middle of range", func() {
+		for expression, at := range map[string]string{
+			"sqrt(0.5 - t)":             "t=0.501",
+			"t < 0.7 ? 1 : \"fast\"":    "t=0.7",
+			"10 / (t - 0.25)":           "t=0.25",
+			"t < duration ? 1 : log(0)": "t=1",
+		} {
+			_, err := NewExpressionConf(ExpressionConfig{RPS: expression, Duration: time.Second})
+			Expect(err).To(HaveOccurred(), expression)
+			Expect(err.Error()).To(ContainSubstring(at), expression)
+		}
+	})
+
+	It("invalid", func() {
+		for _, expression := range []string{"10 *", "foo(t)", "x", `"fast"`, "log(t - 1)"} {
+			_, err := NewExpressionConf(ExpressionConfig{RPS: expression, Duration: time.Second})
+			Expect(err).To(HaveOccurred(), expression)
+		}
+	})
+})
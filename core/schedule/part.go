@@ -0,0 +1,81 @@
+package schedule
+
+import (
+	"sync"
+	"time"
+
+	"github.com/yandex/pandora/core"
+)
+
+// NewPart returns schedule, that emits only part of passed schedule tokens: every parts-th token
+// starting from part-th, where 0 <= part < parts. All parts of the same schedule make up whole
+// schedule, so parts are used to split load between several Pandora instances.
+// Instance starts and stops of core.InstanceStopSchedule are split separately, so every part
+// decreases its instances too.
+func NewPart(s core.Schedule, part, parts int) core.Schedule {
+	if part < 0 || part >= parts {
+		panic("invalid schedule part")
+	}
+	return &partSchedule{schedule: s, part: part, parts: parts}
+}
+
+type partSchedule struct {
+	schedule    core.Schedule
+	part, parts int
+
+	mu sync.Mutex
+	// taken is number of tokens taken from schedule.
+	taken int
+	// takenStops is number of instance stop tokens taken from schedule. They are not counted in taken.
+	takenStops int
+	stop       bool
+}
+
+func (s *partSchedule) Start(startAt time.Time) {
+	s.schedule.Start(startAt)
+}
+
+func (s *partSchedule) Next() (ts time.Time, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		ts, ok = s.schedule.Next()
+		if !ok {
+			return
+		}
+		taken := &s.taken
+		if stopSchedule, isStopSchedule := s.schedule.(core.InstanceStopSchedule); isStopSchedule && stopSchedule.IsStop() {
+			taken = &s.takenStops
+		}
+		i := *taken
+		*taken++
+		if i%s.parts == s.part {
+			s.stop = taken == &s.takenStops
+			return
+		}
+	}
+}
+
+func (s *partSchedule) IsStop() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stop
+}
+
+func (s *partSchedule) Left() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	left := s.schedule.Left()
+	if left < 0 {
+		return left
+	}
+	if _, ok := s.schedule.(core.InstanceStopSchedule); ok {
+		return -1 // Part of instance starts and stops left is unknown.
+	}
+	first := s.taken + (s.part-s.taken%s.parts+s.parts)%s.parts
+	end := s.taken + left
+	if first >= end {
+		return 0
+	}
+	return (end-1-first)/s.parts + 1
+}
@@ -0,0 +1,40 @@
+package schedule
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/yandex/pandora/core/coretest"
+)
+
+var _ = Describe("part", func() {
+	It("first", func() {
+		testee := NewPart(NewConst(1, 5*time.Second), 0, 2)
+		coretest.ExpectScheduleNexts(testee, 0, 2*time.Second, 4*time.Second, 5*time.Second)
+	})
+
+	It("last", func() {
+		testee := NewPart(NewConst(1, 5*time.Second), 1, 2)
+		coretest.ExpectScheduleNexts(testee, time.Second, 3*time.Second, 5*time.Second)
+	})
+
+	It("more parts than tokens", func() {
+		testee := NewPart(NewOnce(2), 2, 3)
+		coretest.ExpectScheduleNexts(testee, 0)
+	})
+
+	It("single part", func() {
+		testee := NewPart(NewOnce(2), 0, 1)
+		coretest.ExpectScheduleNexts(testee, 0, 0, 0)
+	})
+
+	It("unknown left", func() {
+		testee := NewPart(NewUnlimited(time.Second), 0, 2)
+		Expect(testee.Left()).To(BeNumerically("<", 0))
+	})
+
+	It("invalid part panics", func() {
+		Expect(func() { NewPart(NewOnce(1), 2, 2) }).To(Panic())
+	})
+})
@@ -0,0 +1,276 @@
+package schedule
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/yandex/pandora/core"
+	"go.uber.org/zap"
+)
+
+// PoissonConfig configures schedule with exponentially distributed intervals between operations,
+// that is, Poisson process with Ops mean rate.
+// Seed makes operation times reproducible. Random seed is used, if zero.
+// In rps-per-instance mode instance id is added to Seed, so instances have different operation times.
+type PoissonConfig struct {
+	Ops      float64       `validate:"min=0"`
+	Duration time.Duration `validate:"min-time=1ms"`
+	Seed     int64
+}
+
+func NewPoissonConf(conf PoissonConfig) core.Schedule {
+	return NewPoisson(conf.Ops, conf.Duration, conf.Seed)
+}
+
+func NewPoisson(ops float64, duration time.Duration, seed int64) core.Schedule {
+	return newRandomSchedule(duration, seed, func(rnd *rand.Rand) arrivals {
+		return &poissonArrivals{rnd: rnd, ops: ops, limit: duration}
+	})
+}
+
+// NormalConfig configures schedule with normally distributed intervals between operations.
+// Mean interval is 1/Ops, and Jitter is interval standard deviation as share of mean interval.
+// Negative intervals are truncated to zero. Operations are evenly spaced like in const, if Jitter is zero.
+type NormalConfig struct {
+	Ops      float64       `validate:"min=0"`
+	Duration time.Duration `validate:"min-time=1ms"`
+	Jitter   float64       `validate:"min=0"`
+	Seed     int64
+}
+
+func NewNormalConf(conf NormalConfig) core.Schedule {
+	return NewNormal(conf.Ops, conf.Duration, conf.Jitter, conf.Seed)
+}
+
+func NewNormal(ops float64, duration time.Duration, jitter float64, seed int64) core.Schedule {
+	return newRandomSchedule(duration, seed, func(rnd *rand.Rand) arrivals {
+		return &normalArrivals{rnd: rnd, ops: ops, jitter: jitter, limit: duration}
+	})
+}
+
+// OnOffConfig configures bursty schedule, that switches between on and off periods.
+// Periods have exponentially distributed durations with On and Off means. There are no operations
+// in off periods, and operations in on periods are Poisson process with rate, that makes
+// Ops mean rate of the whole schedule.
+type OnOffConfig struct {
+	Ops      float64       `validate:"min=0"`
+	Duration time.Duration `validate:"min-time=1ms"`
+	On       time.Duration `validate:"min-time=1ms"`
+	Off      time.Duration `validate:"min-time=1ms"`
+	Seed     int64
+}
+
+func NewOnOffConf(conf OnOffConfig) core.Schedule {
+	onOps := conf.Ops * float64(conf.On+conf.Off) / float64(conf.On)
+	return NewMMPP(conf.Duration, []MMPPState{
+		{Ops: onOps, Duration: conf.On},
+		{Ops: 0, Duration: conf.Off},
+	}, conf.Seed)
+}
+
+// MMPPConfig configures Markov-modulated Poisson process schedule. Schedule starts in the first
+// state, stays in state for exponentially distributed time with state mean Duration, and then
+// switches to random other state. In every state operations are Poisson process with state Ops rate.
+type MMPPConfig struct {
+	Duration time.Duration `validate:"min-time=1ms"`
+	States   []MMPPState   `validate:"required,dive"`
+	Seed     int64
+}
+
+type MMPPState struct {
+	Ops      float64       `validate:"min=0"`
+	Duration time.Duration `validate:"min-time=1ms"` // Mean time in state.
+}
+
+func NewMMPPConf(conf MMPPConfig) core.Schedule {
+	return NewMMPP(conf.Duration, conf.States, conf.Seed)
+}
+
+func NewMMPP(duration time.Duration, states []MMPPState, seed int64) core.Schedule {
+	return newRandomSchedule(duration, seed, func(rnd *rand.Rand) arrivals {
+		return &mmppArrivals{rnd: rnd, states: states, limit: duration}
+	})
+}
+
+// arrivals generates operation offsets from schedule start. Offsets are not greater than
+// schedule duration, and offset equal to duration means that there are no more operations.
+// Methods are called under schedule lock.
+type arrivals interface {
+	first() time.Duration
+	next(prev time.Duration) time.Duration
+}
+
+func newRandomSchedule(duration time.Duration, seed int64, newArrivals func(rnd *rand.Rand) arrivals) core.Schedule {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+		zap.L().Debug("Random schedule seed", zap.Int64("seed", seed))
+	}
+	rnd := rand.New(rand.NewSource(seed))
+	return &randomSchedule{
+		duration: duration,
+		seed:     seed,
+		rnd:      rnd,
+		arrivals: newArrivals(rnd),
+	}
+}
+
+// randomSchedule generates operation times lazily, so number of operations is unknown until
+// the last one. Offset of next operation is generated in advance, so Left returns 0 right after
+// the last operation has been withdrawn, and -1 before that.
+type randomSchedule struct {
+	duration time.Duration
+	seed     int64
+	rnd      *rand.Rand
+	arrivals arrivals
+
+	mu      sync.Mutex
+	started bool
+	start   time.Time
+	next    time.Duration
+}
+
+var _ core.BoundSchedule = (*randomSchedule)(nil)
+
+// Bind reseeds schedule of rps-per-instance pool with instance id, so instances don't make
+// operations at the same moments.
+func (s *randomSchedule) Bind(deps core.ScheduleDeps) {
+	if !deps.PerInstance {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rnd.Seed(s.seed + int64(deps.InstanceID))
+}
+
+func (s *randomSchedule) Start(startAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.startAt(startAt)
+}
+
+func (s *randomSchedule) startAt(startAt time.Time) {
+	if s.started {
+		return
+	}
+	s.started = true
+	s.start = startAt
+	s.next = s.arrivals.first()
+}
+
+func (s *randomSchedule) Next() (ts time.Time, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.startAt(time.Now())
+	if s.next >= s.duration {
+		return s.start.Add(s.duration), false
+	}
+	ts = s.start.Add(s.next)
+	s.next = s.arrivals.next(s.next)
+	return ts, true
+}
+
+func (s *randomSchedule) Left() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started && s.next >= s.duration {
+		return 0
+	}
+	return -1
+}
+
+type poissonArrivals struct {
+	rnd   *rand.Rand
+	ops   float64
+	limit time.Duration
+}
+
+func (a *poissonArrivals) first() time.Duration { return a.next(0) }
+
+func (a *poissonArrivals) next(prev time.Duration) time.Duration {
+	if a.ops <= 0 {
+		return a.limit
+	}
+	return addSeconds(prev, a.rnd.ExpFloat64()/a.ops, a.limit)
+}
+
+type normalArrivals struct {
+	rnd    *rand.Rand
+	ops    float64
+	jitter float64
+	limit  time.Duration
+}
+
+func (a *normalArrivals) first() time.Duration {
+	if a.ops <= 0 {
+		return a.limit
+	}
+	return 0
+}
+
+func (a *normalArrivals) next(prev time.Duration) time.Duration {
+	if a.ops <= 0 {
+		return a.limit
+	}
+	interval := (1 + a.jitter*a.rnd.NormFloat64()) / a.ops
+	if interval < 0 {
+		interval = 0
+	}
+	return addSeconds(prev, interval, a.limit)
+}
+
+type mmppArrivals struct {
+	rnd      *rand.Rand
+	states   []MMPPState
+	limit    time.Duration
+	state    int
+	stateEnd time.Duration
+}
+
+func (a *mmppArrivals) first() time.Duration {
+	a.stateEnd = a.sojourn(0)
+	return a.next(0)
+}
+
+func (a *mmppArrivals) next(prev time.Duration) time.Duration {
+	for prev < a.limit {
+		ops := a.states[a.state].Ops
+		if ops > 0 {
+			// Exponential distribution is memoryless, so interval can be generated again from state end.
+			next := addSeconds(prev, a.rnd.ExpFloat64()/ops, a.limit)
+			if next < a.stateEnd {
+				return next
+			}
+		}
+		prev = a.stateEnd
+		a.switchState()
+	}
+	return a.limit
+}
+
+func (a *mmppArrivals) switchState() {
+	if len(a.states) > 1 {
+		state := a.rnd.Intn(len(a.states) - 1)
+		if state >= a.state {
+			state++
+		}
+		a.state = state
+	}
+	a.stateEnd = a.sojourn(a.stateEnd)
+}
+
+func (a *mmppArrivals) sojourn(from time.Duration) time.Duration {
+	mean := a.states[a.state].Duration.Seconds()
+	return addSeconds(from, a.rnd.ExpFloat64()*mean, a.limit)
+}
+
+// addSeconds returns offset seconds later than prev, but not greater than limit.
+func addSeconds(prev time.Duration, seconds float64, limit time.Duration) time.Duration {
+	left := float64(limit - prev)
+	interval := seconds * 1e9
+	if interval >= left || math.IsNaN(interval) {
+		return limit
+	}
+	return prev + time.Duration(interval)
+}
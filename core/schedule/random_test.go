@@ -0,0 +1,97 @@
+package schedule
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/yandex/pandora/core"
+)
+
+// drainRandom takes all tokens from random schedule, checking Left, and returns tokens relative to start.
+func drainRandom(sched core.Schedule, start time.Time, duration time.Duration) []time.Duration {
+	Expect(sched.Left()).To(Equal(-1))
+	sched.Start(start)
+	var nexts []time.Duration
+	prev := time.Duration(0)
+	for {
+		left := sched.Left()
+		x, ok := sched.Next()
+		if !ok {
+			Expect(x.Sub(start)).To(Equal(duration))
+			Expect(sched.Left()).To(Equal(0))
+			return nexts
+		}
+		Expect(left).To(Equal(-1))
+		next := x.Sub(start)
+		Expect(next).To(BeNumerically(">=", prev))
+		Expect(next).To(BeNumerically("<", duration))
+		nexts = append(nexts, next)
+		prev = next
+	}
+}
+
+var _ = Describe("random", func() {
+	const duration = 1000 * time.Second
+	start := time.Now()
+
+	It("poisson", func() {
+		nexts := drainRandom(NewPoisson(100, duration, 1), start, duration)
+		Expect(len(nexts)).To(BeNumerically("~", 100000, 2000))
+		Expect(drainRandom(NewPoisson(100, duration, 1), start, duration)).To(Equal(nexts), "same seed")
+		Expect(drainRandom(NewPoisson(100, duration, 2), start, duration)).NotTo(Equal(nexts), "another seed")
+	})
+
+	It("per instance seed", func() {
+		newInstance := func(id int) core.Schedule {
+			sched := NewPoisson(100, time.Second, 1)
+			sched.(core.BoundSchedule).Bind(core.ScheduleDeps{PerInstance: true, InstanceID: id})
+			return sched
+		}
+		nexts := drainRandom(newInstance(0), start, time.Second)
+		Expect(drainRandom(newInstance(0), start, time.Second)).To(Equal(nexts), "same instance")
+		Expect(drainRandom(newInstance(1), start, time.Second)).NotTo(Equal(nexts), "another instance")
+		shared := NewPoisson(100, time.Second, 1)
+		shared.(core.BoundSchedule).Bind(core.ScheduleDeps{})
+		Expect(drainRandom(shared, start, time.Second)).To(Equal(drainRandom(NewPoisson(100, time.Second, 1), start, time.Second)))
+	})
+
+	It("normal without jitter", func() {
+		nexts := drainRandom(NewNormal(10, time.Second, 0, 1), start, time.Second)
+		Expect(nexts).To(HaveLen(10))
+		for i, next := range nexts {
+			Expect(next).To(BeNumerically("~", time.Duration(i)*100*time.Millisecond, time.Microsecond))
+		}
+	})
+
+	It("normal", func() {
+		nexts := drainRandom(NewNormal(100, duration, 0.5, 1), start, duration)
+		Expect(len(nexts)).To(BeNumerically("~", 100000, 2000))
+	})
+
+	It("on off", func() {
+		sched := NewOnOffConf(OnOffConfig{Ops: 100, Duration: duration, On: time.Second, Off: 3 * time.Second, Seed: 1})
+		nexts := drainRandom(sched, start, duration)
+		Expect(len(nexts)).To(BeNumerically("~", 100000, 10000))
+		var pauses int
+		for i := 1; i < len(nexts); i++ {
+			if nexts[i]-nexts[i-1] > 500*time.Millisecond {
+				pauses++
+			}
+		}
+		Expect(pauses).To(BeNumerically(">", 100))
+	})
+
+	It("mmpp", func() {
+		states := []MMPPState{{Ops: 10, Duration: time.Second}, {Ops: 1000, Duration: time.Second}}
+		nexts := drainRandom(NewMMPP(duration, states, 1), start, duration)
+		Expect(len(nexts)).To(BeNumerically("~", 505000, 50000))
+	})
+
+	It("zero ops", func() {
+		Expect(drainRandom(NewPoisson(0, time.Second, 1), start, time.Second)).To(BeEmpty())
+		Expect(drainRandom(NewNormal(0, time.Second, 1, 1), start, time.Second)).To(BeEmpty())
+		states := []MMPPState{{Ops: 0, Duration: time.Millisecond}}
+		Expect(drainRandom(NewMMPP(time.Second, states, 1), start, time.Second)).To(BeEmpty())
+	})
+})
@@ -0,0 +1,135 @@
+package schedule
+
+import (
+	"bufio"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/yandex/pandora/core"
+)
+
+const (
+	ReplayFormatTimestamps = "timestamps"
+	ReplayFormatCounts     = "counts"
+)
+
+// ReplayConfig configures schedule, that replays recorded operation times, for example,
+// request timestamps from production access log.
+// Source has one value on line. Empty lines and lines starting with '#' are skipped.
+type ReplayConfig struct {
+	Source core.DataSource `config:"source" validate:"required"`
+	// Format is "timestamps" or "counts".
+	// Timestamps are unix time in seconds, that may be fractional, or RFC 3339 time. Timestamps MUST be
+	// in non-decreasing order, so operations match lines of ordered ammo. Operations are replayed with
+	// the same offsets from the first timestamp.
+	// Counts are numbers of operations in consecutive seconds. Operations are evenly spaced in their second.
+	Format string `config:"format"`
+	// Speed is time scale factor. For example, recorded hour is replayed in 30 minutes, if Speed is 2.
+	Speed float64 `config:"speed" validate:"gt=0"`
+}
+
+func DefaultReplayConfig() ReplayConfig {
+	return ReplayConfig{Format: ReplayFormatTimestamps, Speed: 1}
+}
+
+func NewReplayConf(conf ReplayConfig) (core.Schedule, error) {
+	var parse func(lines []replayLine, speed float64) (core.Schedule, error)
+	switch conf.Format {
+	case ReplayFormatTimestamps:
+		parse = newTimestampsReplay
+	case ReplayFormatCounts:
+		parse = newCountsReplay
+	default:
+		return nil, errors.Errorf("unknown replay format %q: expected %q or %q",
+			conf.Format, ReplayFormatTimestamps, ReplayFormatCounts)
+	}
+	lines, err := readReplayLines(conf.Source)
+	if err != nil {
+		return nil, err
+	}
+	return parse(lines, conf.Speed)
+}
+
+// replayLine is value of source line with its number, that is used in errors.
+type replayLine struct {
+	number int
+	value  string
+}
+
+func readReplayLines(source core.DataSource) ([]replayLine, error) {
+	rc, err := source.OpenSource()
+	if err != nil {
+		return nil, errors.WithMessage(err, "replay source open failed")
+	}
+	defer rc.Close()
+	var lines []replayLine
+	scanner := bufio.NewScanner(rc)
+	for number := 1; scanner.Scan(); number++ {
+		value := strings.TrimSpace(scanner.Text())
+		if value == "" || strings.HasPrefix(value, "#") {
+			continue
+		}
+		lines = append(lines, replayLine{number, value})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.WithMessage(err, "replay source read failed")
+	}
+	return lines, nil
+}
+
+func newTimestampsReplay(lines []replayLine, speed float64) (core.Schedule, error) {
+	timestamps := make([]time.Time, len(lines))
+	for i, line := range lines {
+		ts, err := parseReplayTimestamp(line.value)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "replay timestamp parse failed at line %v", line.number)
+		}
+		if i > 0 && ts.Before(timestamps[i-1]) {
+			return nil, errors.Errorf("replay timestamp %q at line %v is before previous one: timestamps should be ordered",
+				line.value, line.number)
+		}
+		timestamps[i] = ts
+	}
+	offsets := make([]time.Duration, len(timestamps))
+	for i, ts := range timestamps {
+		offsets[i] = time.Duration(float64(ts.Sub(timestamps[0])) / speed)
+	}
+	var duration time.Duration
+	if len(offsets) > 0 {
+		duration = offsets[len(offsets)-1]
+	}
+	return NewDoAtSchedule(duration, int64(len(offsets)), func(i int64) time.Duration {
+		return offsets[i]
+	}), nil
+}
+
+func parseReplayTimestamp(value string) (time.Time, error) {
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err == nil {
+		return time.Unix(0, int64(seconds*1e9)), nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+func newCountsReplay(lines []replayLine, speed float64) (core.Schedule, error) {
+	// started[i] is number of operations in seconds before i'th.
+	started := make([]int64, len(lines)+1)
+	for i, line := range lines {
+		count, err := strconv.ParseInt(line.value, 10, 64)
+		if err != nil || count < 0 {
+			return nil, errors.Errorf("replay count %q at line %v is not a non-negative integer", line.value, line.number)
+		}
+		started[i+1] = started[i] + count
+	}
+	n := started[len(lines)]
+	duration := time.Duration(float64(time.Duration(len(lines))*time.Second) / speed)
+	return NewDoAtSchedule(duration, n, func(i int64) time.Duration {
+		second := sort.Search(len(lines), func(s int) bool { return started[s+1] > i })
+		count := started[second+1] - started[second]
+		offset := float64(second) + float64(i-started[second])/float64(count)
+		return time.Duration(offset * 1e9 / speed)
+	}), nil
+}
@@ -0,0 +1,81 @@
+package schedule
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/yandex/pandora/core/coretest"
+	"github.com/yandex/pandora/core/datasource"
+)
+
+var _ = Describe("replay", func() {
+	var conf ReplayConfig
+	BeforeEach(func() {
+		conf = DefaultReplayConfig()
+	})
+
+	It("timestamps", func() {
+		conf.Source = datasource.NewString("# recorded\n1697500000\n\n1697500000.5\n2023-10-16T23:46:41Z\n")
+		testee, err := NewReplayConf(conf)
+		Expect(err).NotTo(HaveOccurred())
+		coretest.ExpectScheduleNexts(testee, 0, 500*time.Millisecond, time.Second, time.Second)
+	})
+
+	It("timestamps with speed", func() {
+		conf.Source = datasource.NewString("10\n11\n13\n")
+		conf.Speed = 2
+		testee, err := NewReplayConf(conf)
+		Expect(err).NotTo(HaveOccurred())
+		coretest.ExpectScheduleNexts(testee, 0, 500*time.Millisecond, 1500*time.Millisecond, 1500*time.Millisecond)
+	})
+
+	It("counts", func() {
+		conf.Source = datasource.NewString("2\n0\n4\n")
+		conf.Format = ReplayFormatCounts
+		testee, err := NewReplayConf(conf)
+		Expect(err).NotTo(HaveOccurred())
+		coretest.ExpectScheduleNexts(testee,
+			0, 500*time.Millisecond,
+			2*time.Second, 2250*time.Millisecond, 2500*time.Millisecond, 2750*time.Millisecond,
+			3*time.Second,
+		)
+	})
+
+	It("counts with speed", func() {
+		conf.Source = datasource.NewString("1\n2\n")
+		conf.Format = ReplayFormatCounts
+		conf.Speed = 0.5
+		testee, err := NewReplayConf(conf)
+		Expect(err).NotTo(HaveOccurred())
+		coretest.ExpectScheduleNexts(testee, 0, 2*time.Second, 3*time.Second, 4*time.Second)
+	})
+
+	It("empty", func() {
+		conf.Source = datasource.NewString("")
+		testee, err := NewReplayConf(conf)
+		Expect(err).NotTo(HaveOccurred())
+		coretest.ExpectScheduleNexts(testee, 0)
+	})
+
+	It("unordered timestamps", func() {
+		conf.Source = datasource.NewString("# recorded\n10\n\n12\n11\n13\n")
+		_, err := NewReplayConf(conf)
+		Expect(err).To(MatchError(ContainSubstring(`"11" at line 5`)))
+	})
+
+	It("invalid", func() {
+		conf.Source = datasource.NewString("10\nyesterday\n")
+		_, err := NewReplayConf(conf)
+		Expect(err).To(HaveOccurred())
+
+		conf.Source = datasource.NewString("10\n-1\n")
+		conf.Format = ReplayFormatCounts
+		_, err = NewReplayConf(conf)
+		Expect(err).To(MatchError(ContainSubstring(`"-1" at line 2`)))
+
+		conf.Format = "csv"
+		_, err = NewReplayConf(conf)
+		Expect(err).To(MatchError(ContainSubstring("unknown replay format")))
+	})
+})
@@ -0,0 +1,59 @@
+package schedule
+
+import (
+	"math"
+	"time"
+
+	"github.com/yandex/pandora/core"
+)
+
+// SineConfig configures schedule, which RPS oscillates between Min and Max with Period.
+// RPS is Min at start, and Max at the half of Period.
+type SineConfig struct {
+	Min      float64       `validate:"min=0"`
+	Max      float64       `validate:"min=0"`
+	Period   time.Duration `validate:"min-time=1ms"`
+	Duration time.Duration `validate:"min-time=1ms"`
+}
+
+func NewSineConf(conf SineConfig) core.Schedule {
+	return NewSine(conf.Min, conf.Max, conf.Period, conf.Duration)
+}
+
+func NewSine(min, max float64, period, duration time.Duration) core.Schedule {
+	if min == max {
+		return NewConst(min, duration)
+	}
+	mid := (min + max) / 2
+	amplitude := (max - min) / 2
+	omega := 2 * math.Pi / period.Seconds()
+	// RPS(x) = mid - amplitude * cos(omega * x)
+	// Number of shots from 0 to x = mid * x - amplitude * sin(omega * x) / omega
+	shots := func(x float64) float64 {
+		return mid*x - amplitude*math.Sin(omega*x)/omega
+	}
+	xn := duration.Seconds()
+	n := int64(shots(xn))
+	return NewDoAtSchedule(duration, n, func(i int64) time.Duration {
+		return time.Duration(invertShots(shots, float64(i), xn) * 1e9)
+	})
+}
+
+// invertShots returns x in [0, xn], where monotonic shots(x) equals to i.
+// Shots(x) has no inverse in elementary functions, so x is found by bisection.
+func invertShots(shots func(x float64) float64, i, xn float64) float64 {
+	lo, hi := 0.0, xn
+	// Stop at nanosecond precision, or when float precision is exhausted.
+	for hi-lo > 1e-9 {
+		mid := (lo + hi) / 2
+		if mid == lo || mid == hi {
+			break
+		}
+		if shots(mid) < i {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return hi
+}
@@ -0,0 +1,75 @@
+package core
+
+import "sync"
+
+// InstanceState is state of one Instance, shared between its Gun shoots and lifecycle hooks.
+// For example, session token, that is got on instance start, and used in every shoot.
+// Values SHOULD be accessed via StateKey, so they are typed.
+// InstanceState is safe for concurrent use.
+type InstanceState struct {
+	mu     sync.Mutex
+	values map[string]interface{}
+}
+
+func NewInstanceState() *InstanceState {
+	return &InstanceState{values: map[string]interface{}{}}
+}
+
+func (s *InstanceState) Get(key string) (interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.values[key]
+	return value, ok
+}
+
+func (s *InstanceState) Set(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+}
+
+func (s *InstanceState) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, key)
+}
+
+// Values returns copy of all state values.
+func (s *InstanceState) Values() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values := make(map[string]interface{}, len(s.values))
+	for key, value := range s.values {
+		values[key] = value
+	}
+	return values
+}
+
+// StateKey is typed key of InstanceState value.
+type StateKey[T any] struct {
+	name string
+}
+
+func NewStateKey[T any](name string) StateKey[T] {
+	return StateKey[T]{name: name}
+}
+
+func (k StateKey[T]) Name() string { return k.name }
+
+// Get returns value of key. Ok is false, if value is not set or has another type.
+func (k StateKey[T]) Get(s *InstanceState) (value T, ok bool) {
+	v, ok := s.Get(k.name)
+	if !ok {
+		return value, false
+	}
+	value, ok = v.(T)
+	return value, ok
+}
+
+func (k StateKey[T]) Set(s *InstanceState, value T) {
+	s.Set(k.name, value)
+}
+
+func (k StateKey[T]) Delete(s *InstanceState) {
+	s.Delete(k.name)
+}
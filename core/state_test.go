@@ -0,0 +1,29 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateKey(t *testing.T) {
+	state := NewInstanceState()
+	token := NewStateKey[string]("token")
+	count := NewStateKey[int]("token")
+
+	_, ok := token.Get(state)
+	assert.False(t, ok)
+
+	token.Set(state, "secret")
+	value, ok := token.Get(state)
+	assert.True(t, ok)
+	assert.Equal(t, "secret", value)
+	assert.Equal(t, map[string]interface{}{"token": "secret"}, state.Values())
+
+	_, ok = count.Get(state)
+	assert.False(t, ok, "value of another type")
+
+	token.Delete(state)
+	_, ok = token.Get(state)
+	assert.False(t, ok)
+}
@@ -9,7 +9,6 @@ import (
 	"math/rand"
 	"time"
 
-	"github.com/spf13/afero"
 	"github.com/yandex/pandora/cli"
 	phttp "github.com/yandex/pandora/components/phttp/import"
 	"github.com/yandex/pandora/core"
@@ -68,7 +67,7 @@ func (g *Gun) shoot(ammo *Ammo) {
 
 func main() {
 	// Standard imports.
-	fs := afero.NewOsFs()
+	fs := cli.PluginFs()
 	coreimport.Import(fs)
 
 	// May not be imported, if you don't need http guns and etc.
@@ -2,7 +2,9 @@ package answlog
 
 import (
 	"os"
+	"sync"
 
+	"go.uber.org/atomic"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -21,6 +23,30 @@ func getAnswWriter(path string) zapcore.WriteSyncer {
 	if path == "" {
 		path = "./answ.log"
 	}
-	file, _ := os.Create(path)
-	return zapcore.AddSync(file)
+	return &lazyFile{path: path}
+}
+
+// lazyFile creates file on first write, so guns, that are created but don't log answers,
+// as on 'pandora validate', don't create or truncate it.
+type lazyFile struct {
+	path   string
+	once   sync.Once
+	opened atomic.Bool
+	file   zapcore.WriteSyncer
+}
+
+func (f *lazyFile) Write(p []byte) (int, error) {
+	f.once.Do(func() {
+		file, _ := os.Create(f.path)
+		f.file = zapcore.AddSync(file)
+		f.opened.Store(true)
+	})
+	return f.file.Write(p)
+}
+
+func (f *lazyFile) Sync() error {
+	if !f.opened.Load() {
+		return nil
+	}
+	return f.file.Sync()
 }
@@ -0,0 +1,165 @@
+// Package histogram implements compact log-linear histogram of non-negative integer values,
+// similar to HdrHistogram. Values are bucketed with relative error less than 1/64, so memory
+// usage depends only on values magnitude, not on values count.
+// Histogram is not goroutine safe.
+package histogram
+
+import (
+	"math"
+	"math/bits"
+)
+
+const (
+	subBucketBits      = 7
+	subBucketCount     = 1 << subBucketBits
+	subBucketHalfCount = subBucketCount / 2
+)
+
+type Histogram struct {
+	counts []int64
+	count  int64
+	sum    float64
+	min    int64
+	max    int64
+}
+
+func New() *Histogram {
+	return &Histogram{}
+}
+
+// Record records value. Negative values are recorded as zero.
+func (h *Histogram) Record(v int64) {
+	h.RecordN(v, 1)
+}
+
+// RecordN records value n times.
+func (h *Histogram) RecordN(v int64, n int64) {
+	if n <= 0 {
+		return
+	}
+	if v < 0 {
+		v = 0
+	}
+	i := bucketIndex(v)
+	if i >= len(h.counts) {
+		h.grow(i + 1)
+	}
+	h.counts[i] += n
+	if h.count == 0 || v < h.min {
+		h.min = v
+	}
+	if v > h.max {
+		h.max = v
+	}
+	h.count += n
+	h.sum += float64(v) * float64(n)
+}
+
+// Merge adds all values recorded in other histogram.
+func (h *Histogram) Merge(other *Histogram) {
+	if other.count == 0 {
+		return
+	}
+	if len(other.counts) > len(h.counts) {
+		h.grow(len(other.counts))
+	}
+	for i, c := range other.counts {
+		h.counts[i] += c
+	}
+	if h.count == 0 || other.min < h.min {
+		h.min = other.min
+	}
+	if other.max > h.max {
+		h.max = other.max
+	}
+	h.count += other.count
+	h.sum += other.sum
+}
+
+func (h *Histogram) Reset() {
+	for i := range h.counts {
+		h.counts[i] = 0
+	}
+	h.count = 0
+	h.sum = 0
+	h.min = 0
+	h.max = 0
+}
+
+func (h *Histogram) Count() int64 { return h.count }
+func (h *Histogram) Min() int64   { return h.min }
+func (h *Histogram) Max() int64   { return h.max }
+func (h *Histogram) Sum() float64 { return h.sum }
+
+func (h *Histogram) Mean() float64 {
+	if h.count == 0 {
+		return 0
+	}
+	return h.sum / float64(h.count)
+}
+
+// Quantile returns value, that is greater or equal than q share of recorded values,
+// where q is in [0, 1]. Returned value is highest value equivalent to bucket containing quantile,
+// but not greater than maximum recorded value. Returns zero for empty histogram.
+func (h *Histogram) Quantile(q float64) int64 {
+	if h.count == 0 {
+		return 0
+	}
+	q = math.Max(0, math.Min(q, 1))
+	rank := int64(math.Ceil(q * float64(h.count)))
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			v := bucketHighest(i)
+			if v > h.max {
+				v = h.max
+			}
+			if v < h.min {
+				v = h.min
+			}
+			return v
+		}
+	}
+	return h.max
+}
+
+// CountAtOrBelow returns approximate number of recorded values, that are less or equal than v.
+// All values from bucket containing v are counted.
+func (h *Histogram) CountAtOrBelow(v int64) int64 {
+	if v < 0 {
+		return 0
+	}
+	last := bucketIndex(v)
+	var n int64
+	for i := 0; i <= last && i < len(h.counts); i++ {
+		n += h.counts[i]
+	}
+	return n
+}
+
+func (h *Histogram) grow(n int) {
+	counts := make([]int64, n)
+	copy(counts, h.counts)
+	h.counts = counts
+}
+
+func bucketIndex(v int64) int {
+	if v < subBucketCount {
+		return int(v)
+	}
+	shift := bits.Len64(uint64(v)) - subBucketBits
+	return shift*subBucketHalfCount + int(v>>uint(shift))
+}
+
+func bucketHighest(i int) int64 {
+	if i < subBucketCount {
+		return int64(i)
+	}
+	shift := i/subBucketHalfCount - 1
+	mantissa := int64(i - shift*subBucketHalfCount)
+	return (mantissa+1)<<uint(shift) - 1
+}
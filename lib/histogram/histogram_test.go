@@ -0,0 +1,67 @@
+package histogram
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogram_SmallValuesExact(t *testing.T) {
+	h := New()
+	for v := int64(1); v <= 100; v++ {
+		h.Record(v)
+	}
+	assert.EqualValues(t, 100, h.Count())
+	assert.EqualValues(t, 1, h.Min())
+	assert.EqualValues(t, 100, h.Max())
+	assert.Equal(t, 50.5, h.Mean())
+	assert.EqualValues(t, 50, h.Quantile(0.5))
+	assert.EqualValues(t, 99, h.Quantile(0.99))
+	assert.EqualValues(t, 100, h.Quantile(1))
+	assert.EqualValues(t, 1, h.Quantile(0))
+	assert.EqualValues(t, 10, h.CountAtOrBelow(10))
+}
+
+func TestHistogram_RelativeError(t *testing.T) {
+	h := New()
+	r := rand.New(rand.NewSource(1))
+	values := make([]int64, 10000)
+	for i := range values {
+		values[i] = r.Int63n(10000000)
+		h.Record(values[i])
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	for _, q := range []float64{0.5, 0.9, 0.95, 0.99, 0.999} {
+		expected := values[int(q*float64(len(values)))-1]
+		actual := h.Quantile(q)
+		assert.InEpsilon(t, expected, actual, 1.0/64, "quantile %v", q)
+	}
+}
+
+func TestHistogram_Merge(t *testing.T) {
+	a, b := New(), New()
+	a.Record(10)
+	b.RecordN(1000000, 3)
+	b.Record(-5)
+	a.Merge(b)
+	assert.EqualValues(t, 5, a.Count())
+	assert.EqualValues(t, 0, a.Min())
+	assert.EqualValues(t, 1000000, a.Max())
+	assert.EqualValues(t, 1000000, a.Quantile(1))
+
+	a.Reset()
+	assert.EqualValues(t, 0, a.Count())
+	assert.EqualValues(t, 0, a.Quantile(0.5))
+}
+
+func TestBucketBounds(t *testing.T) {
+	for _, v := range []int64{0, 1, 127, 128, 129, 255, 256, 1 << 40, 1<<62 + 12345} {
+		i := bucketIndex(v)
+		assert.GreaterOrEqual(t, bucketHighest(i), v)
+		if i > 0 {
+			assert.Less(t, bucketHighest(i-1), v)
+		}
+	}
+}
@@ -6,7 +6,6 @@
 package main
 
 import (
-	"github.com/spf13/afero"
 	"github.com/yandex/pandora/cli"
 	grpc "github.com/yandex/pandora/components/grpc/import"
 	phttp "github.com/yandex/pandora/components/phttp/import"
@@ -16,7 +15,7 @@ import (
 func main() {
 	// CLI don't know anything about components initially.
 	// All extpoints constructors and default configurations should be registered, before CLI run.
-	fs := afero.NewOsFs()
+	fs := cli.PluginFs()
 	coreimport.Import(fs)
 	phttp.Import(fs)
 	grpc.Import(fs)